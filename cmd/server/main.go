@@ -2,7 +2,8 @@ package main
 
 import (
     "context"
-    "log"
+    "fmt"
+    "log/slog"
     "net/http"
     "os"
     "os/signal"
@@ -13,21 +14,36 @@ import (
     "github.com/joho/godotenv"
     "github.com/gatij/goUrlShortener/config"
     "github.com/gatij/goUrlShortener/internal/api"
+    "github.com/gatij/goUrlShortener/internal/api/handlers"
+    "github.com/gatij/goUrlShortener/internal/api/middleware"
+    "github.com/gatij/goUrlShortener/internal/logging"
     "github.com/gatij/goUrlShortener/internal/service"
-    "github.com/gatij/goUrlShortener/internal/storage/metrics"
+    "github.com/gatij/goUrlShortener/internal/storage/factory"
     "github.com/gatij/goUrlShortener/internal/storage/url"
+    "github.com/gatij/goUrlShortener/internal/telemetry"
+    "github.com/gatij/goUrlShortener/pkg/utils"
 )
 
 func main() {
     // Load .env file if it exists
-    if err := godotenv.Load(); err != nil {
-        log.Println("No .env file found or error loading it. Using environment variables.")
-    }
+    envErr := godotenv.Load()
 
     // Load configuration
     cfg, err := config.Load()
     if err != nil {
-        log.Fatalf("Failed to load configuration: %v", err)
+        slog.Error("Failed to load configuration", "error", err)
+        os.Exit(1)
+    }
+
+    // Set up structured logging, used by everything logged from here on
+    logger, err := logging.New(os.Stdout, cfg.LogFormat, cfg.LogLevel)
+    if err != nil {
+        slog.Error("Invalid log settings", "error", err)
+        os.Exit(1)
+    }
+    slog.SetDefault(logger)
+    if envErr != nil {
+        slog.Info("No .env file found or error loading it. Using environment variables.")
     }
 
     // Set Gin mode based on environment
@@ -35,20 +51,130 @@ func main() {
         gin.SetMode(gin.ReleaseMode)
     }
 
+    // Exit only once run has returned, so its deferred cleanup always runs
+    if err := run(cfg); err != nil {
+        slog.Error("Server failed", "error", err)
+        os.Exit(1)
+    }
+}
+
+// run wires up the service from cfg and serves requests until the process
+// is told to stop, closing storage on the way out
+func run(cfg *config.Config) error {
     // Initialize storage
-    urlStore := url.NewMemoryStorage()
-    metricsStore := metrics.NewMemoryStorage()
+    stores, err := factory.New(cfg)
+    if err != nil {
+        return fmt.Errorf("initialize storage: %w", err)
+    }
+    defer func() {
+        if err := stores.Close(); err != nil {
+            slog.Error("Failed to close storage", "error", err)
+        }
+    }()
+    slog.Info("Using storage", "type", cfg.StorageType)
+    urlStore := stores.URLs
+    metricsStore := stores.Metrics
+
+    // Time storage operations and expose them along with request metrics
+    var metricsRegistry *telemetry.Telemetry
+    if cfg.MetricsEnabled {
+        metricsRegistry = telemetry.New()
+        metricsRegistry.RegisterLinkCount(stores.URLs)
+        urlStore = telemetry.InstrumentURLStorage(urlStore, metricsRegistry)
+        metricsStore = telemetry.InstrumentMetricsStorage(metricsStore, metricsRegistry)
+    }
+
+    // Purge expired links in the background until shutdown
+    backgroundCtx, stopBackground := context.WithCancel(context.Background())
+    defer stopBackground()
+    go url.NewReaper(urlStore, cfg.ReapInterval, cfg.FallbackRetention).Run(backgroundCtx)
+
+    // Set up short code generation
+    generator, err := utils.NewCodeGenerator(utils.GeneratorOptions{
+        Strategy: cfg.CodeStrategy,
+        Alphabet: cfg.CodeAlphabet,
+        Salt:     cfg.CodeSalt,
+        Sequence: stores.Sequence,
+    })
+    if err != nil {
+        return fmt.Errorf("invalid short code settings: %w", err)
+    }
+
+    // Load the domain policy and pick up edits to its files until shutdown
+    var domainPolicy *utils.DomainPolicy
+    if cfg.DomainBlocklistFile != "" || cfg.DomainAllowlistFile != "" {
+        domainPolicy, err = utils.LoadDomainPolicy(cfg.DomainBlocklistFile, cfg.DomainAllowlistFile)
+        if err != nil {
+            return fmt.Errorf("invalid domain policy: %w", err)
+        }
+        go domainPolicy.Watch(backgroundCtx, cfg.PolicyReloadInterval)
+    }
+
+    // Refuse links back to this service and chains through other shorteners
+    shortenerDomains := cfg.ShortenerDomains
+    if len(shortenerDomains) == 0 {
+        shortenerDomains = utils.DefaultShortenerDomains
+    }
+    loopGuard := utils.NewLoopGuard(cfg.BaseURL, cfg.SelfHostnames, shortenerDomains)
+    var resolver *utils.RedirectResolver
+    if cfg.ResolveShortLinks {
+        resolver = utils.NewRedirectResolver(loopGuard, cfg.ShortLinkResolveTimeout, utils.DefaultMaxRedirectHops)
+    }
 
     // Initialize services
     metricsService := service.NewMetricsService(metricsStore)
+    analyticsService := service.NewAnalyticsService(stores.Analytics)
     shortenerConfig := service.ShortenerConfig{
         BaseURL:    cfg.BaseURL,
         CodeLength: cfg.CodeLength,
+
+        MaxGenerateAttempts: cfg.MaxCodeAttempts,
+        EscalationThreshold: cfg.CodeEscalationThreshold,
+        Generator:           generator,
+        DomainPolicy:        domainPolicy,
+        LoopGuard:           loopGuard,
+        Resolver:            resolver,
+        FallbackRetention:   cfg.FallbackRetention,
+    }
+    if metricsRegistry != nil {
+        shortenerConfig.Observer = metricsRegistry
     }
     shortenerService := service.NewShortenerService(urlStore, metricsService, shortenerConfig)
+    apiKeyService := service.NewAPIKeyService(stores.APIKeys, cfg.AdminAPIKey)
+    if cfg.AdminAPIKey == "" {
+        slog.Warn("ADMIN_API_KEY is not set, API keys can only be managed with keys already stored")
+    }
+    if cfg.LinkCookieSecret == "" {
+        slog.Warn("LINK_COOKIE_SECRET is not set, visitors of password-protected links are asked again after a restart or on another instance")
+    }
 
     // Setup router
-    router := api.SetupRouter(shortenerService, metricsService)
+    router := api.SetupRouter(shortenerService, metricsService, analyticsService, apiKeyService, api.RouterConfig{
+        AllowAnonymousShorten: cfg.AllowAnonymousShorten,
+
+        TrustedProxies:    cfg.TrustedProxies,
+        CreateRateLimit:   middleware.RateLimit{PerMinute: cfg.CreateRateLimit, Burst: cfg.CreateRateBurst},
+        RedirectRateLimit: middleware.RateLimit{PerMinute: cfg.RedirectRateLimit, Burst: cfg.RedirectRateBurst},
+
+        Batch: handlers.BatchConfig{
+            MaxSize:     cfg.BatchMaxSize,
+            Concurrency: cfg.BatchConcurrency,
+            Timeout:     cfg.BatchTimeout,
+        },
+        Redirect: handlers.RedirectConfig{
+            Status:      cfg.RedirectStatus,
+            CacheMaxAge: cfg.RedirectCacheMaxAge,
+            InactiveURL: cfg.InactiveLinkURL,
+            FallbackURL: cfg.FallbackURL,
+        },
+        Password: handlers.PasswordConfig{
+            Secret:   []byte(cfg.LinkCookieSecret),
+            TTL:      cfg.LinkPasswordTTL,
+            Attempts: middleware.RateLimit{PerMinute: cfg.PasswordRateLimit, Burst: cfg.PasswordRateBurst},
+        },
+
+        Telemetry: metricsRegistry,
+    })
 
     // Configure server
     server := &http.Server{
@@ -60,27 +186,33 @@ func main() {
     }
 
     // Start server in a goroutine
+    serverErr := make(chan error, 1)
     go func() {
-        log.Printf("Starting server on port %s...", cfg.Port)
+        slog.Info("Starting server", "port", cfg.Port)
         if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-            log.Fatalf("Server error: %v", err)
+            serverErr <- err
         }
     }()
 
     // Wait for interrupt signal to gracefully shut down the server
     quit := make(chan os.Signal, 1)
     signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-    <-quit
+    select {
+    case <-quit:
+    case err := <-serverErr:
+        return fmt.Errorf("server error: %w", err)
+    }
 
-    log.Println("Shutting down server...")
+    slog.Info("Shutting down server")
 
     // Create context with timeout for shutdown
     ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
     defer cancel()
 
     if err := server.Shutdown(ctx); err != nil {
-        log.Fatalf("Server forced to shutdown: %v", err)
+        return fmt.Errorf("server forced to shutdown: %w", err)
     }
 
-    log.Println("Server exited properly")
+    slog.Info("Server exited properly")
+    return nil
 }
\ No newline at end of file
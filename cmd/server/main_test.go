@@ -2,32 +2,56 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/gatij/goUrlShortener/internal/api"
+	"github.com/gatij/goUrlShortener/internal/api/handlers"
+	"github.com/gatij/goUrlShortener/internal/api/middleware"
+	"github.com/gatij/goUrlShortener/internal/model"
 	"github.com/gatij/goUrlShortener/internal/service"
+	"github.com/gatij/goUrlShortener/internal/storage/analytics"
+	"github.com/gatij/goUrlShortener/internal/storage/apikey"
 	"github.com/gatij/goUrlShortener/internal/storage/metrics"
 	"github.com/gatij/goUrlShortener/internal/storage/url"
+	"github.com/gatij/goUrlShortener/internal/telemetry"
+	"github.com/gatij/goUrlShortener/pkg/utils"
 )
 
+// testAdminKey is the admin API key accepted by the test router
+const testAdminKey = "test-admin-key"
+
 func setupTestRouter() http.Handler {
+	return setupTestRouterWithConfig(api.RouterConfig{}, service.ShortenerConfig{})
+}
+
+// setupTestRouterWithConfig creates a test router, filling in the base URL
+// and code length of shortenerConfig
+func setupTestRouterWithConfig(config api.RouterConfig, shortenerConfig service.ShortenerConfig) http.Handler {
+	return setupTestRouterWithStorage(url.NewMemoryStorage(), config, shortenerConfig)
+}
+
+// setupTestRouterWithStorage creates a test router like
+// setupTestRouterWithConfig, keeping its links in urlStore
+func setupTestRouterWithStorage(urlStore url.Storage, config api.RouterConfig, shortenerConfig service.ShortenerConfig) http.Handler {
 	// Initialize storage
-	urlStore := url.NewMemoryStorage()
 	metricsStore := metrics.NewMemoryStorage()
 
 	// Initialize services
 	metricsService := service.NewMetricsService(metricsStore)
-	shortenerConfig := service.ShortenerConfig{
-		BaseURL:    "http://localhost:3000",
-		CodeLength: 6,
-	}
+	analyticsService := service.NewAnalyticsService(analytics.NewMemoryStorage())
+	shortenerConfig.BaseURL = "http://localhost:3000"
+	shortenerConfig.CodeLength = 6
 	shortenerService := service.NewShortenerService(urlStore, metricsService, shortenerConfig)
+	apiKeyService := service.NewAPIKeyService(apikey.NewMemoryStorage(), testAdminKey)
 
 	// Setup router
-	return api.SetupRouter(shortenerService, metricsService)
+	return api.SetupRouter(shortenerService, metricsService, analyticsService, apiKeyService, config)
 }
 
 func TestHealthEndpoint(t *testing.T) {
@@ -61,6 +85,7 @@ func TestCreateAndRetrieveURL(t *testing.T) {
 	
 	createReq, _ := http.NewRequest("POST", "/api/v1/urls", bytes.NewBuffer(jsonData))
 	createReq.Header.Set("Content-Type", "application/json")
+	createReq.Header.Set("Authorization", "Bearer "+testAdminKey)
 	
 	createResp := httptest.NewRecorder()
 	router.ServeHTTP(createResp, createReq)
@@ -94,22 +119,30 @@ func TestCreateAndRetrieveURL(t *testing.T) {
 		t.Errorf("Expected redirect to 'https://github.com/golang/go' but got %s", location)
 	}
 	
-	// Check metrics
-	metricsReq, _ := http.NewRequest("GET", "/api/v1/metrics/domains", nil)
-	metricsResp := httptest.NewRecorder()
-	router.ServeHTTP(metricsResp, metricsReq)
-	
-	// Verify metrics response
-	if metricsResp.Code != http.StatusOK {
-		t.Errorf("Expected status code %d but got %d", http.StatusOK, metricsResp.Code)
-	}
-	
+	// Check metrics. Domain counts are updated asynchronously, so poll briefly.
 	var metricsResult map[string]interface{}
-	json.Unmarshal(metricsResp.Body.Bytes(), &metricsResult)
-	
-	topDomains, ok := metricsResult["top_domains"].([]interface{})
-	if !ok || len(topDomains) == 0 {
-		t.Errorf("Expected non-empty top_domains but got: %v", metricsResult)
+	deadline := time.Now().Add(time.Second)
+	for {
+		metricsReq, _ := http.NewRequest("GET", "/api/v1/metrics/domains", nil)
+		metricsResp := httptest.NewRecorder()
+		router.ServeHTTP(metricsResp, metricsReq)
+		
+		// Verify metrics response
+		if metricsResp.Code != http.StatusOK {
+			t.Fatalf("Expected status code %d but got %d", http.StatusOK, metricsResp.Code)
+		}
+		
+		metricsResult = nil
+		json.Unmarshal(metricsResp.Body.Bytes(), &metricsResult)
+		
+		if topDomains, ok := metricsResult["top_domains"].([]interface{}); ok && len(topDomains) > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Errorf("Expected non-empty top_domains but got: %v", metricsResult)
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
 	}
 }
 
@@ -140,3 +173,1297 @@ func TestRootEndpoint(t *testing.T) {
 		t.Errorf("Expected 'endpoints' field in response but got: %v", response)
 	}
 }
+
+func TestCreateExpiringURL(t *testing.T) {
+	router := setupTestRouter()
+	
+	// Create a shortened URL that expires in an hour
+	jsonData := []byte(`{"url": "https://github.com/golang/go/issues", "expires_in": 3600}`)
+	createReq, _ := http.NewRequest("POST", "/api/v1/urls", bytes.NewBuffer(jsonData))
+	createReq.Header.Set("Content-Type", "application/json")
+	createReq.Header.Set("Authorization", "Bearer "+testAdminKey)
+	
+	createResp := httptest.NewRecorder()
+	router.ServeHTTP(createResp, createReq)
+	
+	if createResp.Code != http.StatusCreated {
+		t.Fatalf("Expected status code %d but got %d", http.StatusCreated, createResp.Code)
+	}
+	
+	var createResult map[string]interface{}
+	json.Unmarshal(createResp.Body.Bytes(), &createResult)
+	
+	if _, ok := createResult["expires_at"].(string); !ok {
+		t.Errorf("Expected expires_at in response but got: %v", createResult)
+	}
+	
+	// An expiry in the past is rejected
+	jsonData = []byte(`{"url": "https://github.com/golang/go/wiki", "expires_at": "2000-01-01T00:00:00Z"}`)
+	badReq, _ := http.NewRequest("POST", "/api/v1/urls", bytes.NewBuffer(jsonData))
+	badReq.Header.Set("Content-Type", "application/json")
+	badReq.Header.Set("Authorization", "Bearer "+testAdminKey)
+	
+	badResp := httptest.NewRecorder()
+	router.ServeHTTP(badResp, badReq)
+	
+	if badResp.Code != http.StatusBadRequest {
+		t.Errorf("Expected status code %d but got %d", http.StatusBadRequest, badResp.Code)
+	}
+}
+
+func TestCreateURLWithAlias(t *testing.T) {
+	router := setupTestRouter()
+	
+	post := func(body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/api/v1/urls", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+testAdminKey)
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		return resp
+	}
+	
+	// Create a shortened URL with a vanity code
+	createResp := post(`{"url": "https://github.com/golang/go/releases", "alias": "go-rel"}`)
+	if createResp.Code != http.StatusCreated {
+		t.Fatalf("Expected status code %d but got %d", http.StatusCreated, createResp.Code)
+	}
+	
+	// The alias redirects to the original URL
+	redirectReq, _ := http.NewRequest("GET", "/go-rel", nil)
+	redirectResp := httptest.NewRecorder()
+	router.ServeHTTP(redirectResp, redirectReq)
+	
+	if redirectResp.Code != http.StatusMovedPermanently {
+		t.Errorf("Expected status code %d but got %d", http.StatusMovedPermanently, redirectResp.Code)
+	}
+	
+	// A second URL can't take the same alias
+	if resp := post(`{"url": "https://github.com/golang/tools", "alias": "go-rel"}`); resp.Code != http.StatusConflict {
+		t.Errorf("Expected status code %d but got %d", http.StatusConflict, resp.Code)
+	}
+	
+	// Reserved words are rejected
+	if resp := post(`{"url": "https://github.com/golang/tools", "alias": "health"}`); resp.Code != http.StatusBadRequest {
+		t.Errorf("Expected status code %d but got %d", http.StatusBadRequest, resp.Code)
+	}
+}
+
+func TestCreateExistingURLWithOtherSettings(t *testing.T) {
+	router := setupTestRouter()
+
+	post := func(body string) (*httptest.ResponseRecorder, map[string]interface{}) {
+		req, _ := http.NewRequest("POST", "/api/v1/urls", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+testAdminKey)
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		var result map[string]interface{}
+		json.Unmarshal(resp.Body.Bytes(), &result)
+		return resp, result
+	}
+
+	resp, link := post(`{"url": "https://github.com/golang/go/issues"}`)
+	if resp.Code != http.StatusCreated {
+		t.Fatalf("Expected status code %d but got %d", http.StatusCreated, resp.Code)
+	}
+	shortCode := link["short_code"]
+
+	// Asking for the same URL with settings the link lacks is a conflict
+	// naming the existing link, not the link without those settings
+	for _, body := range []string{
+		`{"url": "https://github.com/golang/go/issues", "expires_at": "2099-01-01T00:00:00Z"}`,
+		`{"url": "https://github.com/golang/go/issues", "redirect_status": 302}`,
+		`{"url": "https://github.com/golang/go/issues", "preview": true}`,
+	} {
+		resp, result := post(body)
+		if resp.Code != http.StatusConflict || result["short_code"] != shortCode {
+			t.Errorf("Expected a conflict naming %v for %s but got %d %v", shortCode, body, resp.Code, result)
+		}
+	}
+
+	// Nor is a link with settings handed to a request without them
+	for _, body := range []string{
+		`{"url": "https://go.dev/doc", "expires_at": "2099-01-01T00:00:00Z"}`,
+		`{"url": "https://go.dev/blog", "redirect_status": 302}`,
+		`{"url": "https://go.dev/learn", "preview": true}`,
+	} {
+		if resp, _ := post(body); resp.Code != http.StatusCreated {
+			t.Fatalf("Expected status code %d for %s but got %d", http.StatusCreated, body, resp.Code)
+		}
+	}
+	for _, url := range []string{"https://go.dev/doc", "https://go.dev/blog", "https://go.dev/learn"} {
+		if resp, _ := post(`{"url": "` + url + `"}`); resp.Code != http.StatusConflict {
+			t.Errorf("Expected status code %d for a plain %s but got %d", http.StatusConflict, url, resp.Code)
+		}
+	}
+
+	// The same settings get the existing link back
+	resp, again := post(`{"url": "https://go.dev/doc", "expires_at": "2099-01-01T00:00:00Z"}`)
+	if resp.Code != http.StatusCreated || again["short_code"] == nil {
+		t.Errorf("Expected the existing link for matching settings but got %d %v", resp.Code, again)
+	}
+}
+
+func TestLinkStats(t *testing.T) {
+	router := setupTestRouter()
+	
+	createReq, _ := http.NewRequest("POST", "/api/v1/urls", bytes.NewBufferString(`{"url": "https://github.com/golang/go", "alias": "golang"}`))
+	createReq.Header.Set("Content-Type", "application/json")
+	createReq.Header.Set("Authorization", "Bearer "+testAdminKey)
+	createResp := httptest.NewRecorder()
+	router.ServeHTTP(createResp, createReq)
+	if createResp.Code != http.StatusCreated {
+		t.Fatalf("Expected status code %d but got %d", http.StatusCreated, createResp.Code)
+	}
+	
+	// Follow the link a few times
+	for i := 0; i < 3; i++ {
+		redirectReq, _ := http.NewRequest("GET", "/golang", nil)
+		redirectReq.Header.Set("Referer", "https://news.ycombinator.com/item?id=1")
+		redirectReq.Header.Set("User-Agent", "Mozilla/5.0 (X11; Linux x86_64; rv:121.0) Gecko/20100101 Firefox/121.0")
+		router.ServeHTTP(httptest.NewRecorder(), redirectReq)
+	}
+	
+	// Clicks are recorded asynchronously, so poll briefly
+	var stats struct {
+		TotalClicks  int `json:"total_clicks"`
+		ClicksPerDay []struct {
+			Clicks int `json:"clicks"`
+		} `json:"clicks_per_day"`
+		TopReferrers []struct {
+			Name string `json:"name"`
+		} `json:"top_referrers"`
+		TopUserAgents []struct {
+			Name string `json:"name"`
+		} `json:"top_user_agents"`
+	}
+	deadline := time.Now().Add(time.Second)
+	for {
+		statsReq, _ := http.NewRequest("GET", "/api/v1/urls/golang/stats", nil)
+		statsReq.Header.Set("Authorization", "Bearer "+testAdminKey)
+		statsResp := httptest.NewRecorder()
+		router.ServeHTTP(statsResp, statsReq)
+		if statsResp.Code != http.StatusOK {
+			t.Fatalf("Expected status code %d but got %d", http.StatusOK, statsResp.Code)
+		}
+		json.Unmarshal(statsResp.Body.Bytes(), &stats)
+		
+		if stats.TotalClicks == 3 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected 3 clicks but got %d", stats.TotalClicks)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	
+	if len(stats.ClicksPerDay) != 1 || stats.ClicksPerDay[0].Clicks != 3 {
+		t.Errorf("Expected 3 clicks today but got %+v", stats.ClicksPerDay)
+	}
+	if len(stats.TopReferrers) != 1 || stats.TopReferrers[0].Name != "news.ycombinator.com" {
+		t.Errorf("Expected news.ycombinator.com as the only referrer but got %+v", stats.TopReferrers)
+	}
+	if len(stats.TopUserAgents) != 1 || stats.TopUserAgents[0].Name != "Firefox" {
+		t.Errorf("Expected Firefox as the only user agent but got %+v", stats.TopUserAgents)
+	}
+
+	// A new link under the alias of a deleted one starts without its clicks
+	deleteReq, _ := http.NewRequest("DELETE", "/api/v1/urls/golang", nil)
+	deleteReq.Header.Set("Authorization", "Bearer "+testAdminKey)
+	deleteResp := httptest.NewRecorder()
+	router.ServeHTTP(deleteResp, deleteReq)
+	if deleteResp.Code != http.StatusNoContent {
+		t.Fatalf("Expected status code %d but got %d", http.StatusNoContent, deleteResp.Code)
+	}
+	recreateReq, _ := http.NewRequest("POST", "/api/v1/urls", bytes.NewBufferString(`{"url": "https://go.dev", "alias": "golang"}`))
+	recreateReq.Header.Set("Content-Type", "application/json")
+	recreateReq.Header.Set("Authorization", "Bearer "+testAdminKey)
+	recreateResp := httptest.NewRecorder()
+	router.ServeHTTP(recreateResp, recreateReq)
+	if recreateResp.Code != http.StatusCreated {
+		t.Fatalf("Expected status code %d but got %d", http.StatusCreated, recreateResp.Code)
+	}
+	reusedReq, _ := http.NewRequest("GET", "/api/v1/urls/golang/stats", nil)
+	reusedReq.Header.Set("Authorization", "Bearer "+testAdminKey)
+	reusedResp := httptest.NewRecorder()
+	router.ServeHTTP(reusedResp, reusedReq)
+	var reused struct {
+		TotalClicks  int               `json:"total_clicks"`
+		TopReferrers []json.RawMessage `json:"top_referrers"`
+	}
+	json.Unmarshal(reusedResp.Body.Bytes(), &reused)
+	if reusedResp.Code != http.StatusOK || reused.TotalClicks != 0 || len(reused.TopReferrers) != 0 {
+		t.Errorf("Expected empty stats for the new link but got %d: %s", reusedResp.Code, reusedResp.Body.String())
+	}
+
+	// Unknown links have no stats
+	missingReq, _ := http.NewRequest("GET", "/api/v1/urls/nothere/stats", nil)
+	missingReq.Header.Set("Authorization", "Bearer "+testAdminKey)
+	missingResp := httptest.NewRecorder()
+	router.ServeHTTP(missingResp, missingReq)
+	if missingResp.Code != http.StatusNotFound {
+		t.Errorf("Expected status code %d but got %d", http.StatusNotFound, missingResp.Code)
+	}
+}
+
+func TestManageURL(t *testing.T) {
+	router := setupTestRouter()
+	
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+testAdminKey)
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		return resp
+	}
+	
+	if resp := do("POST", "/api/v1/urls", `{"url": "https://github.com/golang/go", "alias": "golang"}`); resp.Code != http.StatusCreated {
+		t.Fatalf("Expected status code %d but got %d", http.StatusCreated, resp.Code)
+	}
+	
+	// Read the link's metadata
+	getResp := do("GET", "/api/v1/urls/golang", "")
+	if getResp.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d but got %d", http.StatusOK, getResp.Code)
+	}
+	var link map[string]interface{}
+	json.Unmarshal(getResp.Body.Bytes(), &link)
+	if link["short_url"] != "http://localhost:3000/golang" || link["original_url"] != "https://github.com/golang/go" {
+		t.Errorf("Unexpected link metadata: %v", link)
+	}
+	if createdAt, _ := link["created_at"].(string); createdAt == "" {
+		t.Errorf("Expected created_at in link metadata: %v", link)
+	}
+	
+	// Retarget it
+	patchResp := do("PATCH", "/api/v1/urls/golang", `{"url": "https://go.dev"}`)
+	if patchResp.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d but got %d", http.StatusOK, patchResp.Code)
+	}
+	redirectResp := do("GET", "/golang", "")
+	if location := redirectResp.Header().Get("Location"); location != "https://go.dev" {
+		t.Errorf("Expected redirect to https://go.dev but got %s", location)
+	}
+	
+	// Delete it
+	if resp := do("DELETE", "/api/v1/urls/golang", ""); resp.Code != http.StatusNoContent {
+		t.Errorf("Expected status code %d but got %d", http.StatusNoContent, resp.Code)
+	}
+	if resp := do("GET", "/api/v1/urls/golang", ""); resp.Code != http.StatusNotFound {
+		t.Errorf("Expected status code %d but got %d", http.StatusNotFound, resp.Code)
+	}
+	if resp := do("PATCH", "/api/v1/urls/golang", `{"url": "https://go.dev"}`); resp.Code != http.StatusNotFound {
+		t.Errorf("Expected status code %d but got %d", http.StatusNotFound, resp.Code)
+	}
+	if resp := do("DELETE", "/api/v1/urls/golang", ""); resp.Code != http.StatusNotFound {
+		t.Errorf("Expected status code %d but got %d", http.StatusNotFound, resp.Code)
+	}
+}
+
+func TestListURLs(t *testing.T) {
+	router := setupTestRouter()
+	
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+testAdminKey)
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		return resp
+	}
+	
+	for _, target := range []string{"https://github.com/golang/go", "https://go.dev/doc", "https://github.com/golang/tools"} {
+		if resp := do("POST", "/api/v1/urls", `{"url": "`+target+`"}`); resp.Code != http.StatusCreated {
+			t.Fatalf("Expected status code %d but got %d", http.StatusCreated, resp.Code)
+		}
+	}
+	
+	type page struct {
+		URLs []struct {
+			OriginalURL string `json:"original_url"`
+		} `json:"urls"`
+		NextCursor string `json:"next_cursor"`
+	}
+	
+	// Walk the github.com links one at a time, oldest first
+	var originals []string
+	path := "/api/v1/urls?domain=github.com&order=asc&limit=1"
+	for i := 0; i < 5; i++ {
+		resp := do("GET", path, "")
+		if resp.Code != http.StatusOK {
+			t.Fatalf("Expected status code %d but got %d", http.StatusOK, resp.Code)
+		}
+		var p page
+		json.Unmarshal(resp.Body.Bytes(), &p)
+		for _, u := range p.URLs {
+			originals = append(originals, u.OriginalURL)
+		}
+		if p.NextCursor == "" {
+			break
+		}
+		path = "/api/v1/urls?domain=github.com&order=asc&limit=1&after=" + p.NextCursor
+	}
+	if len(originals) != 2 || originals[0] != "https://github.com/golang/go" || originals[1] != "https://github.com/golang/tools" {
+		t.Errorf("Unexpected github.com links: %v", originals)
+	}
+	
+	// Newest first by default
+	resp := do("GET", "/api/v1/urls?q=GO.DEV", "")
+	var p page
+	json.Unmarshal(resp.Body.Bytes(), &p)
+	if len(p.URLs) != 1 || p.URLs[0].OriginalURL != "https://go.dev/doc" || p.NextCursor != "" {
+		t.Errorf("Unexpected search result: %s", resp.Body.String())
+	}
+	
+	for _, query := range []string{"after=bogus", "limit=0", "order=sideways", "from=yesterday"} {
+		if resp := do("GET", "/api/v1/urls?"+query, ""); resp.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status code %d but got %d", query, http.StatusBadRequest, resp.Code)
+		}
+	}
+}
+
+func TestAPIKeyAuth(t *testing.T) {
+	router := setupTestRouter()
+	
+	do := func(key, method, path, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		if key != "" {
+			req.Header.Set("Authorization", "Bearer "+key)
+		}
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		return resp
+	}
+	createKey := func(name string) (id, secret string) {
+		resp := do(testAdminKey, "POST", "/api/v1/keys", `{"name": "`+name+`"}`)
+		if resp.Code != http.StatusCreated {
+			t.Fatalf("Expected status code %d but got %d", http.StatusCreated, resp.Code)
+		}
+		var key map[string]interface{}
+		json.Unmarshal(resp.Body.Bytes(), &key)
+		id, _ = key["id"].(string)
+		secret, _ = key["key"].(string)
+		return id, secret
+	}
+	
+	// Anonymous and unknown keys are turned away
+	if resp := do("", "POST", "/api/v1/urls", `{"url": "https://github.com/golang/go"}`); resp.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status code %d but got %d", http.StatusUnauthorized, resp.Code)
+	}
+	if resp := do("bogus", "GET", "/api/v1/urls", ""); resp.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status code %d but got %d", http.StatusUnauthorized, resp.Code)
+	}
+	
+	aliceID, alice := createKey("alice")
+	_, bob := createKey("bob")
+	
+	// Only admins manage keys, and secrets are never listed
+	if resp := do(alice, "GET", "/api/v1/keys", ""); resp.Code != http.StatusForbidden {
+		t.Errorf("Expected status code %d but got %d", http.StatusForbidden, resp.Code)
+	}
+	listResp := do(testAdminKey, "GET", "/api/v1/keys", "")
+	if listResp.Code != http.StatusOK || bytes.Contains(listResp.Body.Bytes(), []byte(alice)) {
+		t.Errorf("Unexpected key listing: %d %s", listResp.Code, listResp.Body.String())
+	}
+	
+	// Alice's link is hers to manage
+	if resp := do(alice, "POST", "/api/v1/urls", `{"url": "https://github.com/golang/go", "alias": "golang"}`); resp.Code != http.StatusCreated {
+		t.Fatalf("Expected status code %d but got %d", http.StatusCreated, resp.Code)
+	}
+	if resp := do(alice, "GET", "/api/v1/urls/golang", ""); resp.Code != http.StatusOK {
+		t.Errorf("Expected status code %d but got %d", http.StatusOK, resp.Code)
+	}
+	for _, method := range []string{"GET", "PATCH", "DELETE"} {
+		if resp := do(bob, method, "/api/v1/urls/golang", `{"url": "https://go.dev"}`); resp.Code != http.StatusForbidden {
+			t.Errorf("%s: expected status code %d but got %d", method, http.StatusForbidden, resp.Code)
+		}
+	}
+	if resp := do(bob, "GET", "/api/v1/urls/golang/stats", ""); resp.Code != http.StatusForbidden {
+		t.Errorf("Expected status code %d but got %d", http.StatusForbidden, resp.Code)
+	}
+	
+	countURLs := func(key, query string) int {
+		resp := do(key, "GET", "/api/v1/urls"+query, "")
+		var page struct {
+			URLs []interface{} `json:"urls"`
+		}
+		json.Unmarshal(resp.Body.Bytes(), &page)
+		return len(page.URLs)
+	}
+	if n := countURLs(alice, ""); n != 1 {
+		t.Errorf("Expected alice to list 1 URL but got %d", n)
+	}
+	if n := countURLs(bob, ""); n != 0 {
+		t.Errorf("Expected bob to list no URLs but got %d", n)
+	}
+	if n := countURLs(bob, "?owner="+aliceID); n != 0 {
+		t.Errorf("Expected the owner filter to be ignored for bob but got %d URLs", n)
+	}
+	if n := countURLs(testAdminKey, "?owner="+aliceID); n != 1 {
+		t.Errorf("Expected the admin to list alice's URL but got %d", n)
+	}
+	
+	// Shortening the same URL gives bob a separate link, so alice retargeting
+	// the first one can't take over the one bob handed out
+	var bobLink map[string]interface{}
+	resp := do(bob, "POST", "/api/v1/urls", `{"url": "https://github.com/golang/go"}`)
+	json.Unmarshal(resp.Body.Bytes(), &bobLink)
+	if resp.Code != http.StatusCreated || bobLink["short_code"] == "golang" || bobLink["owner"] == aliceID {
+		t.Fatalf("Expected bob to get a separate link but got %d %v", resp.Code, bobLink)
+	}
+	if resp := do(alice, "PATCH", "/api/v1/urls/golang", `{"url": "https://evil.test/phish"}`); resp.Code != http.StatusOK {
+		t.Errorf("Expected alice to retarget her link but got %d", resp.Code)
+	}
+	resp = do("", "GET", "/"+bobLink["short_code"].(string), "")
+	if resp.Code != http.StatusMovedPermanently || resp.Header().Get("Location") != "https://github.com/golang/go" {
+		t.Errorf("Expected bob's link to keep its destination but got %d %s", resp.Code, resp.Header().Get("Location"))
+	}
+	
+	// Revoked keys stop working
+	if resp := do(testAdminKey, "DELETE", "/api/v1/keys/"+aliceID, ""); resp.Code != http.StatusNoContent {
+		t.Errorf("Expected status code %d but got %d", http.StatusNoContent, resp.Code)
+	}
+	if resp := do(alice, "GET", "/api/v1/urls/golang", ""); resp.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status code %d but got %d", http.StatusUnauthorized, resp.Code)
+	}
+}
+
+func TestRateLimits(t *testing.T) {
+	router := setupTestRouterWithConfig(api.RouterConfig{
+		CreateRateLimit:   middleware.RateLimit{PerMinute: 1, Burst: 2},
+		RedirectRateLimit: middleware.RateLimit{PerMinute: 1, Burst: 3},
+	}, service.ShortenerConfig{})
+	
+	create := func(target string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/api/v1/urls", bytes.NewBufferString(`{"url": "`+target+`"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+testAdminKey)
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		return resp
+	}
+	
+	first := create("https://github.com/golang/go")
+	if first.Code != http.StatusCreated {
+		t.Fatalf("Expected status code %d but got %d", http.StatusCreated, first.Code)
+	}
+	var link map[string]interface{}
+	json.Unmarshal(first.Body.Bytes(), &link)
+	shortCode, _ := link["short_code"].(string)
+	
+	if resp := create("https://go.dev"); resp.Code != http.StatusCreated {
+		t.Fatalf("Expected status code %d but got %d", http.StatusCreated, resp.Code)
+	}
+	limited := create("https://pkg.go.dev")
+	if limited.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected status code %d but got %d", http.StatusTooManyRequests, limited.Code)
+	}
+	if limited.Header().Get("Retry-After") == "" || limited.Header().Get("X-RateLimit-Remaining") != "0" {
+		t.Errorf("Expected Retry-After and X-RateLimit-Remaining headers but got %v", limited.Header())
+	}
+	
+	// The rest of the API shares the create limit
+	req, _ := http.NewRequest("GET", "/api/v1/urls/"+shortCode, nil)
+	req.Header.Set("Authorization", "Bearer "+testAdminKey)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	if resp.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected link management to share the spent limit but got %d", resp.Code)
+	}
+	
+	// Redirects have their own, separate limit, shared with QR codes and previews
+	for i := 0; i < 4; i++ {
+		path := "/" + shortCode
+		expected := http.StatusMovedPermanently
+		if i == 3 {
+			path += "/qr"
+			expected = http.StatusTooManyRequests
+		}
+		req, _ := http.NewRequest("GET", path, nil)
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		
+		if resp.Code != expected {
+			t.Errorf("Request %d: expected status code %d but got %d", i+1, expected, resp.Code)
+		}
+	}
+	
+	// Every URL of a batch counts against the create limit
+	router = setupTestRouterWithConfig(api.RouterConfig{
+		CreateRateLimit: middleware.RateLimit{PerMinute: 1, Burst: 3},
+	}, service.ShortenerConfig{})
+	batch := func() *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/api/v1/urls/batch", bytes.NewBufferString(`[{"url": "https://a.dev"}, {"url": "https://b.dev"}, {"url": "https://c.dev"}, {"url": "https://d.dev"}, {"url": "https://e.dev"}]`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+testAdminKey)
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		return resp
+	}
+	var results handlers.BatchResponse
+	json.Unmarshal(batch().Body.Bytes(), &results)
+	if results.Succeeded != 3 || results.Failed != 2 || results.Results[3].Status != http.StatusTooManyRequests || results.Results[4].Status != http.StatusTooManyRequests {
+		t.Errorf("Expected the URLs past the burst to be refused but got %+v", results)
+	}
+	if resp := batch(); resp.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected the next batch to be refused but got %d", resp.Code)
+	}
+	if resp := create("https://pkg.go.dev"); resp.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected single shortening to share the spent limit but got %d", resp.Code)
+	}
+}
+
+func TestDomainPolicy(t *testing.T) {
+	router := setupTestRouterWithConfig(api.RouterConfig{}, service.ShortenerConfig{
+		DomainPolicy: utils.NewDomainPolicy([]string{"example.com"}, nil),
+	})
+	
+	create := func(target string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/api/v1/urls", bytes.NewBufferString(`{"url": "`+target+`"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+testAdminKey)
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		return resp
+	}
+	
+	blocked := create("https://www.example.com/page")
+	if blocked.Code != http.StatusForbidden {
+		t.Fatalf("Expected status code %d but got %d", http.StatusForbidden, blocked.Code)
+	}
+	var response map[string]interface{}
+	json.Unmarshal(blocked.Body.Bytes(), &response)
+	if response["error"] != "Domain is not allowed" {
+		t.Errorf("Expected a domain error but got %v", response)
+	}
+	
+	if resp := create("https://notexample.com"); resp.Code != http.StatusCreated {
+		t.Errorf("Expected status code %d for an unlisted domain but got %d", http.StatusCreated, resp.Code)
+	}
+}
+
+func TestLoopProtection(t *testing.T) {
+	router := setupTestRouter()
+	
+	tests := []struct {
+		target   string
+		expected string
+	}{
+		{"http://localhost:3000/abc123", "Cannot shorten links to this service"},
+		{"https://bit.ly/3xYz", "URL is a short link of another shortener"},
+	}
+	
+	for _, tt := range tests {
+		req, _ := http.NewRequest("POST", "/api/v1/urls", bytes.NewBufferString(`{"url": "`+tt.target+`"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+testAdminKey)
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		
+		if resp.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status code %d but got %d", tt.target, http.StatusBadRequest, resp.Code)
+		}
+		var response map[string]interface{}
+		json.Unmarshal(resp.Body.Bytes(), &response)
+		if response["error"] != tt.expected {
+			t.Errorf("%s: expected error %q but got %v", tt.target, tt.expected, response["error"])
+		}
+	}
+}
+
+func TestBatchCreate(t *testing.T) {
+	router := setupTestRouterWithConfig(api.RouterConfig{
+		Batch: handlers.BatchConfig{MaxSize: 4, Concurrency: 2},
+	}, service.ShortenerConfig{})
+	
+	send := func(contentType, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/api/v1/urls/batch", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", contentType)
+		req.Header.Set("Authorization", "Bearer "+testAdminKey)
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		return resp
+	}
+	
+	resp := send("application/json", `[
+		{"url": "https://github.com/golang/go", "alias": "golang"},
+		{"url": "not a url"},
+		{"url": "https://go.dev", "expires_in": -5},
+		{"alias": "nourl"}
+	]`)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d but got %d", http.StatusOK, resp.Code)
+	}
+	var batch handlers.BatchResponse
+	if err := json.Unmarshal(resp.Body.Bytes(), &batch); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if batch.Succeeded != 1 || batch.Failed != 3 || len(batch.Results) != 4 {
+		t.Fatalf("Expected 1 success and 3 failures but got %+v", batch)
+	}
+	expected := []int{http.StatusCreated, http.StatusBadRequest, http.StatusBadRequest, http.StatusBadRequest}
+	for i, result := range batch.Results {
+		if result.Index != i || result.Status != expected[i] {
+			t.Errorf("Result %d: expected index %d with status %d but got %+v", i, i, expected[i], result)
+		}
+	}
+	if batch.Results[0].URLResponse == nil || batch.Results[0].ShortCode != "golang" {
+		t.Errorf("Expected the short URL in the first result but got %+v", batch.Results[0])
+	}
+	if batch.Results[1].Error != "Invalid URL format" {
+		t.Errorf("Expected an invalid URL error but got %q", batch.Results[1].Error)
+	}
+	
+	if resp := send("application/json", `[{"url": "https://a.dev"}, {"url": "https://b.dev"}, {"url": "https://c.dev"}, {"url": "https://d.dev"}, {"url": "https://e.dev"}]`); resp.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status code %d but got %d", http.StatusRequestEntityTooLarge, resp.Code)
+	}
+	if resp := send("application/json", `{"url": "https://a.dev"}`); resp.Code != http.StatusBadRequest {
+		t.Errorf("Expected status code %d for a non-array body but got %d", http.StatusBadRequest, resp.Code)
+	}
+	if resp := send("application/json", `[{"url": "https://a.dev/`+strings.Repeat("x", 64<<10)+`"}]`); resp.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status code %d for an oversized body but got %d", http.StatusRequestEntityTooLarge, resp.Code)
+	}
+	
+	// NDJSON gets one result per line, stopping at the maximum size
+	resp = send("application/x-ndjson", `{"url": "https://github.com/golang/go", "alias": "go-again"}
+{"url": "https://pkg.go.dev"}
+
+{"url": "https://go.dev/doc"}
+{"url": "https://go.dev/blog"}
+{"url": "https://go.dev/play"}
+`)
+	if resp.Code != http.StatusOK || resp.Header().Get("Content-Type") != "application/x-ndjson" {
+		t.Fatalf("Expected an NDJSON response but got %d %s", resp.Code, resp.Header().Get("Content-Type"))
+	}
+	statuses := make(map[int]int)
+	for _, line := range bytes.Split(bytes.TrimSpace(resp.Body.Bytes()), []byte("\n")) {
+		var result handlers.BatchResult
+		if err := json.Unmarshal(line, &result); err != nil {
+			t.Fatalf("Failed to parse result line %s: %v", line, err)
+		}
+		statuses[result.Index] = result.Status
+	}
+	expectedStatuses := map[int]int{
+		0: http.StatusConflict, // Already shortened as "golang"
+		1: http.StatusCreated,
+		2: http.StatusCreated,
+		3: http.StatusCreated,
+		4: http.StatusRequestEntityTooLarge,
+	}
+	if len(statuses) != len(expectedStatuses) {
+		t.Fatalf("Expected %d results but got %v", len(expectedStatuses), statuses)
+	}
+	for index, status := range expectedStatuses {
+		if statuses[index] != status {
+			t.Errorf("Line %d: expected status %d but got %d", index, status, statuses[index])
+		}
+	}
+}
+
+func TestPrometheusMetrics(t *testing.T) {
+	tel := telemetry.New()
+	urlStore := url.NewMemoryStorage()
+	router := setupTestRouterWithStorage(urlStore, api.RouterConfig{Telemetry: tel}, service.ShortenerConfig{Observer: tel})
+	
+	create := func(body string) string {
+		createReq, _ := http.NewRequest("POST", "/api/v1/urls", bytes.NewBufferString(body))
+		createReq.Header.Set("Content-Type", "application/json")
+		createReq.Header.Set("Authorization", "Bearer "+testAdminKey)
+		createResp := httptest.NewRecorder()
+		router.ServeHTTP(createResp, createReq)
+		var link map[string]interface{}
+		json.Unmarshal(createResp.Body.Bytes(), &link)
+		shortCode, _ := link["short_code"].(string)
+		return shortCode
+	}
+	shortCode := create(`{"url": "https://github.com/golang/go"}`)
+	
+	// Only the first visit is a redirect. The password form, the forced
+	// preview page and links that can't be followed yet or any more aren't counted.
+	guarded := create(`{"url": "https://go.dev/internal-doc", "password": "open sesame"}`)
+	previewed := create(`{"url": "https://go.dev/learn", "preview": true}`)
+	scheduled := create(`{"url": "https://go.dev/blog", "not_before": "` + time.Now().Add(time.Hour).Format(time.RFC3339) + `"}`)
+	expiredAt := time.Now().Add(-time.Minute)
+	expired := model.URL{ID: "gone01", ShortCode: "gone01", Original: "https://go.dev/play", CreatedAt: expiredAt.Add(-time.Hour), ExpiresAt: &expiredAt}
+	if err := urlStore.Save(context.Background(), expired); err != nil {
+		t.Fatalf("Failed to save URL: %v", err)
+	}
+	for _, path := range []string{"/" + shortCode, "/" + guarded, "/" + previewed, "/" + scheduled, "/gone01", "/nothere"} {
+		req, _ := http.NewRequest("GET", path, nil)
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	
+	req, _ := http.NewRequest("GET", "/metrics", nil)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d but got %d", http.StatusOK, resp.Code)
+	}
+	
+	body := resp.Body.String()
+	for _, expected := range []string{
+		"urlshortener_links_created_total 4",
+		"urlshortener_redirects_total 1",
+		`urlshortener_shortener_errors_total{operation="redirect",type="not_found"} 1`,
+		`urlshortener_http_requests_total{method="POST",route="/api/v1/urls",status="201"} 4`,
+		`urlshortener_http_requests_total{method="GET",route="/:shortCode",status="301"} 1`,
+		`urlshortener_http_requests_total{method="GET",route="/:shortCode",status="404"} 2`,
+		`urlshortener_http_requests_total{method="GET",route="/:shortCode",status="410"} 1`,
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("Expected metrics to contain %q", expected)
+		}
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	router := setupTestRouter()
+	
+	req, _ := http.NewRequest("GET", "/nothere", nil)
+	req.Header.Set("X-Request-ID", "trace-42")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	if got := resp.Header().Get("X-Request-ID"); got != "trace-42" {
+		t.Errorf("Expected the request ID to be echoed but got %q", got)
+	}
+	
+	req, _ = http.NewRequest("GET", "/health", nil)
+	resp = httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	if resp.Header().Get("X-Request-ID") == "" {
+		t.Error("Expected a request ID to be generated")
+	}
+}
+
+func TestRedirectStatus(t *testing.T) {
+	router := setupTestRouterWithConfig(api.RouterConfig{
+		Redirect: handlers.RedirectConfig{Status: http.StatusFound, CacheMaxAge: time.Hour},
+	}, service.ShortenerConfig{})
+	
+	create := func(body string) (int, map[string]interface{}) {
+		req, _ := http.NewRequest("POST", "/api/v1/urls", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+testAdminKey)
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		var result map[string]interface{}
+		json.Unmarshal(resp.Body.Bytes(), &result)
+		return resp.Code, result
+	}
+	follow := func(shortCode string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "/"+shortCode, nil)
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		return resp
+	}
+	
+	// Links without their own status use the configured default, uncached
+	_, link := create(`{"url": "https://go.dev/doc"}`)
+	resp := follow(link["short_code"].(string))
+	if resp.Code != http.StatusFound {
+		t.Errorf("Expected status code %d but got %d", http.StatusFound, resp.Code)
+	}
+	if resp.Header().Get("Cache-Control") != "no-store" {
+		t.Errorf("Expected temporary redirect not to be cached but got %q", resp.Header().Get("Cache-Control"))
+	}
+	if _, ok := link["redirect_status"]; ok {
+		t.Errorf("Expected no redirect_status for a link using the default but got %v", link)
+	}
+	
+	// A permanent redirect is cached, but not beyond the link's expiry
+	_, link = create(`{"url": "https://go.dev/blog", "redirect_status": 308, "expires_in": 600}`)
+	if link["redirect_status"] != float64(http.StatusPermanentRedirect) {
+		t.Errorf("Expected redirect_status 308 in response but got %v", link)
+	}
+	resp = follow(link["short_code"].(string))
+	if resp.Code != http.StatusPermanentRedirect {
+		t.Errorf("Expected status code %d but got %d", http.StatusPermanentRedirect, resp.Code)
+	}
+	cacheControl := resp.Header().Get("Cache-Control")
+	if cacheControl != "public, max-age=599" && cacheControl != "public, max-age=600" {
+		t.Errorf("Expected caching capped at the expiry but got %q", cacheControl)
+	}
+	if resp.Header().Get("Expires") == "" {
+		t.Error("Expected an Expires header")
+	}
+	
+	// Only redirect status codes are accepted
+	if code, _ := create(`{"url": "https://go.dev/play", "redirect_status": 303}`); code != http.StatusBadRequest {
+		t.Errorf("Expected status code %d but got %d", http.StatusBadRequest, code)
+	}
+}
+
+func TestQRCode(t *testing.T) {
+	router := setupTestRouter()
+	
+	createReq, _ := http.NewRequest("POST", "/api/v1/urls", bytes.NewBufferString(`{"url": "https://go.dev/tour"}`))
+	createReq.Header.Set("Content-Type", "application/json")
+	createReq.Header.Set("Authorization", "Bearer "+testAdminKey)
+	createResp := httptest.NewRecorder()
+	router.ServeHTTP(createResp, createReq)
+	var link map[string]interface{}
+	json.Unmarshal(createResp.Body.Bytes(), &link)
+	shortCode, _ := link["short_code"].(string)
+	
+	if link["qr_code_url"] != "http://localhost:3000/"+shortCode+"/qr" {
+		t.Errorf("Expected qr_code_url in response but got %v", link)
+	}
+	
+	tests := []struct {
+		query       string
+		status      int
+		contentType string
+	}{
+		{"", http.StatusOK, "image/png"},
+		{"?format=svg&size=512&level=h&margin=2&fg=%23336699&bg=fff", http.StatusOK, "image/svg+xml"},
+		{"?format=gif", http.StatusBadRequest, ""},
+		{"?size=big", http.StatusBadRequest, ""},
+		{"?fg=blue", http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest("GET", "/"+shortCode+"/qr"+tt.query, nil)
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		if resp.Code != tt.status {
+			t.Errorf("%q: expected status code %d but got %d", tt.query, tt.status, resp.Code)
+			continue
+		}
+		if tt.contentType != "" && resp.Header().Get("Content-Type") != tt.contentType {
+			t.Errorf("%q: expected %s but got %s", tt.query, tt.contentType, resp.Header().Get("Content-Type"))
+		}
+	}
+	
+	req, _ := http.NewRequest("GET", "/nothere/qr", nil)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	if resp.Code != http.StatusNotFound {
+		t.Errorf("Expected status code %d but got %d", http.StatusNotFound, resp.Code)
+	}
+}
+
+func TestLinkPreview(t *testing.T) {
+	router := setupTestRouter()
+	
+	create := func(body string) map[string]interface{} {
+		req, _ := http.NewRequest("POST", "/api/v1/urls", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+testAdminKey)
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		var result map[string]interface{}
+		json.Unmarshal(resp.Body.Bytes(), &result)
+		return result
+	}
+	get := func(path string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", path, nil)
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		return resp
+	}
+	
+	// The preview page shows the destination and continues through the short URL
+	link := create(`{"url": "https://192.0.2.10/files?name=<b>"}`)
+	shortCode, _ := link["short_code"].(string)
+	if link["preview_url"] != "http://localhost:3000/"+shortCode+"/preview" {
+		t.Errorf("Expected preview_url in response but got %v", link)
+	}
+	resp := get("/" + shortCode + "/preview")
+	if resp.Code != http.StatusOK || !strings.HasPrefix(resp.Header().Get("Content-Type"), "text/html") {
+		t.Fatalf("Expected an HTML page but got %d %s", resp.Code, resp.Header().Get("Content-Type"))
+	}
+	page := resp.Body.String()
+	for _, expected := range []string{"192.0.2.10", "IP address", `href="http://localhost:3000/` + shortCode + `"`} {
+		if !strings.Contains(page, expected) {
+			t.Errorf("Expected the preview page to contain %q", expected)
+		}
+	}
+	if strings.Contains(page, "<b>") {
+		t.Error("Expected the destination to be escaped")
+	}
+	
+	// Without the setting, visits still redirect straight away
+	if resp := get("/" + shortCode); resp.Code != http.StatusMovedPermanently {
+		t.Errorf("Expected status code %d but got %d", http.StatusMovedPermanently, resp.Code)
+	}
+	
+	// Links with the setting show the page on every visit, continuing to the destination
+	link = create(`{"url": "https://go.dev/learn", "preview": true}`)
+	shortCode, _ = link["short_code"].(string)
+	if link["preview"] != true {
+		t.Errorf("Expected preview in response but got %v", link)
+	}
+	resp = get("/" + shortCode)
+	if resp.Code != http.StatusOK || !strings.Contains(resp.Body.String(), `href="https://go.dev/learn"`) {
+		t.Errorf("Expected the preview page linking to the destination but got %d", resp.Code)
+	}
+	
+	if resp := get("/nothere/preview"); resp.Code != http.StatusNotFound {
+		t.Errorf("Expected status code %d but got %d", http.StatusNotFound, resp.Code)
+	}
+}
+
+func TestPasswordProtectedLink(t *testing.T) {
+	router := setupTestRouterWithConfig(api.RouterConfig{
+		Password: handlers.PasswordConfig{Attempts: middleware.RateLimit{PerMinute: 1, Burst: 3}},
+	}, service.ShortenerConfig{})
+	
+	createReq, _ := http.NewRequest("POST", "/api/v1/urls", bytes.NewBufferString(`{"url": "https://go.dev/internal-doc", "password": "open sesame"}`))
+	createReq.Header.Set("Content-Type", "application/json")
+	createReq.Header.Set("Authorization", "Bearer "+testAdminKey)
+	createResp := httptest.NewRecorder()
+	router.ServeHTTP(createResp, createReq)
+	var link map[string]interface{}
+	json.Unmarshal(createResp.Body.Bytes(), &link)
+	shortCode, _ := link["short_code"].(string)
+	if link["password_protected"] != true || strings.Contains(createResp.Body.String(), "open sesame") {
+		t.Fatalf("Expected a protected link without the password in the response but got %s", createResp.Body.String())
+	}
+	
+	submit := func(password, next string) *httptest.ResponseRecorder {
+		form := "password=" + password + "&next=" + next
+		req, _ := http.NewRequest("POST", "/"+shortCode, strings.NewReader(form))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		return resp
+	}
+	get := func(path string, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", path, nil)
+		for _, cookie := range cookies {
+			req.AddCookie(cookie)
+		}
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		return resp
+	}
+	
+	// Visits and previews get the form instead of the destination
+	for _, path := range []string{"/" + shortCode, "/" + shortCode + "/preview"} {
+		resp := get(path)
+		if resp.Code != http.StatusOK || resp.Header().Get("Location") != "" || strings.Contains(resp.Body.String(), "go.dev") {
+			t.Errorf("%s: expected the password form but got %d", path, resp.Code)
+		}
+		if !strings.Contains(resp.Body.String(), `type="password"`) {
+			t.Errorf("%s: expected a password field", path)
+		}
+	}
+	
+	if resp := submit("wrong", ""); resp.Code != http.StatusUnauthorized || !strings.Contains(resp.Body.String(), "Wrong password") {
+		t.Errorf("Expected a wrong password to be refused but got %d", resp.Code)
+	}
+	
+	// The right password sets a cookie that opens the link
+	resp := submit("open+sesame", "preview")
+	if resp.Code != http.StatusSeeOther || resp.Header().Get("Location") != "http://localhost:3000/"+shortCode+"/preview" {
+		t.Fatalf("Expected a redirect to the preview but got %d %s", resp.Code, resp.Header().Get("Location"))
+	}
+	cookies := resp.Result().Cookies()
+	if len(cookies) != 1 || !cookies[0].HttpOnly || cookies[0].Path != "/"+shortCode {
+		t.Fatalf("Expected an HTTP-only access cookie for the link but got %v", cookies)
+	}
+	resp = get("/"+shortCode, cookies[0])
+	if resp.Code != http.StatusMovedPermanently || resp.Header().Get("Location") != "https://go.dev/internal-doc" {
+		t.Errorf("Expected the cookie to open the link but got %d", resp.Code)
+	}
+	
+	// Nobody may cache the redirect, or it would open the link without the password
+	if cacheControl := resp.Header().Get("Cache-Control"); cacheControl != "no-store" {
+		t.Errorf("Expected the redirect not to be cached but got Cache-Control %q", cacheControl)
+	}
+	
+	// A tampered cookie doesn't
+	forged := *cookies[0]
+	forged.Value = strings.TrimSuffix(forged.Value, forged.Value[len(forged.Value)-2:]) + "xx"
+	if resp := get("/"+shortCode, &forged); resp.Code != http.StatusOK {
+		t.Errorf("Expected a forged cookie to get the form but got %d", resp.Code)
+	}
+	
+	// Attempts are throttled, even with the right password
+	submit("wrong", "")
+	resp = submit("open+sesame", "")
+	if resp.Code != http.StatusTooManyRequests || resp.Header().Get("Retry-After") == "" {
+		t.Errorf("Expected attempts to be throttled but got %d", resp.Code)
+	}
+}
+
+func TestClickLimitedLink(t *testing.T) {
+	router := setupTestRouter()
+	
+	create := func(body string) (int, map[string]interface{}) {
+		req, _ := http.NewRequest("POST", "/api/v1/urls", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+testAdminKey)
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		var result map[string]interface{}
+		json.Unmarshal(resp.Body.Bytes(), &result)
+		return resp.Code, result
+	}
+	get := func(path string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", path, nil)
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		return resp
+	}
+	
+	code, link := create(`{"url": "https://go.dev/dl/go.tar.gz", "max_clicks": 1}`)
+	if code != http.StatusCreated || link["max_clicks"] != float64(1) || link["clicks_remaining"] != float64(1) {
+		t.Fatalf("Expected a one-time link but got %d %v", code, link)
+	}
+	shortCode := link["short_code"].(string)
+	
+	// The QR code and preview don't spend the click
+	for _, path := range []string{"/" + shortCode + "/qr", "/" + shortCode + "/preview"} {
+		if resp := get(path); resp.Code != http.StatusOK {
+			t.Errorf("%s: expected status code %d but got %d", path, http.StatusOK, resp.Code)
+		}
+	}
+	
+	// Nor does the preview show where the link leads, even if the link always
+	// shows it; continuing goes through the short URL, spending the click
+	_, previewed := create(`{"url": "https://go.dev/dl/secret.tar.gz", "max_clicks": 1, "preview": true}`)
+	previewCode := previewed["short_code"].(string)
+	for i := 0; i < 3; i++ {
+		resp := get("/" + previewCode + "/preview")
+		if resp.Code != http.StatusOK || strings.Contains(resp.Body.String(), "go.dev") || !strings.Contains(resp.Body.String(), `href="http://localhost:3000/`+previewCode+`"`) {
+			t.Fatalf("Expected a preview hiding the destination but got %d %s", resp.Code, resp.Body.String())
+		}
+	}
+	if resp := get("/" + previewCode); resp.Code != http.StatusOK || !strings.Contains(resp.Body.String(), `href="https://go.dev/dl/secret.tar.gz"`) {
+		t.Errorf("Expected the click to show the destination but got %d", resp.Code)
+	}
+	if resp := get("/" + previewCode); resp.Code != http.StatusGone {
+		t.Errorf("Expected the link to be spent but got %d", resp.Code)
+	}
+	
+	// The only click redirects without letting clients cache it
+	resp := get("/" + shortCode)
+	if resp.Code != http.StatusMovedPermanently || resp.Header().Get("Location") != "https://go.dev/dl/go.tar.gz" {
+		t.Fatalf("Expected the first click to redirect but got %d", resp.Code)
+	}
+	if resp.Header().Get("Cache-Control") != "no-store" {
+		t.Errorf("Expected click-limited redirect not to be cached but got %q", resp.Header().Get("Cache-Control"))
+	}
+	
+	// After that the link is gone
+	for _, path := range []string{"/" + shortCode, "/" + shortCode + "/qr"} {
+		resp := get(path)
+		if resp.Code != http.StatusGone || !strings.Contains(resp.Body.String(), "Click limit reached") {
+			t.Errorf("%s: expected status code %d but got %d", path, http.StatusGone, resp.Code)
+		}
+	}
+	
+	// Managing the link shows the spent budget
+	req, _ := http.NewRequest("GET", "/api/v1/urls/"+shortCode, nil)
+	req.Header.Set("Authorization", "Bearer "+testAdminKey)
+	resp = httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	var stored map[string]interface{}
+	json.Unmarshal(resp.Body.Bytes(), &stored)
+	if stored["clicks_remaining"] != float64(0) {
+		t.Errorf("Expected no clicks remaining but got %v", stored)
+	}
+	
+	// A spent link frees its URL for a new one
+	if code, again := create(`{"url": "https://go.dev/dl/go.tar.gz", "max_clicks": 1}`); code != http.StatusCreated || again["short_code"] == shortCode {
+		t.Errorf("Expected a new one-time link but got %d %v", code, again)
+	}
+	
+	if code, _ := create(`{"url": "https://go.dev/dl/other.tar.gz", "max_clicks": -1}`); code != http.StatusBadRequest {
+		t.Errorf("Expected status code %d but got %d", http.StatusBadRequest, code)
+	}
+}
+
+func TestScheduledLink(t *testing.T) {
+	router := setupTestRouter()
+	
+	send := func(method, path, body string) (*httptest.ResponseRecorder, map[string]interface{}) {
+		req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+testAdminKey)
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		var result map[string]interface{}
+		json.Unmarshal(resp.Body.Bytes(), &result)
+		return resp, result
+	}
+	
+	launch := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	resp, link := send("POST", "/api/v1/urls", `{"url": "https://go.dev/campaign", "not_before": "`+launch+`"}`)
+	if resp.Code != http.StatusCreated || link["not_before"] != launch {
+		t.Fatalf("Expected a scheduled link but got %d %v", resp.Code, link)
+	}
+	shortCode := link["short_code"].(string)
+	
+	// Before launch visitors get a page saying when, and the QR code already works
+	resp, _ = send("GET", "/"+shortCode, "")
+	if resp.Code != http.StatusNotFound || resp.Header().Get("Location") != "" || resp.Header().Get("Retry-After") == "" {
+		t.Errorf("Expected the not yet active page but got %d", resp.Code)
+	}
+	if !strings.Contains(resp.Body.String(), "not active yet") || resp.Header().Get("Cache-Control") != "no-store" {
+		t.Errorf("Expected an uncached page saying the link isn't active yet but got %s", resp.Body.String())
+	}
+	if resp, _ := send("GET", "/"+shortCode+"/preview", ""); resp.Code != http.StatusNotFound || strings.Contains(resp.Body.String(), "go.dev/campaign") {
+		t.Errorf("Expected the preview not to give away the destination but got %d", resp.Code)
+	}
+	if resp, _ := send("GET", "/"+shortCode+"/qr", ""); resp.Code != http.StatusOK {
+		t.Errorf("Expected the QR code to work before launch but got %d", resp.Code)
+	}
+	
+	// Launching early and closing the window
+	closed := time.Now().Add(-time.Minute).UTC().Format(time.RFC3339)
+	resp, link = send("PATCH", "/api/v1/urls/"+shortCode, `{"not_before": null, "not_after": "`+closed+`"}`)
+	if resp.Code != http.StatusOK || link["not_before"] != nil || link["original_url"] != "https://go.dev/campaign" {
+		t.Fatalf("Expected the window to be moved but got %d %v", resp.Code, link)
+	}
+	if resp, _ := send("GET", "/"+shortCode, ""); resp.Code != http.StatusGone || !strings.Contains(resp.Body.String(), "no longer active") {
+		t.Errorf("Expected the closed page but got %d", resp.Code)
+	}
+	
+	// Reopened, it redirects again
+	if resp, _ := send("PATCH", "/api/v1/urls/"+shortCode, `{"not_after": null}`); resp.Code != http.StatusOK {
+		t.Fatalf("Expected the window to be opened but got %d", resp.Code)
+	}
+	if resp, _ := send("GET", "/"+shortCode, ""); resp.Code != http.StatusMovedPermanently {
+		t.Errorf("Expected status code %d but got %d", http.StatusMovedPermanently, resp.Code)
+	}
+	
+	if resp, _ := send("PATCH", "/api/v1/urls/"+shortCode, `{}`); resp.Code != http.StatusBadRequest {
+		t.Errorf("Expected an empty update to be refused but got %d", resp.Code)
+	}
+	if resp, _ := send("POST", "/api/v1/urls", `{"url": "https://go.dev/other", "not_before": "`+launch+`", "not_after": "`+closed+`"}`); resp.Code != http.StatusBadRequest {
+		t.Errorf("Expected a window ending before it starts to be refused but got %d", resp.Code)
+	}
+	
+	// A fallback URL replaces the page
+	router = setupTestRouterWithConfig(api.RouterConfig{
+		Redirect: handlers.RedirectConfig{InactiveURL: "https://example.com/coming-soon"},
+	}, service.ShortenerConfig{})
+	_, link = send("POST", "/api/v1/urls", `{"url": "https://go.dev/campaign", "not_before": "`+launch+`"}`)
+	req, _ := http.NewRequest("GET", "/"+link["short_code"].(string), nil)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,*/*;q=0.8")
+	resp = httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	if resp.Code != http.StatusFound || resp.Header().Get("Location") != "https://example.com/coming-soon" {
+		t.Errorf("Expected a redirect to the fallback URL but got %d %s", resp.Code, resp.Header().Get("Location"))
+	}
+}
+
+func TestFallbackURL(t *testing.T) {
+	router := setupTestRouterWithConfig(api.RouterConfig{
+		Redirect: handlers.RedirectConfig{FallbackURL: "https://example.com/missing"},
+	}, service.ShortenerConfig{})
+	
+	visit := func(path, accept string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", path, nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		return resp
+	}
+	const browser = "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8"
+	
+	// Browsers following an unknown code go to the service-wide fallback
+	resp := visit("/nope1234", browser)
+	if resp.Code != http.StatusFound || resp.Header().Get("Location") != "https://example.com/missing" || resp.Header().Get("Cache-Control") != "no-store" {
+		t.Errorf("Expected an uncached redirect to the fallback URL but got %d %s", resp.Code, resp.Header().Get("Location"))
+	}
+	
+	// API clients still get JSON, naming the fallback
+	resp = visit("/nope1234", "application/json")
+	var result map[string]interface{}
+	json.Unmarshal(resp.Body.Bytes(), &result)
+	if resp.Code != http.StatusNotFound || result["error"] != "URL not found" || result["fallback_url"] != "https://example.com/missing" {
+		t.Errorf("Expected a JSON 404 naming the fallback URL but got %d %v", resp.Code, result)
+	}
+	
+	// A link's own fallback wins once it has spent its clicks
+	req, _ := http.NewRequest("POST", "/api/v1/urls", bytes.NewBufferString(`{"url": "https://go.dev/dl/go.tar.gz", "max_clicks": 1, "fallback_url": "https://go.dev/dl/"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+testAdminKey)
+	created := httptest.NewRecorder()
+	router.ServeHTTP(created, req)
+	var link map[string]interface{}
+	json.Unmarshal(created.Body.Bytes(), &link)
+	if created.Code != http.StatusCreated || link["fallback_url"] != "https://go.dev/dl/" {
+		t.Fatalf("Expected a link with a fallback URL but got %d %v", created.Code, link)
+	}
+	shortCode := link["short_code"].(string)
+	
+	if resp := visit("/"+shortCode, browser); resp.Code != http.StatusMovedPermanently {
+		t.Fatalf("Expected the first click to redirect but got %d", resp.Code)
+	}
+	if resp := visit("/"+shortCode, browser); resp.Code != http.StatusFound || resp.Header().Get("Location") != "https://go.dev/dl/" {
+		t.Errorf("Expected a redirect to the link's fallback URL but got %d %s", resp.Code, resp.Header().Get("Location"))
+	}
+	resp = visit("/"+shortCode, "")
+	json.Unmarshal(resp.Body.Bytes(), &result)
+	if resp.Code != http.StatusGone || result["fallback_url"] != "https://go.dev/dl/" {
+		t.Errorf("Expected a JSON 410 naming the link's fallback URL but got %d %v", resp.Code, result)
+	}
+	
+	// Expired links keep sending browsers to their own fallback after the
+	// reaper has run, while expired links without one are purged
+	urlStore := url.NewMemoryStorage()
+	router = setupTestRouterWithStorage(urlStore, api.RouterConfig{}, service.ShortenerConfig{})
+	expiredAt := time.Now().Add(-time.Minute)
+	for _, link := range []model.URL{
+		{ID: "dlgone", ShortCode: "dlgone", Original: "https://go.dev/dl/go.tar.gz", CreatedAt: expiredAt.Add(-time.Hour), ExpiresAt: &expiredAt, FallbackURL: "https://go.dev/dl/"},
+		{ID: "plain1", ShortCode: "plain1", Original: "https://github.com", CreatedAt: expiredAt.Add(-time.Hour), ExpiresAt: &expiredAt},
+	} {
+		if err := urlStore.Save(context.Background(), link); err != nil {
+			t.Fatalf("Failed to save URL: %v", err)
+		}
+	}
+	if removed := url.NewReaper(urlStore, time.Minute, time.Hour).ReapOnce(context.Background()); removed != 1 {
+		t.Errorf("Expected the reaper to purge 1 URL but got %d", removed)
+	}
+	if resp := visit("/dlgone", browser); resp.Code != http.StatusFound || resp.Header().Get("Location") != "https://go.dev/dl/" {
+		t.Errorf("Expected a reaped link to still redirect to its fallback URL but got %d %s", resp.Code, resp.Header().Get("Location"))
+	}
+	if resp := visit("/plain1", ""); resp.Code != http.StatusNotFound {
+		t.Errorf("Expected status code %d for a purged link but got %d", http.StatusNotFound, resp.Code)
+	}
+	
+	// Shortening the URL of such a link again conflicts until the retention
+	// has passed, after which the link makes way for a new one
+	router = setupTestRouterWithStorage(urlStore, api.RouterConfig{}, service.ShortenerConfig{FallbackRetention: time.Hour})
+	longExpiredAt := time.Now().Add(-2 * time.Hour)
+	for _, link := range []model.URL{
+		{ID: "docold", ShortCode: "docold", Original: "https://go.dev/doc/install", CreatedAt: longExpiredAt.Add(-time.Hour), ExpiresAt: &longExpiredAt, Owner: service.BootstrapKeyID, FallbackURL: "https://go.dev/doc/"},
+		{ID: "docnew", ShortCode: "docnew", Original: "https://go.dev/doc/tutorial", CreatedAt: expiredAt.Add(-time.Hour), ExpiresAt: &expiredAt, Owner: service.BootstrapKeyID, FallbackURL: "https://go.dev/doc/"},
+	} {
+		if err := urlStore.Save(context.Background(), link); err != nil {
+			t.Fatalf("Failed to save URL: %v", err)
+		}
+	}
+	shorten := func(original string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/api/v1/urls", bytes.NewBufferString(`{"url": "`+original+`"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+testAdminKey)
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		return resp
+	}
+	if resp := shorten("https://go.dev/doc/tutorial"); resp.Code != http.StatusConflict {
+		t.Errorf("Expected status code %d for a URL whose link keeps its fallback but got %d", http.StatusConflict, resp.Code)
+	}
+	if resp := shorten("https://go.dev/doc/install"); resp.Code != http.StatusCreated || strings.Contains(resp.Body.String(), "docold") {
+		t.Errorf("Expected a new link for a URL whose link outlived the retention but got %d %s", resp.Code, resp.Body.String())
+	}
+	if _, err := urlStore.GetByShortCode(context.Background(), "docold"); err != url.ErrURLNotFound {
+		t.Errorf("Expected the replaced link to be gone but got: %v", err)
+	}
+
+	// Without any fallback browsers get a page saying why
+	router = setupTestRouter()
+	resp = visit("/nope1234", browser)
+	if resp.Code != http.StatusNotFound || !strings.HasPrefix(resp.Header().Get("Content-Type"), "text/html") || !strings.Contains(resp.Body.String(), "URL not found") {
+		t.Errorf("Expected a page saying the URL wasn't found but got %d %s", resp.Code, resp.Body.String())
+	}
+}
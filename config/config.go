@@ -2,16 +2,85 @@ package config
 
 import (
     "os"
+    "path/filepath"
     "strconv"
+    "strings"
+    "time"
 
     "github.com/joho/godotenv"
 )
 
+// Storage backends that can be selected with STORAGE_TYPE. File storage
+// persists URLs, API keys and the code counter; domain metrics and click
+// analytics stay in memory and are lost on restart.
+const (
+    StorageMemory = "memory"
+    StorageFile   = "file"
+    StorageSQLite = "sqlite"
+    StorageRedis  = "redis"
+)
+
 // Config holds all application configuration
 type Config struct {
     Port       string
     BaseURL    string
     CodeLength int
+
+    MaxCodeAttempts         int     // Short codes tried per request before giving up
+    CodeEscalationThreshold float64 // Fraction of the keyspace in use that triggers longer codes
+    CodeStrategy            string  // Short code generation strategy (random, counter, hashids, hash)
+    CodeAlphabet            string  // Characters for generated codes (empty for the strategy default)
+    CodeSalt                string  // Secret mixed into hashids and hash codes
+
+    StorageType       string // Storage backend to use (memory, file, sqlite, redis)
+    DataDir           string // Directory for file-based storage
+    SnapshotThreshold int    // WAL entries between snapshots for file-based storage
+    SQLitePath        string // Database file for SQLite storage
+
+    RedisAddr      string // Redis server address (host:port)
+    RedisPassword  string // Redis password, if any
+    RedisDB        int    // Redis database number
+    RedisKeyPrefix string // Prefix for all keys written to Redis
+
+    ReapInterval      time.Duration // How often expired links are purged from storage
+    FallbackRetention time.Duration // How long expired links with a fallback URL are kept before being purged
+
+    AdminAPIKey           string // Admin API key accepted without being stored (empty for none)
+    AllowAnonymousShorten bool   // Let requests without an API key create short URLs
+
+    TrustedProxies    []string // Proxy IPs or CIDRs whose X-Forwarded-For is believed
+    CreateRateLimit   float64  // Short URLs a client may create, or other API requests it may make, per minute (0 disables the limit)
+    CreateRateBurst   int      // Short URLs a client may create in a burst
+    RedirectRateLimit float64  // Redirects, QR codes and previews a client may fetch per minute (0 disables the limit)
+    RedirectRateBurst int      // Redirects a client may follow in a burst
+
+    DomainBlocklistFile  string        // File of domains that may not be shortened (empty for none)
+    DomainAllowlistFile  string        // File of the only domains that may be shortened (empty to allow all)
+    PolicyReloadInterval time.Duration // How often the domain policy files are checked for changes
+
+    SelfHostnames           []string      // Hostnames besides the BaseURL host that reach this service
+    ShortenerDomains        []string      // Other URL shorteners (empty for the built-in list)
+    ResolveShortLinks       bool          // Store the destination of other shorteners' links instead of refusing them
+    ShortLinkResolveTimeout time.Duration // How long resolving another shortener's link may take
+
+    BatchMaxSize     int           // URLs allowed in one batch request
+    BatchConcurrency int           // URLs of a batch shortened at once
+    BatchTimeout     time.Duration // Time allowed for a whole batch request
+
+    RedirectStatus      int           // Redirect status code of links without their own (301, 302, 307 or 308)
+    RedirectCacheMaxAge time.Duration // How long clients may cache permanent redirects
+    InactiveLinkURL     string        // Where links outside their activation window send visitors (FallbackURL if empty)
+    FallbackURL         string        // Where browsers go for links that can't be followed (a page saying why if empty)
+
+    LinkCookieSecret  string        // Key signing the cookies of password-protected links (random per process if empty)
+    LinkPasswordTTL   time.Duration // How long a correct link password is remembered
+    PasswordRateLimit float64       // Password attempts a client may make per link per minute
+    PasswordRateBurst int           // Password attempts a client may make per link in a burst
+
+    MetricsEnabled bool // Serve Prometheus metrics at /metrics
+
+    LogFormat string // Log output format (json, text)
+    LogLevel  string // Lowest level logged (debug, info, warn, error)
 }
 
 // Load loads configuration from environment variables
@@ -34,15 +103,244 @@ func Load() (*Config, error) {
         }
     }
     
+    // Get short code collision policy from environment or use defaults
+    maxCodeAttempts := 5
+    if val, err := strconv.Atoi(os.Getenv("MAX_CODE_ATTEMPTS")); err == nil && val > 0 {
+        maxCodeAttempts = val
+    }
+    codeEscalationThreshold := 0.1
+    if val, err := strconv.ParseFloat(os.Getenv("CODE_ESCALATION_THRESHOLD"), 64); err == nil && val > 0 && val <= 1 {
+        codeEscalationThreshold = val
+    }
+    
+    // Get short code generation strategy from environment or use default
+    codeStrategy := os.Getenv("CODE_STRATEGY")
+    if codeStrategy == "" {
+        codeStrategy = "random"
+    }
+    
     // Get port from environment or use default
     port := os.Getenv("PORT")
     if port == "" {
         port = "3000"
     }
     
+    // Get storage backend from environment or use default
+    storageType := os.Getenv("STORAGE_TYPE")
+    if storageType == "" {
+        storageType = StorageMemory
+    }
+    
+    // Get data directory from environment or use default
+    dataDir := os.Getenv("DATA_DIR")
+    if dataDir == "" {
+        dataDir = "./data"
+    }
+    
+    // Get snapshot threshold from environment or use default
+    snapshotThreshold := 1000 // Default
+    if val, err := strconv.Atoi(os.Getenv("SNAPSHOT_THRESHOLD")); err == nil && val > 0 {
+        snapshotThreshold = val
+    }
+    
+    // Get SQLite database path from environment or use default
+    sqlitePath := os.Getenv("SQLITE_PATH")
+    if sqlitePath == "" {
+        sqlitePath = filepath.Join(dataDir, "urlshortener.db")
+    }
+    
+    // Get Redis settings from environment or use defaults
+    redisAddr := os.Getenv("REDIS_ADDR")
+    if redisAddr == "" {
+        redisAddr = "localhost:6379"
+    }
+    redisDB := 0
+    if val, err := strconv.Atoi(os.Getenv("REDIS_DB")); err == nil && val >= 0 {
+        redisDB = val
+    }
+    redisKeyPrefix := os.Getenv("REDIS_KEY_PREFIX")
+    if redisKeyPrefix == "" {
+        redisKeyPrefix = "urlshortener"
+    }
+    
+    // Get expired link purge interval from environment or use default
+    reapInterval := time.Minute
+    if val, err := time.ParseDuration(os.Getenv("REAP_INTERVAL")); err == nil && val > 0 {
+        reapInterval = val
+    }
+    fallbackRetention := 7 * 24 * time.Hour
+    if val, err := time.ParseDuration(os.Getenv("FALLBACK_RETENTION")); err == nil && val > 0 {
+        fallbackRetention = val
+    }
+    
+    // Get API key settings from environment or use defaults
+    allowAnonymousShorten := false
+    if val, err := strconv.ParseBool(os.Getenv("ALLOW_ANONYMOUS_SHORTEN")); err == nil {
+        allowAnonymousShorten = val
+    }
+    
+    // Get rate limits from environment or use defaults
+    createRateLimit := 60.0
+    if val, err := strconv.ParseFloat(os.Getenv("CREATE_RATE_LIMIT"), 64); err == nil && val >= 0 {
+        createRateLimit = val
+    }
+    createRateBurst := 10
+    if val, err := strconv.Atoi(os.Getenv("CREATE_RATE_BURST")); err == nil && val > 0 {
+        createRateBurst = val
+    }
+    redirectRateLimit := 600.0
+    if val, err := strconv.ParseFloat(os.Getenv("REDIRECT_RATE_LIMIT"), 64); err == nil && val >= 0 {
+        redirectRateLimit = val
+    }
+    redirectRateBurst := 100
+    if val, err := strconv.Atoi(os.Getenv("REDIRECT_RATE_BURST")); err == nil && val > 0 {
+        redirectRateBurst = val
+    }
+    
+    // Get domain policy reload interval from environment or use default
+    policyReloadInterval := 30 * time.Second
+    if val, err := time.ParseDuration(os.Getenv("DOMAIN_POLICY_RELOAD_INTERVAL")); err == nil && val > 0 {
+        policyReloadInterval = val
+    }
+    
+    // Get redirect loop settings from environment or use defaults
+    resolveShortLinks := false
+    if val, err := strconv.ParseBool(os.Getenv("RESOLVE_SHORT_LINKS")); err == nil {
+        resolveShortLinks = val
+    }
+    shortLinkResolveTimeout := 5 * time.Second
+    if val, err := time.ParseDuration(os.Getenv("SHORT_LINK_RESOLVE_TIMEOUT")); err == nil && val > 0 {
+        shortLinkResolveTimeout = val
+    }
+    
+    // Get batch limits from environment or use defaults
+    batchMaxSize := 1000
+    if val, err := strconv.Atoi(os.Getenv("BATCH_MAX_SIZE")); err == nil && val > 0 {
+        batchMaxSize = val
+    }
+    batchConcurrency := 8
+    if val, err := strconv.Atoi(os.Getenv("BATCH_CONCURRENCY")); err == nil && val > 0 {
+        batchConcurrency = val
+    }
+    batchTimeout := 2 * time.Minute
+    if val, err := time.ParseDuration(os.Getenv("BATCH_TIMEOUT")); err == nil && val > 0 {
+        batchTimeout = val
+    }
+    
+    // Get redirect settings from environment or use defaults
+    redirectStatus := 301
+    if val, err := strconv.Atoi(os.Getenv("REDIRECT_STATUS")); err == nil {
+        switch val {
+        case 301, 302, 307, 308:
+            redirectStatus = val
+        }
+    }
+    redirectCacheMaxAge := 24 * time.Hour
+    if val, err := time.ParseDuration(os.Getenv("REDIRECT_CACHE_MAX_AGE")); err == nil && val > 0 {
+        redirectCacheMaxAge = val
+    }
+    
+    // Get password-protected link settings from environment or use defaults
+    linkPasswordTTL := time.Hour
+    if val, err := time.ParseDuration(os.Getenv("LINK_PASSWORD_TTL")); err == nil && val > 0 {
+        linkPasswordTTL = val
+    }
+    passwordRateLimit := 1.0
+    if val, err := strconv.ParseFloat(os.Getenv("PASSWORD_RATE_LIMIT"), 64); err == nil && val > 0 {
+        passwordRateLimit = val
+    }
+    passwordRateBurst := 5
+    if val, err := strconv.Atoi(os.Getenv("PASSWORD_RATE_BURST")); err == nil && val > 0 {
+        passwordRateBurst = val
+    }
+    
+    // Get Prometheus metrics setting from environment or use default
+    metricsEnabled := true
+    if val, err := strconv.ParseBool(os.Getenv("METRICS_ENABLED")); err == nil {
+        metricsEnabled = val
+    }
+    
+    // Get log settings from environment or use defaults
+    logFormat := os.Getenv("LOG_FORMAT")
+    if logFormat == "" {
+        logFormat = "json"
+    }
+    logLevel := os.Getenv("LOG_LEVEL")
+    if logLevel == "" {
+        logLevel = "info"
+    }
+    
     return &Config{
         Port:       port,
         BaseURL:    baseURL,
         CodeLength: codeLength,
+
+        MaxCodeAttempts:         maxCodeAttempts,
+        CodeEscalationThreshold: codeEscalationThreshold,
+        CodeStrategy:            codeStrategy,
+        CodeAlphabet:            os.Getenv("CODE_ALPHABET"),
+        CodeSalt:                os.Getenv("CODE_SALT"),
+
+        StorageType:       storageType,
+        DataDir:           dataDir,
+        SnapshotThreshold: snapshotThreshold,
+        SQLitePath:        sqlitePath,
+
+        RedisAddr:      redisAddr,
+        RedisPassword:  os.Getenv("REDIS_PASSWORD"),
+        RedisDB:        redisDB,
+        RedisKeyPrefix: redisKeyPrefix,
+
+        ReapInterval:      reapInterval,
+        FallbackRetention: fallbackRetention,
+
+        AdminAPIKey:           os.Getenv("ADMIN_API_KEY"),
+        AllowAnonymousShorten: allowAnonymousShorten,
+
+        TrustedProxies:    getList("TRUSTED_PROXIES"),
+        CreateRateLimit:   createRateLimit,
+        CreateRateBurst:   createRateBurst,
+        RedirectRateLimit: redirectRateLimit,
+        RedirectRateBurst: redirectRateBurst,
+
+        DomainBlocklistFile:  os.Getenv("DOMAIN_BLOCKLIST_FILE"),
+        DomainAllowlistFile:  os.Getenv("DOMAIN_ALLOWLIST_FILE"),
+        PolicyReloadInterval: policyReloadInterval,
+
+        SelfHostnames:           getList("SELF_HOSTNAMES"),
+        ShortenerDomains:        getList("SHORTENER_DOMAINS"),
+        ResolveShortLinks:       resolveShortLinks,
+        ShortLinkResolveTimeout: shortLinkResolveTimeout,
+
+        BatchMaxSize:     batchMaxSize,
+        BatchConcurrency: batchConcurrency,
+        BatchTimeout:     batchTimeout,
+
+        RedirectStatus:      redirectStatus,
+        RedirectCacheMaxAge: redirectCacheMaxAge,
+        InactiveLinkURL:     os.Getenv("INACTIVE_LINK_URL"),
+        FallbackURL:         os.Getenv("FALLBACK_URL"),
+
+        LinkCookieSecret:  os.Getenv("LINK_COOKIE_SECRET"),
+        LinkPasswordTTL:   linkPasswordTTL,
+        PasswordRateLimit: passwordRateLimit,
+        PasswordRateBurst: passwordRateBurst,
+
+        MetricsEnabled: metricsEnabled,
+
+        LogFormat: logFormat,
+        LogLevel:  logLevel,
     }, nil
+}
+
+// getList returns the comma-separated values of an environment variable,
+// skipping empty ones
+func getList(name string) []string {
+    var values []string
+    for _, value := range strings.Split(os.Getenv(name), ",") {
+        if value = strings.TrimSpace(value); value != "" {
+            values = append(values, value)
+        }
+    }
+    return values
 }
\ No newline at end of file
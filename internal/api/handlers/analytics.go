@@ -0,0 +1,56 @@
+package handlers
+
+import (
+    "net/http"
+    "strconv"
+
+    "github.com/gin-gonic/gin"
+    "github.com/gatij/goUrlShortener/internal/api/middleware"
+    "github.com/gatij/goUrlShortener/internal/service"
+)
+
+// AnalyticsHandler handles per-link analytics endpoints
+type AnalyticsHandler struct {
+    shortenerService *service.ShortenerService
+    analyticsService *service.AnalyticsService
+}
+
+// NewAnalyticsHandler creates a new analytics handler
+func NewAnalyticsHandler(shortenerService *service.ShortenerService, analyticsService *service.AnalyticsService) *AnalyticsHandler {
+    return &AnalyticsHandler{
+        shortenerService: shortenerService,
+        analyticsService: analyticsService,
+    }
+}
+
+// GetLinkStats returns click statistics for a short URL
+func (h *AnalyticsHandler) GetLinkStats(c *gin.Context) {
+    shortCode := c.Param("code")
+
+    // Stats are only served for links the caller manages. Expired links
+    // still have their history, so they are included.
+    caller, _ := middleware.APIKeyFrom(c)
+    link, err := h.shortenerService.GetLink(c.Request.Context(), caller, shortCode)
+    if err != nil {
+        respondLinkError(c, err, "Failed to retrieve URL")
+        return
+    }
+
+    days, err := strconv.Atoi(c.DefaultQuery("days", strconv.Itoa(service.DefaultStatsDays)))
+    if err != nil || days <= 0 {
+        days = service.DefaultStatsDays // Default if invalid
+    }
+    limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(service.DefaultStatsLimit)))
+    if err != nil || limit <= 0 {
+        limit = service.DefaultStatsLimit // Default if invalid
+    }
+
+    stats, err := h.analyticsService.GetLinkStats(c.Request.Context(), link, days, limit)
+    if err != nil {
+        c.Error(err)
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve link stats"})
+        return
+    }
+
+    c.JSON(http.StatusOK, stats)
+}
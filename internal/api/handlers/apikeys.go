@@ -0,0 +1,109 @@
+package handlers
+
+import (
+    "net/http"
+    "time"
+
+    "github.com/gin-gonic/gin"
+    "github.com/gatij/goUrlShortener/internal/model"
+    "github.com/gatij/goUrlShortener/internal/service"
+    "github.com/gatij/goUrlShortener/internal/storage/apikey"
+)
+
+// APIKeyRequest represents the request to create an API key
+type APIKeyRequest struct {
+    Name  string `json:"name" binding:"required"` // Label for the key
+    Scope string `json:"scope,omitempty"`         // "user" (default) or "admin"
+}
+
+// APIKeyResponse represents an API key. The secret is only included in the
+// response to creating the key.
+type APIKeyResponse struct {
+    ID        string    `json:"id"`
+    Name      string    `json:"name"`
+    Scope     string    `json:"scope"`
+    CreatedAt time.Time `json:"created_at"`
+    Key       string    `json:"key,omitempty"` // Secret to send in the Authorization header
+}
+
+// APIKeyHandler handles API key management endpoints
+type APIKeyHandler struct {
+    apiKeyService *service.APIKeyService
+}
+
+// NewAPIKeyHandler creates a new API key handler
+func NewAPIKeyHandler(apiKeyService *service.APIKeyService) *APIKeyHandler {
+    return &APIKeyHandler{
+        apiKeyService: apiKeyService,
+    }
+}
+
+// CreateAPIKey handles requests to issue a new API key
+func (h *APIKeyHandler) CreateAPIKey(c *gin.Context) {
+    var req APIKeyRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+        return
+    }
+
+    key, secret, err := h.apiKeyService.CreateKey(c.Request.Context(), req.Name, req.Scope)
+    if err != nil {
+        if err == service.ErrInvalidKeyName {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Name is required"})
+            return
+        }
+        if err == service.ErrInvalidScope {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Scope must be user or admin"})
+            return
+        }
+        c.Error(err)
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create API key"})
+        return
+    }
+
+    response := newAPIKeyResponse(key)
+    response.Key = secret
+    c.JSON(http.StatusCreated, response)
+}
+
+// ListAPIKeys returns every stored API key without their secrets
+func (h *APIKeyHandler) ListAPIKeys(c *gin.Context) {
+    keys, err := h.apiKeyService.ListKeys(c.Request.Context())
+    if err != nil {
+        c.Error(err)
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list API keys"})
+        return
+    }
+
+    response := make([]APIKeyResponse, 0, len(keys))
+    for _, key := range keys {
+        response = append(response, newAPIKeyResponse(key))
+    }
+
+    c.JSON(http.StatusOK, gin.H{"keys": response})
+}
+
+// DeleteAPIKey revokes an API key
+func (h *APIKeyHandler) DeleteAPIKey(c *gin.Context) {
+    if err := h.apiKeyService.RevokeKey(c.Request.Context(), c.Param("id")); err != nil {
+        if err == apikey.ErrKeyNotFound {
+            c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+            return
+        }
+        c.Error(err)
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke API key"})
+        return
+    }
+
+    c.Status(http.StatusNoContent)
+}
+
+// newAPIKeyResponse builds the API representation of a key
+func newAPIKeyResponse(key model.APIKey) APIKeyResponse {
+    return APIKeyResponse{
+        ID:        key.ID,
+        Name:      key.Name,
+        Scope:     key.Scope,
+        CreatedAt: key.CreatedAt,
+    }
+}
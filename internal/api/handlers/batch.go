@@ -0,0 +1,318 @@
+package handlers
+
+import (
+    "bufio"
+    "bytes"
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "math"
+    "mime"
+    "net/http"
+    "strings"
+    "sync"
+    "time"
+
+    "github.com/gin-gonic/gin"
+    "github.com/gatij/goUrlShortener/internal/api/middleware"
+    "github.com/gatij/goUrlShortener/internal/logging"
+    "github.com/gatij/goUrlShortener/internal/service"
+)
+
+const (
+    // DefaultBatchMaxSize is the most URLs a batch may hold
+    DefaultBatchMaxSize = 1000
+
+    // DefaultBatchConcurrency is how many URLs of a batch are shortened at once
+    DefaultBatchConcurrency = 8
+
+    // DefaultBatchTimeout bounds how long a batch may take
+    DefaultBatchTimeout = 2 * time.Minute
+
+    // maxBatchLineSize is the longest NDJSON line accepted
+    maxBatchLineSize = 1 << 20
+
+    // maxBatchItemSize is the room a JSON array body gets per URL of the
+    // largest batch, bounding the body before it is decoded
+    maxBatchItemSize = 16 << 10
+
+    // ndjsonContentType selects streaming, one request and one result per line
+    ndjsonContentType = "application/x-ndjson"
+)
+
+// BatchConfig contains settings for the batch endpoint
+type BatchConfig struct {
+    MaxSize     int           // URLs allowed in one batch
+    Concurrency int           // URLs of a batch shortened at once
+    Timeout     time.Duration // Time allowed for the whole batch
+
+    // Limiter is the per-client limit on creating short URLs, charged for
+    // every URL of a batch after the first, which the request itself paid
+    // for (unlimited if nil)
+    Limiter *middleware.RateLimiter
+}
+
+// BatchResult is the outcome of one URL of a batch. Status is the status code
+// POST /api/v1/urls would have answered with; the short URL is included on
+// success and when the URL was already shortened under another code.
+type BatchResult struct {
+    Index  int `json:"index"` // Position of the URL in the batch, from 0
+    Status int `json:"status"`
+    *URLResponse
+    Error   string `json:"error,omitempty"`
+    Message string `json:"message,omitempty"`
+}
+
+// BatchResponse represents the results of a batch, in the order of the request
+type BatchResponse struct {
+    Results   []BatchResult `json:"results"`
+    Succeeded int           `json:"succeeded"`
+    Failed    int           `json:"failed"`
+}
+
+// batchItem is a URL of a batch waiting to be shortened
+type batchItem struct {
+    index int
+    raw   []byte // The URLRequest as JSON
+
+    limited    bool          // The client's create limit refused the URL
+    retryAfter time.Duration // Until the limit allows another URL, if limited
+}
+
+// BatchHandler handles shortening many URLs in one request
+type BatchHandler struct {
+    shortener *ShortenerHandler
+    config    BatchConfig
+}
+
+// NewBatchHandler creates a new batch handler
+func NewBatchHandler(shortenerService *service.ShortenerService, config BatchConfig) *BatchHandler {
+    if config.MaxSize <= 0 {
+        config.MaxSize = DefaultBatchMaxSize
+    }
+    if config.Concurrency <= 0 {
+        config.Concurrency = DefaultBatchConcurrency
+    }
+    if config.Timeout <= 0 {
+        config.Timeout = DefaultBatchTimeout
+    }
+    return &BatchHandler{
+        shortener: NewShortenerHandler(shortenerService),
+        config:    config,
+    }
+}
+
+// CreateShortURLs handles requests to shorten a batch of URLs. The body is a
+// JSON array of URL requests, answered with all results at once, or NDJSON
+// with one URL request per line, answered with one result per line as soon
+// as each is done. A URL that fails doesn't affect the others.
+func (h *BatchHandler) CreateShortURLs(c *gin.Context) {
+    // Large batches outlast the server's usual timeouts
+    deadline := time.Now().Add(h.config.Timeout)
+    controller := http.NewResponseController(c.Writer)
+    controller.SetReadDeadline(deadline)
+    controller.SetWriteDeadline(deadline)
+    ctx, cancel := context.WithDeadline(c.Request.Context(), deadline)
+    defer cancel()
+
+    owner := ""
+    if key, ok := middleware.APIKeyFrom(c); ok {
+        owner = key.ID
+    }
+    client := middleware.ClientKey(c)
+
+    mediaType, _, _ := mime.ParseMediaType(c.GetHeader("Content-Type"))
+    if mediaType == ndjsonContentType {
+        h.streamBatch(ctx, c, controller, owner, client)
+        return
+    }
+
+    var raws []json.RawMessage
+    body := http.MaxBytesReader(c.Writer, c.Request.Body, int64(h.config.MaxSize)*maxBatchItemSize)
+    if err := json.NewDecoder(body).Decode(&raws); err != nil {
+        var tooLarge *http.MaxBytesError
+        if errors.As(err, &tooLarge) {
+            c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+                "error": "Batch too large",
+                "message": fmt.Sprintf("A batch body may be at most %d bytes.", tooLarge.Limit),
+            })
+            return
+        }
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: expected a JSON array of URL requests"})
+        return
+    }
+    if len(raws) == 0 {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Batch is empty"})
+        return
+    }
+    if len(raws) > h.config.MaxSize {
+        c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+            "error": "Batch too large",
+            "message": fmt.Sprintf("A batch may hold at most %d URLs.", h.config.MaxSize),
+        })
+        return
+    }
+
+    items := make(chan batchItem)
+    go func() {
+        defer close(items)
+        for i, raw := range raws {
+            items <- h.admit(client, batchItem{index: i, raw: raw})
+        }
+    }()
+
+    response := BatchResponse{Results: make([]BatchResult, len(raws))}
+    for result := range h.run(ctx, owner, items) {
+        response.Results[result.Index] = result
+        if result.Status < 300 {
+            response.Succeeded++
+        } else {
+            response.Failed++
+        }
+    }
+
+    c.JSON(http.StatusOK, response)
+}
+
+// streamBatch shortens the URLs of an NDJSON body, writing each result as a
+// line as soon as it is ready, so results may be out of order. Reading stops
+// after the maximum batch size with a final 413 result.
+func (h *BatchHandler) streamBatch(ctx context.Context, c *gin.Context, controller *http.ResponseController, owner, client string) {
+    // Results are written while the body is still being read
+    controller.EnableFullDuplex()
+
+    items := make(chan batchItem)
+    extra := make(chan BatchResult, 1) // Result for a problem with the body itself
+    go func() {
+        defer close(items)
+        defer close(extra)
+
+        scanner := bufio.NewScanner(c.Request.Body)
+        scanner.Buffer(make([]byte, 0, 64*1024), maxBatchLineSize)
+        index := 0
+        for scanner.Scan() {
+            line := bytes.TrimSpace(scanner.Bytes())
+            if len(line) == 0 {
+                continue
+            }
+            if index == h.config.MaxSize {
+                extra <- BatchResult{
+                    Index:   index,
+                    Status:  http.StatusRequestEntityTooLarge,
+                    Error:   "Batch too large",
+                    Message: fmt.Sprintf("A batch may hold at most %d URLs, the rest were ignored.", h.config.MaxSize),
+                }
+                return
+            }
+            select {
+            case items <- h.admit(client, batchItem{index: index, raw: append([]byte(nil), line...)}):
+            case <-ctx.Done():
+                return
+            }
+            index++
+        }
+        if err := scanner.Err(); err != nil {
+            extra <- BatchResult{Index: index, Status: http.StatusBadRequest, Error: "Invalid request: " + err.Error()}
+        }
+    }()
+
+    c.Header("Content-Type", ndjsonContentType)
+    c.Status(http.StatusOK)
+    encoder := json.NewEncoder(c.Writer)
+    write := func(result BatchResult) {
+        if err := encoder.Encode(result); err != nil {
+            logging.FromContext(ctx).Warn("Failed to write batch result", "error", err)
+        }
+        c.Writer.Flush()
+    }
+
+    for result := range h.run(ctx, owner, items) {
+        write(result)
+    }
+    for result := range extra {
+        write(result)
+    }
+}
+
+// admit charges client's create limit for item, unless it is the first of
+// its batch, marking it limited if the limit refuses
+func (h *BatchHandler) admit(client string, item batchItem) batchItem {
+    if h.config.Limiter == nil || item.index == 0 {
+        return item
+    }
+    if allowed, retryAfter := h.config.Limiter.Allow(client); !allowed {
+        item.limited = true
+        item.retryAfter = retryAfter
+    }
+    return item
+}
+
+// run shortens items with at most Concurrency at a time. The returned channel
+// receives every result and is closed once items is drained.
+func (h *BatchHandler) run(ctx context.Context, owner string, items <-chan batchItem) <-chan BatchResult {
+    results := make(chan BatchResult)
+
+    var wg sync.WaitGroup
+    for i := 0; i < h.config.Concurrency; i++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            for item := range items {
+                results <- h.shorten(ctx, owner, item)
+            }
+        }()
+    }
+    go func() {
+        wg.Wait()
+        close(results)
+    }()
+
+    return results
+}
+
+// shorten creates the short URL for one item of a batch
+func (h *BatchHandler) shorten(ctx context.Context, owner string, item batchItem) BatchResult {
+    result := BatchResult{Index: item.index}
+    fail := func(e apiError) BatchResult {
+        result.Status, result.Error, result.Message = e.status, e.error, e.message
+        return result
+    }
+
+    if item.limited {
+        return fail(apiError{http.StatusTooManyRequests, "Rate limit exceeded",
+            fmt.Sprintf("Too many URLs shortened, retry this one after %d seconds.", int(math.Ceil(item.retryAfter.Seconds())))})
+    }
+
+    var req URLRequest
+    if err := json.Unmarshal(item.raw, &req); err != nil {
+        return fail(apiError{http.StatusBadRequest, "Invalid request: " + err.Error(), ""})
+    }
+    if strings.TrimSpace(req.URL) == "" {
+        return fail(apiError{http.StatusBadRequest, "Invalid request: url is required", ""})
+    }
+    opts, err := createOptions(req, time.Now())
+    if err != nil {
+        return fail(apiError{http.StatusBadRequest, err.Error(), ""})
+    }
+    opts.Owner = owner
+
+    url, err := h.shortener.shortenerService.CreateShortURL(ctx, req.URL, opts)
+    if err != nil {
+        if err == service.ErrURLAlreadyShortened {
+            // Report the existing short code of the URL
+            response := h.shortener.newURLResponse(url)
+            result.URLResponse = &response
+        }
+        e := createError(err)
+        if e.status == http.StatusInternalServerError {
+            logging.FromContext(ctx).Error("Failed to shorten batch URL", "index", item.index, "error", err)
+        }
+        return fail(e)
+    }
+
+    response := h.shortener.newURLResponse(url)
+    result.URLResponse = &response
+    result.Status = http.StatusCreated
+    return result
+}
@@ -30,6 +30,7 @@ func (h *MetricsHandler) GetTopDomains(c *gin.Context) {
 
     domains, err := h.metricsService.GetTopDomains(c.Request.Context(), limit)
     if err != nil {
+        c.Error(err)
         c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve top domains"})
         return
     }
@@ -38,4 +39,8 @@ func (h *MetricsHandler) GetTopDomains(c *gin.Context) {
         "top_domains": domains,
         "limit":       limit,
     })
-}
\ No newline at end of file
+}
+// GetShortCodeStats returns how often generated short codes collided
+func (h *MetricsHandler) GetShortCodeStats(c *gin.Context) {
+    c.JSON(http.StatusOK, h.metricsService.GetShortCodeStats())
+}
@@ -0,0 +1,199 @@
+package handlers
+
+import (
+    "bytes"
+    "crypto/hmac"
+    "crypto/rand"
+    "crypto/sha256"
+    "encoding/base64"
+    "fmt"
+    "html/template"
+    "math"
+    "net/http"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/gin-gonic/gin"
+    "github.com/gatij/goUrlShortener/internal/api/middleware"
+    "github.com/gatij/goUrlShortener/internal/model"
+)
+
+const (
+    // DefaultPasswordTTL is how long a correct link password is remembered
+    DefaultPasswordTTL = time.Hour
+
+    // accessCookieName is the cookie proving the password of a link was
+    // entered. It is scoped to the link's path, so each link has its own.
+    accessCookieName = "link_access"
+)
+
+// DefaultPasswordAttempts allows a client five password attempts per link at
+// once, then one more a minute
+var DefaultPasswordAttempts = middleware.RateLimit{PerMinute: 1, Burst: 5}
+
+// passwordTemplate is the form asking for the password of a link
+var passwordTemplate = template.Must(template.New("password").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>Password required</title>
+<style>
+body { font-family: system-ui, sans-serif; max-width: 30rem; margin: 3rem auto; padding: 0 1rem; color: #222; }
+.error { padding: .75rem; background: #fdecea; border-left: .25rem solid #d93025; }
+input[type=password] { display: block; width: 100%; box-sizing: border-box; margin: .5rem 0 1rem; padding: .5rem; }
+button { padding: .6rem 1.2rem; background: #1a5fb4; color: #fff; border: 0; border-radius: .25rem; }
+</style>
+</head>
+<body>
+<h1>Password required</h1>
+<p>{{.ShortURL}} is protected. Enter its password to continue.</p>
+{{- if .Message}}
+<p class="error">{{.Message}}</p>
+{{- end}}
+<form method="post" action="{{.ShortURL}}">
+<input type="hidden" name="next" value="{{.Next}}">
+<label>Password <input type="password" name="password" required autofocus autocomplete="current-password"></label>
+<button type="submit">Continue</button>
+</form>
+</body>
+</html>
+`))
+
+// passwordPage holds what the password form shows
+type passwordPage struct {
+    ShortURL string
+    Next     string // Page to go on to: empty for the link itself or "preview"
+    Message  string // Why the last attempt failed
+}
+
+// PasswordConfig contains settings for password-protected links
+type PasswordConfig struct {
+    Secret   []byte               // Key signing access cookies (random per process if empty)
+    TTL      time.Duration        // How long a correct password is remembered
+    Attempts middleware.RateLimit // Password attempts allowed per client and link
+}
+
+// PasswordGate keeps visitors out of password-protected links until they
+// enter the password. A correct password is remembered with a signed cookie
+// for TTL, and attempts are throttled per client and link.
+type PasswordGate struct {
+    secret   []byte
+    ttl      time.Duration
+    attempts *middleware.RateLimiter
+    now      func() time.Time // Clock, replaceable in tests
+}
+
+// NewPasswordGate creates a password gate. Without a secret one is generated,
+// so cookies are only accepted by this process until it restarts.
+func NewPasswordGate(config PasswordConfig) *PasswordGate {
+    if len(config.Secret) == 0 {
+        config.Secret = make([]byte, 32)
+        rand.Read(config.Secret)
+    }
+    if config.TTL <= 0 {
+        config.TTL = DefaultPasswordTTL
+    }
+    if !config.Attempts.Enabled() {
+        config.Attempts = DefaultPasswordAttempts
+    }
+    return &PasswordGate{
+        secret:   config.Secret,
+        ttl:      config.TTL,
+        attempts: middleware.NewRateLimiter(config.Attempts),
+        now:      time.Now,
+    }
+}
+
+// granted reports whether the request carries a valid access cookie for link
+func (g *PasswordGate) granted(c *gin.Context, link model.URL) bool {
+    value, err := c.Cookie(accessCookieName)
+    if err != nil {
+        return false
+    }
+    expiry, signature, found := strings.Cut(value, ".")
+    if !found {
+        return false
+    }
+    expiresAt, err := strconv.ParseInt(expiry, 10, 64)
+    if err != nil || g.now().Unix() >= expiresAt {
+        return false
+    }
+    return hmac.Equal([]byte(signature), []byte(g.sign(link, expiresAt)))
+}
+
+// grant sets the access cookie for link, scoped to its short URL
+func (g *PasswordGate) grant(c *gin.Context, link model.URL, shortURL string) {
+    expiresAt := g.now().Add(g.ttl).Unix()
+    c.SetSameSite(http.SameSiteLaxMode)
+    c.SetCookie(accessCookieName, fmt.Sprintf("%d.%s", expiresAt, g.sign(link, expiresAt)),
+        int(g.ttl.Seconds()), "/"+link.ShortCode, "", strings.HasPrefix(shortURL, "https://"), true)
+}
+
+// sign returns the signature of an access cookie for link that expires at
+// expiresAt. The password hash is signed too, so a new password locks out
+// visitors who only knew the old one.
+func (g *PasswordGate) sign(link model.URL, expiresAt int64) string {
+    mac := hmac.New(sha256.New, g.secret)
+    fmt.Fprintf(mac, "%s\n%d\n%s", link.ShortCode, expiresAt, link.PasswordHash)
+    return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// allowAttempt spends one of the client's password attempts for link. If
+// none is left it returns false and how long until the next one.
+func (g *PasswordGate) allowAttempt(c *gin.Context, link model.URL) (bool, time.Duration) {
+    return g.attempts.Allow(c.ClientIP() + " " + link.ShortCode)
+}
+
+// renderPasswordForm writes the password form of the link at shortURL
+func renderPasswordForm(c *gin.Context, status int, shortURL, next, message string) {
+    var buf bytes.Buffer
+    if err := passwordTemplate.Execute(&buf, passwordPage{ShortURL: shortURL, Next: next, Message: message}); err != nil {
+        c.Error(err)
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render password form"})
+        return
+    }
+
+    c.Header("Cache-Control", "no-store")
+    c.Data(status, "text/html; charset=utf-8", buf.Bytes())
+}
+
+// SubmitPassword checks the password entered for a protected link. A correct
+// password sets the access cookie and goes back to the link, or to its
+// preview page if that is where the visitor came from.
+func (h *RedirectHandler) SubmitPassword(c *gin.Context) {
+    link, err := h.shortenerService.GetActiveURL(c.Request.Context(), c.Param("shortCode"))
+    if err != nil {
+        respondActiveLinkError(c, err, "Failed to retrieve URL")
+        return
+    }
+
+    shortURL := h.shortenerService.GenerateShortURL(link.ShortCode)
+    next := ""
+    if c.PostForm("next") == "preview" {
+        next = "preview"
+    }
+
+    if link.HasPassword() {
+        if allowed, retryAfter := h.passwords.allowAttempt(c, link); !allowed {
+            seconds := int(math.Ceil(retryAfter.Seconds()))
+            c.Header("Retry-After", strconv.Itoa(seconds))
+            renderPasswordForm(c, http.StatusTooManyRequests, shortURL, next,
+                fmt.Sprintf("Too many attempts. Try again in %d seconds.", seconds))
+            return
+        }
+        if !h.shortenerService.CheckPassword(link, c.PostForm("password")) {
+            renderPasswordForm(c, http.StatusUnauthorized, shortURL, next, "Wrong password.")
+            return
+        }
+        h.passwords.grant(c, link, shortURL)
+    }
+
+    target := shortURL
+    if next != "" {
+        target += "/" + next
+    }
+    c.Redirect(http.StatusSeeOther, target)
+}
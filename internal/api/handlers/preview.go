@@ -0,0 +1,156 @@
+package handlers
+
+import (
+    "bytes"
+    "html/template"
+    "net/http"
+    neturl "net/url"
+    "time"
+
+    "github.com/gin-gonic/gin"
+    "github.com/gatij/goUrlShortener/internal/model"
+    "github.com/gatij/goUrlShortener/internal/service"
+)
+
+// previewTemplate is the page showing where a short URL leads
+var previewTemplate = template.Must(template.New("preview").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>Link preview{{if .Domain}}: {{.Domain}}{{end}}</title>
+<style>
+body { font-family: system-ui, sans-serif; max-width: 40rem; margin: 3rem auto; padding: 0 1rem; color: #222; }
+.destination { word-break: break-all; padding: .75rem; background: #f4f4f4; border-radius: .25rem; }
+.warnings { padding: .75rem 1rem .75rem 2rem; background: #fff4e5; border-left: .25rem solid #f0a020; }
+.continue { display: inline-block; margin-top: 1rem; padding: .6rem 1.2rem; background: #1a5fb4; color: #fff; border-radius: .25rem; text-decoration: none; }
+dt { font-weight: bold; margin-top: .5rem; }
+</style>
+</head>
+<body>
+<h1>Where this link goes</h1>
+{{- if .Destination}}
+<p>{{.ShortURL}} leads to:</p>
+<p class="destination">{{.Destination}}</p>
+{{- else}}
+<p>{{.ShortURL}} can only be followed a limited number of times, so where it leads is only shown once you continue, which uses up one of them.</p>
+{{- end}}
+<dl>
+{{- if .Domain}}
+<dt>Domain</dt><dd>{{.Domain}}</dd>
+{{- end}}
+<dt>Created</dt><dd>{{.CreatedAt.Format "2 January 2006"}}</dd>
+{{- if .ExpiresAt}}
+<dt>Expires</dt><dd>{{.ExpiresAt.Format "2 January 2006 15:04 MST"}}</dd>
+{{- end}}
+</dl>
+{{- if .Warnings}}
+<ul class="warnings">
+{{- range .Warnings}}
+<li>{{.}}</li>
+{{- end}}
+</ul>
+{{- else if .Destination}}
+<p>Nothing unusual was found about this address, but only continue if you trust it.</p>
+{{- end}}
+<a class="continue" href="{{.ContinueURL}}" rel="noreferrer">Continue{{if .Domain}} to {{.Domain}}{{end}}</a>
+</body>
+</html>
+`))
+
+// previewPage holds what the preview page shows
+type previewPage struct {
+    ShortURL    string
+    Destination string // Empty while it is hidden
+    Domain      string
+    CreatedAt   time.Time
+    ExpiresAt   *time.Time
+    Warnings    []string
+    ContinueURL string // Where the continue button leads
+}
+
+// PreviewHandler handles preview pages of short URLs
+type PreviewHandler struct {
+    shortenerService *service.ShortenerService
+    passwords        *PasswordGate
+}
+
+// NewPreviewHandler creates a new preview handler. The destination of
+// password-protected links is only shown once passwords lets the visitor in.
+func NewPreviewHandler(shortenerService *service.ShortenerService, passwords *PasswordGate) *PreviewHandler {
+    return &PreviewHandler{
+        shortenerService: shortenerService,
+        passwords:        passwords,
+    }
+}
+
+// GetPreview renders a page showing where a short URL leads, without
+// following it. Continuing goes through the short URL, so the click is
+// counted, unless the link always shows this page, in which case it goes
+// straight to the destination. Click-limited links only show their
+// destination once a click is spent, so the page hides it and always
+// continues through the short URL.
+func (h *PreviewHandler) GetPreview(c *gin.Context) {
+    link, err := h.shortenerService.GetActiveURL(c.Request.Context(), c.Param("shortCode"))
+    if err != nil {
+        respondActiveLinkError(c, err, "Failed to retrieve URL")
+        return
+    }
+
+    // Don't give away where a link leads before it launches
+    if err := service.CheckSchedule(link, time.Now()); err != nil {
+        renderInactivePage(c, h.shortenerService, link, err)
+        return
+    }
+
+    continueURL := h.shortenerService.GenerateShortURL(link.ShortCode)
+    if link.HasPassword() && !h.passwords.granted(c, link) {
+        renderPasswordForm(c, http.StatusOK, continueURL, "preview", "")
+        return
+    }
+    if link.MaxClicks > 0 {
+        writePreview(c, previewPage{
+            ShortURL:    continueURL,
+            CreatedAt:   link.CreatedAt,
+            ExpiresAt:   link.ExpiresAt,
+            ContinueURL: continueURL,
+        })
+        return
+    }
+    if link.Preview {
+        continueURL = link.Original
+    }
+    renderPreview(c, h.shortenerService, link, continueURL)
+}
+
+// renderPreview writes the preview page of link with a button to continueURL
+func renderPreview(c *gin.Context, shortenerService *service.ShortenerService, link model.URL, continueURL string) {
+    page := previewPage{
+        ShortURL:    shortenerService.GenerateShortURL(link.ShortCode),
+        Destination: link.Original,
+        Domain:      link.Original,
+        CreatedAt:   link.CreatedAt,
+        ExpiresAt:   link.ExpiresAt,
+        Warnings:    shortenerService.LinkWarnings(link),
+        ContinueURL: continueURL,
+    }
+    if parsed, err := neturl.Parse(link.Original); err == nil {
+        page.Domain = parsed.Host
+    }
+    writePreview(c, page)
+}
+
+// writePreview writes a preview page
+func writePreview(c *gin.Context, page previewPage) {
+    var buf bytes.Buffer
+    if err := previewTemplate.Execute(&buf, page); err != nil {
+        c.Error(err)
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render preview"})
+        return
+    }
+
+    // The page may change when the link is retargeted
+    c.Header("Cache-Control", "no-store")
+    c.Data(http.StatusOK, "text/html; charset=utf-8", buf.Bytes())
+}
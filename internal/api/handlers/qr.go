@@ -0,0 +1,117 @@
+package handlers
+
+import (
+    "fmt"
+    "net/http"
+    "strconv"
+    "strings"
+
+    "github.com/gin-gonic/gin"
+    "github.com/gatij/goUrlShortener/internal/service"
+    "github.com/gatij/goUrlShortener/internal/storage/url"
+    "github.com/gatij/goUrlShortener/pkg/utils"
+)
+
+// QRHandler handles QR codes of short URLs
+type QRHandler struct {
+    shortenerService *service.ShortenerService
+}
+
+// NewQRHandler creates a new QR code handler
+func NewQRHandler(shortenerService *service.ShortenerService) *QRHandler {
+    return &QRHandler{
+        shortenerService: shortenerService,
+    }
+}
+
+// GetQRCode renders a QR code of a short URL. The query parameters format
+// (png or svg), size (pixels), level (L, M, Q or H), margin (modules) and
+// fg and bg (hex colors) override the defaults.
+func (h *QRHandler) GetQRCode(c *gin.Context) {
+    opts, err := qrOptions(c)
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid QR code options", "message": err.Error()})
+        return
+    }
+
+    link, err := h.shortenerService.GetActiveURL(c.Request.Context(), c.Param("shortCode"))
+    if err != nil {
+        respondActiveLinkError(c, err, "Failed to retrieve URL")
+        return
+    }
+
+    image, contentType, err := utils.RenderQRCode(h.shortenerService.GenerateShortURL(link.ShortCode), opts)
+    if err != nil {
+        c.Error(err)
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render QR code"})
+        return
+    }
+
+    // The short URL of a code never changes
+    c.Header("Cache-Control", "public, max-age=86400")
+    c.Data(http.StatusOK, contentType, image)
+}
+
+// qrOptions reads the QR code options of a request, using the defaults for
+// parameters that are absent
+func qrOptions(c *gin.Context) (utils.QROptions, error) {
+    opts := utils.DefaultQROptions()
+    if format := c.Query("format"); format != "" {
+        opts.Format = strings.ToLower(format)
+    }
+    if level := c.Query("level"); level != "" {
+        opts.Level = strings.ToUpper(level)
+    }
+
+    var err error
+    if opts.Size, err = intQuery(c, "size", opts.Size); err != nil {
+        return opts, err
+    }
+    if opts.Margin, err = intQuery(c, "margin", opts.Margin); err != nil {
+        return opts, err
+    }
+    if fg := c.Query("fg"); fg != "" {
+        if opts.Foreground, err = utils.ParseHexColor(fg); err != nil {
+            return opts, err
+        }
+    }
+    if bg := c.Query("bg"); bg != "" {
+        if opts.Background, err = utils.ParseHexColor(bg); err != nil {
+            return opts, err
+        }
+    }
+
+    return opts, opts.Validate()
+}
+
+// intQuery parses an optional integer query parameter, returning fallback if
+// it is absent
+func intQuery(c *gin.Context, name string, fallback int) (int, error) {
+    value := c.Query(name)
+    if value == "" {
+        return fallback, nil
+    }
+    n, err := strconv.Atoi(value)
+    if err != nil {
+        return 0, fmt.Errorf("%s must be a whole number", name)
+    }
+    return n, nil
+}
+
+// respondActiveLinkError reports why a short URL can't be followed, using
+// message for unexpected errors
+func respondActiveLinkError(c *gin.Context, err error, message string) {
+    switch err {
+    case url.ErrURLNotFound:
+        c.JSON(http.StatusNotFound, gin.H{"error": "URL not found"})
+    case service.ErrURLExpired:
+        c.JSON(http.StatusGone, gin.H{"error": "URL expired"})
+    case service.ErrClickLimitReached:
+        c.JSON(http.StatusGone, gin.H{"error": "Click limit reached"})
+    case service.ErrDomainBlocked:
+        c.JSON(http.StatusForbidden, gin.H{"error": "Destination blocked"})
+    default:
+        c.Error(err)
+        c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+    }
+}
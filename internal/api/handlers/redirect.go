@@ -2,21 +2,57 @@ package handlers
 
 import (
     "net/http"
+    "strconv"
+    "time"
 
     "github.com/gin-gonic/gin"
+    "github.com/gatij/goUrlShortener/internal/model"
     "github.com/gatij/goUrlShortener/internal/service"
-    "github.com/gatij/goUrlShortener/internal/storage/url"
 )
 
+const (
+    // DefaultRedirectStatus is the redirect used by links without their own
+    DefaultRedirectStatus = http.StatusMovedPermanently
+
+    // DefaultRedirectCacheMaxAge is how long clients may cache permanent redirects
+    DefaultRedirectCacheMaxAge = 24 * time.Hour
+)
+
+// RedirectConfig contains settings for the redirect endpoint
+type RedirectConfig struct {
+    Status      int           // Redirect status code of links without their own (301, 302, 307 or 308)
+    CacheMaxAge time.Duration // How long clients may cache permanent redirects
+    InactiveURL string        // Where links outside their activation window send visitors (FallbackURL if empty)
+    FallbackURL string        // Where browsers go for links that can't be followed (a page saying why if empty)
+}
+
 // RedirectHandler handles URL redirection
 type RedirectHandler struct {
     shortenerService *service.ShortenerService
+    analyticsService *service.AnalyticsService
+    config           RedirectConfig
+    passwords        *PasswordGate
 }
 
-// NewRedirectHandler creates a new redirect handler
-func NewRedirectHandler(shortenerService *service.ShortenerService) *RedirectHandler {
+// NewRedirectHandler creates a new redirect handler. Password-protected links
+// are opened through passwords.
+func NewRedirectHandler(
+    shortenerService *service.ShortenerService,
+    analyticsService *service.AnalyticsService,
+    config RedirectConfig,
+    passwords *PasswordGate,
+) *RedirectHandler {
+    if !service.IsValidRedirectStatus(config.Status) {
+        config.Status = DefaultRedirectStatus
+    }
+    if config.CacheMaxAge <= 0 {
+        config.CacheMaxAge = DefaultRedirectCacheMaxAge
+    }
     return &RedirectHandler{
         shortenerService: shortenerService,
+        analyticsService: analyticsService,
+        config:           config,
+        passwords:        passwords,
     }
 }
 
@@ -35,23 +71,72 @@ func (h *RedirectHandler) RedirectToOriginal(c *gin.Context) {
     // Get URL from storage
     urlData, err := h.shortenerService.GetURL(c.Request.Context(), shortCode)
     if err != nil {
-        if err == url.ErrURLNotFound {
-            c.JSON(http.StatusNotFound, gin.H{
-                "error": "URL not found",
-                "message": "The shortened URL you're trying to access doesn't exist or has expired.",
-                "help": "Please check the URL and try again, or create a new shortened URL at /api/v1/urls.",
-                "create_url_endpoint": "/api/v1/urls",
-            })
-            return
-        }
-        c.JSON(http.StatusInternalServerError, gin.H{
-            "error": "Failed to retrieve URL",
-            "message": "An internal error occurred while processing your request.",
-            "contact": "Please try again later or contact the administrator if the problem persists.",
-        })
+        h.respondUnavailable(c, urlData, err)
+        return
+    }
+
+    // Ask for the password first, the form isn't a click
+    if urlData.HasPassword() && !h.passwords.granted(c, urlData) {
+        renderPasswordForm(c, http.StatusOK, h.shortenerService.GenerateShortURL(urlData.ShortCode), "", "")
+        return
+    }
+
+    // Spend a click of click-limited links, another visitor may have taken
+    // the last one since the lookup
+    consumed, err := h.shortenerService.ConsumeClick(c.Request.Context(), urlData)
+    if err != nil {
+        h.respondUnavailable(c, urlData, err)
+        return
+    }
+    urlData = consumed
+
+    // Record the click
+    h.analyticsService.TrackClick(c.Request.Context(), model.Click{
+        ShortCode:     urlData.ShortCode,
+        LinkCreatedAt: urlData.CreatedAt,
+        Timestamp:     time.Now(),
+        Referrer:      c.Request.Referer(),
+        UserAgent:     c.Request.UserAgent(),
+        IP:            c.ClientIP(),
+    })
+
+    // Links that always show the preview page go on from there
+    if urlData.Preview {
+        renderPreview(c, h.shortenerService, urlData, urlData.Original)
         return
     }
 
     // Redirect to original URL
-    c.Redirect(http.StatusMovedPermanently, urlData.Original)
+    status := urlData.RedirectStatus
+    if status == 0 {
+        status = h.config.Status
+    }
+    h.setCacheHeaders(c, status, urlData, time.Now())
+    c.Redirect(status, urlData.Original)
+    h.shortenerService.RedirectServed()
+}
+
+// setCacheHeaders tells clients how long they may cache a redirect to link
+// with status. Permanent redirects are cached for CacheMaxAge, but no longer
+// than the link lives or its activation window lasts. Temporary redirects
+// aren't cached, so every click reaches the service and is counted, and
+// retargeting the link takes effect at once. Neither are redirects of
+// click-limited links, which must not outlive their last click, nor of
+// password-protected links, which mustn't reach anyone without the password.
+func (h *RedirectHandler) setCacheHeaders(c *gin.Context, status int, link model.URL, now time.Time) {
+    if status == http.StatusFound || status == http.StatusTemporaryRedirect || link.MaxClicks > 0 || link.HasPassword() {
+        c.Header("Cache-Control", "no-store")
+        c.Header("Expires", now.UTC().Format(http.TimeFormat))
+        return
+    }
+
+    maxAge := h.config.CacheMaxAge
+    for _, end := range []*time.Time{link.ExpiresAt, link.NotAfter} {
+        if end != nil && end.Sub(now) < maxAge {
+            maxAge = max(end.Sub(now), 0)
+        }
+    }
+    seconds := int64(maxAge / time.Second)
+    c.Header("Cache-Control", "public, max-age="+strconv.FormatInt(seconds, 10))
+    c.Header("Expires", now.Add(time.Duration(seconds)*time.Second).UTC().Format(http.TimeFormat))
 }
\ No newline at end of file
@@ -14,8 +14,12 @@ func RootHandler(c *gin.Context) {
         "description": "A simple, scalable URL shortener service written in Go",
         "endpoints": gin.H{
             "create_short_url": "POST /api/v1/urls",
+            "list_short_urls": "GET /api/v1/urls",
+            "manage_api_keys": "POST/GET /api/v1/keys (admin)",
             "get_top_domains": "GET /api/v1/metrics/domains",
             "redirect": "GET /{shortCode}",
+            "preview": "GET /{shortCode}/preview",
+            "qr_code": "GET /{shortCode}/qr",
             "health": "GET /health",
         },
         "usage_example": "POST /api/v1/urls with {\"url\": \"https://example.com/long/url\"}",
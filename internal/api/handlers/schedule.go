@@ -0,0 +1,77 @@
+package handlers
+
+import (
+    "bytes"
+    "html/template"
+    "math"
+    "net/http"
+    "strconv"
+    "time"
+
+    "github.com/gin-gonic/gin"
+    "github.com/gatij/goUrlShortener/internal/model"
+    "github.com/gatij/goUrlShortener/internal/service"
+)
+
+// inactiveTemplate is the page shown for a link outside its activation window
+var inactiveTemplate = template.Must(template.New("inactive").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>{{if .Closed}}Link no longer active{{else}}Link not active yet{{end}}</title>
+<style>
+body { font-family: system-ui, sans-serif; max-width: 30rem; margin: 3rem auto; padding: 0 1rem; color: #222; }
+</style>
+</head>
+<body>
+{{- if .Closed}}
+<h1>Link no longer active</h1>
+<p>{{.ShortURL}} stopped working on {{.At.Format "2 January 2006 at 15:04 MST"}}.</p>
+{{- else}}
+<h1>Link not active yet</h1>
+<p>{{.ShortURL}} starts working on {{.At.Format "2 January 2006 at 15:04 MST"}}. Please come back then.</p>
+{{- end}}
+</body>
+</html>
+`))
+
+// inactivePage holds what the inactive page shows
+type inactivePage struct {
+    ShortURL string
+    Closed   bool      // The window has ended rather than not started
+    At       time.Time // When the window starts or ended
+}
+
+// renderInactivePage writes the page saying when link, which err from
+// service.CheckSchedule found outside its activation window, works
+func renderInactivePage(c *gin.Context, shortenerService *service.ShortenerService, link model.URL, err error) {
+    page := inactivePage{ShortURL: shortenerService.GenerateShortURL(link.ShortCode)}
+    status := http.StatusNotFound
+    if err == service.ErrLinkClosed {
+        page.Closed = true
+        page.At = link.NotAfter.UTC()
+        status = http.StatusGone
+    } else {
+        page.At = link.NotBefore.UTC()
+        setRetryAfter(c, link)
+    }
+
+    var buf bytes.Buffer
+    if err := inactiveTemplate.Execute(&buf, page); err != nil {
+        c.Error(err)
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render page"})
+        return
+    }
+
+    // The link starts or stops working at a set time, so don't cache the page
+    c.Header("Cache-Control", "no-store")
+    c.Data(status, "text/html; charset=utf-8", buf.Bytes())
+}
+
+// setRetryAfter tells clients when link, which isn't active yet, starts working
+func setRetryAfter(c *gin.Context, link model.URL) {
+    seconds := int(math.Ceil(time.Until(*link.NotBefore).Seconds()))
+    c.Header("Retry-After", strconv.Itoa(max(seconds, 0)))
+}
@@ -1,22 +1,100 @@
 package handlers
 
 import (
+    "encoding/json"
+    "errors"
+    "fmt"
     "net/http"
+    "strconv"
+    "time"
 
     "github.com/gin-gonic/gin"
+    "github.com/gatij/goUrlShortener/internal/api/middleware"
+    "github.com/gatij/goUrlShortener/internal/model"
     "github.com/gatij/goUrlShortener/internal/service"
+    "github.com/gatij/goUrlShortener/internal/storage/url"
 )
 
 // URLRequest represents the request to create a shortened URL
 type URLRequest struct {
-    URL string `json:"url" binding:"required"`
+    URL       string     `json:"url" binding:"required"`
+    ExpiresIn *int64     `json:"expires_in,omitempty"` // Lifetime in seconds
+    ExpiresAt *time.Time `json:"expires_at,omitempty"` // Absolute expiry (RFC 3339)
+    Alias     string     `json:"alias,omitempty"`      // Custom short code
+
+    RedirectStatus int  `json:"redirect_status,omitempty"` // 301, 302, 307 or 308 (service default if omitted)
+    Preview        bool `json:"preview,omitempty"`         // Show the preview page on every visit
+
+    Password  string `json:"password,omitempty"`   // Password visitors must enter to follow the link
+    MaxClicks int    `json:"max_clicks,omitempty"` // Redirects after which the link stops working (unlimited if omitted)
+
+    NotBefore *time.Time `json:"not_before,omitempty"` // Start of the activation window (RFC 3339)
+    NotAfter  *time.Time `json:"not_after,omitempty"`  // End of the activation window (RFC 3339)
+
+    FallbackURL string `json:"fallback_url,omitempty"` // Where browsers go once the link can't be followed
+}
+
+// UpdateURLRequest represents the request to change a shortened URL. Fields
+// left out are kept; not_before and not_after may be null and fallback_url
+// empty to clear them.
+type UpdateURLRequest struct {
+    URL         string       `json:"url,omitempty"`          // New destination
+    NotBefore   optionalTime `json:"not_before"`             // New start of the activation window
+    NotAfter    optionalTime `json:"not_after"`              // New end of the activation window
+    FallbackURL *string      `json:"fallback_url,omitempty"` // New fallback URL
+}
+
+// optionalTime is a timestamp in a request that tells a field set to null
+// apart from one that was left out
+type optionalTime struct {
+    Set   bool
+    Value *time.Time
+}
+
+// UnmarshalJSON records that the field was present, even if it was null
+func (t *optionalTime) UnmarshalJSON(data []byte) error {
+    t.Set = true
+    if string(data) == "null" {
+        t.Value = nil
+        return nil
+    }
+    var value time.Time
+    if err := json.Unmarshal(data, &value); err != nil {
+        return err
+    }
+    t.Value = &value
+    return nil
 }
 
 // URLResponse represents the response with the shortened URL
 type URLResponse struct {
     ShortCode  string `json:"short_code"`
     ShortURL   string `json:"short_url"`
+    QRCodeURL  string `json:"qr_code_url"` // PNG or SVG QR code of the short URL
+    PreviewURL string `json:"preview_url"` // Page showing where the short URL leads
     OriginalURL string `json:"original_url"`
+    CreatedAt  time.Time  `json:"created_at"`
+    ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+    Owner      string     `json:"owner,omitempty"` // ID of the API key that created the URL
+
+    RedirectStatus int  `json:"redirect_status,omitempty"` // Omitted when the link uses the service default
+    Preview        bool `json:"preview,omitempty"`         // Visits show the preview page instead of redirecting
+
+    PasswordProtected bool `json:"password_protected,omitempty"`
+
+    MaxClicks       int  `json:"max_clicks,omitempty"`       // Omitted for links without a click limit
+    ClicksRemaining *int `json:"clicks_remaining,omitempty"` // Redirects left before the link stops working
+
+    NotBefore *time.Time `json:"not_before,omitempty"` // Start of the activation window
+    NotAfter  *time.Time `json:"not_after,omitempty"`  // End of the activation window
+
+    FallbackURL string `json:"fallback_url,omitempty"` // Where browsers go once the link can't be followed
+}
+
+// ListURLsResponse represents a page of shortened URLs
+type ListURLsResponse struct {
+    URLs       []URLResponse `json:"urls"`
+    NextCursor string        `json:"next_cursor,omitempty"` // Pass as "after" to get the next page
 }
 
 // ShortenerHandler handles URL shortening endpoints
@@ -39,21 +117,341 @@ func (h *ShortenerHandler) CreateShortURL(c *gin.Context) {
         return
     }
 
-    url, err := h.shortenerService.CreateShortURL(c.Request.Context(), req.URL)
+    opts, optsErr := createOptions(req, time.Now())
+    if optsErr != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": optsErr.Error()})
+        return
+    }
+    if key, ok := middleware.APIKeyFrom(c); ok {
+        opts.Owner = key.ID
+    }
+
+    url, err := h.shortenerService.CreateShortURL(c.Request.Context(), req.URL, opts)
     if err != nil {
-        if err == service.ErrInvalidURL {
-            c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid URL format"})
+        e := createError(err)
+        if e.status == http.StatusInternalServerError {
+            c.Error(err)
+        }
+        body := e.body()
+        if err == service.ErrURLAlreadyShortened {
+            // Report the existing short code of the URL
+            body["short_code"] = url.ShortCode
+            body["short_url"] = h.shortenerService.GenerateShortURL(url.ShortCode)
+        }
+        c.JSON(e.status, body)
+        return
+    }
+
+    c.JSON(http.StatusCreated, h.newURLResponse(url))
+}
+
+// ListURLs returns a page of the caller's shortened URLs, newest first unless
+// order=asc. Results can be filtered by destination domain, a substring of the
+// original URL (q) and a creation time range (from inclusive, to exclusive,
+// RFC 3339). Admins see every URL and can filter by owner.
+func (h *ShortenerHandler) ListURLs(c *gin.Context) {
+    caller, _ := middleware.APIKeyFrom(c)
+    opts := url.ListOptions{
+        Owner:    c.Query("owner"),
+        Domain:   c.Query("domain"),
+        Contains: c.Query("q"),
+        Cursor:   c.Query("after"),
+    }
+
+    switch c.DefaultQuery("order", "desc") {
+    case "desc":
+        opts.Descending = true
+    case "asc":
+    default:
+        c.JSON(http.StatusBadRequest, gin.H{"error": "order must be asc or desc"})
+        return
+    }
+
+    if limit := c.Query("limit"); limit != "" {
+        n, err := strconv.Atoi(limit)
+        if err != nil || n <= 0 {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive number"})
+            return
+        }
+        opts.Limit = n
+    }
+
+    var err error
+    if opts.CreatedFrom, err = parseTimeQuery(c, "from"); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "from must be an RFC 3339 timestamp"})
+        return
+    }
+    if opts.CreatedBefore, err = parseTimeQuery(c, "to"); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "to must be an RFC 3339 timestamp"})
+        return
+    }
+
+    result, err := h.shortenerService.ListURLs(c.Request.Context(), caller, opts)
+    if err != nil {
+        if err == url.ErrInvalidCursor {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+            return
+        }
+        if err == service.ErrForbidden {
+            c.JSON(http.StatusForbidden, gin.H{"error": "API key required to list URLs"})
             return
         }
-        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create short URL"})
+        c.Error(err)
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list URLs"})
         return
     }
 
-    shortURL := h.shortenerService.GenerateShortURL(url.ShortCode)
-    
-    c.JSON(http.StatusCreated, URLResponse{
-        ShortCode:   url.ShortCode,
+    response := ListURLsResponse{
+        URLs:       make([]URLResponse, 0, len(result.URLs)),
+        NextCursor: result.NextCursor,
+    }
+    for _, link := range result.URLs {
+        response.URLs = append(response.URLs, h.newURLResponse(link))
+    }
+
+    c.JSON(http.StatusOK, response)
+}
+
+// parseTimeQuery parses an optional RFC 3339 query parameter, returning the
+// zero time if it is absent
+func parseTimeQuery(c *gin.Context, name string) (time.Time, error) {
+    value := c.Query(name)
+    if value == "" {
+        return time.Time{}, nil
+    }
+    return time.Parse(time.RFC3339, value)
+}
+
+// GetShortURL returns the details of a shortened URL, including expired ones
+func (h *ShortenerHandler) GetShortURL(c *gin.Context) {
+    caller, _ := middleware.APIKeyFrom(c)
+    link, err := h.shortenerService.GetLink(c.Request.Context(), caller, c.Param("code"))
+    if err != nil {
+        respondLinkError(c, err, "Failed to retrieve URL")
+        return
+    }
+
+    c.JSON(http.StatusOK, h.newURLResponse(link))
+}
+
+// UpdateShortURL points a shortened URL at a new destination or changes its
+// activation window or fallback URL
+func (h *ShortenerHandler) UpdateShortURL(c *gin.Context) {
+    var req UpdateURLRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+        return
+    }
+
+    if req.URL == "" && !req.NotBefore.Set && !req.NotAfter.Set && req.FallbackURL == nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Nothing to update", "message": "Give a new url, not_before, not_after or fallback_url."})
+        return
+    }
+
+    opts := service.UpdateOptions{
+        Original: req.URL,
+
+        SetNotBefore: req.NotBefore.Set,
+        NotBefore:    req.NotBefore.Value,
+        SetNotAfter:  req.NotAfter.Set,
+        NotAfter:     req.NotAfter.Value,
+    }
+    if req.FallbackURL != nil {
+        opts.SetFallbackURL = true
+        opts.FallbackURL = *req.FallbackURL
+    }
+
+    caller, _ := middleware.APIKeyFrom(c)
+    link, err := h.shortenerService.UpdateLink(c.Request.Context(), caller, c.Param("code"), opts)
+    if err != nil {
+        if respondURLError(c, err) {
+            return
+        }
+        if err == service.ErrInvalidSchedule || err == service.ErrInvalidFallbackURL {
+            e := createError(err)
+            c.JSON(e.status, e.body())
+            return
+        }
+        if err == service.ErrURLAlreadyShortened {
+            c.JSON(http.StatusConflict, gin.H{
+                "error": "URL has already been shortened",
+                "short_code": link.ShortCode,
+                "short_url": h.shortenerService.GenerateShortURL(link.ShortCode),
+            })
+            return
+        }
+        respondLinkError(c, err, "Failed to update URL")
+        return
+    }
+
+    c.JSON(http.StatusOK, h.newURLResponse(link))
+}
+
+// DeleteShortURL removes a shortened URL
+func (h *ShortenerHandler) DeleteShortURL(c *gin.Context) {
+    caller, _ := middleware.APIKeyFrom(c)
+    if err := h.shortenerService.DeleteURL(c.Request.Context(), caller, c.Param("code")); err != nil {
+        respondLinkError(c, err, "Failed to delete URL")
+        return
+    }
+
+    c.Status(http.StatusNoContent)
+}
+
+// createOptions works out the options for creating req. The expiry may be
+// given as a lifetime from now or as a timestamp.
+func createOptions(req URLRequest, now time.Time) (service.CreateOptions, error) {
+    opts := service.CreateOptions{
+        ExpiresAt: req.ExpiresAt,
+        Alias:     req.Alias,
+
+        RedirectStatus: req.RedirectStatus,
+        Preview:        req.Preview,
+        Password:       req.Password,
+        MaxClicks:      req.MaxClicks,
+        NotBefore:      req.NotBefore,
+        NotAfter:       req.NotAfter,
+        FallbackURL:    req.FallbackURL,
+    }
+    if req.ExpiresIn != nil {
+        if req.ExpiresAt != nil {
+            return opts, errors.New("Specify only one of expires_in and expires_at")
+        }
+        if *req.ExpiresIn <= 0 {
+            return opts, errors.New("expires_in must be a positive number of seconds")
+        }
+        expiresAt := now.Add(time.Duration(*req.ExpiresIn) * time.Second)
+        opts.ExpiresAt = &expiresAt
+    }
+    return opts, nil
+}
+
+// apiError is an error response, with an optional message giving details
+type apiError struct {
+    status  int
+    error   string
+    message string
+}
+
+// body returns the JSON body of the response
+func (e apiError) body() gin.H {
+    body := gin.H{"error": e.error}
+    if e.message != "" {
+        body["message"] = e.message
+    }
+    return body
+}
+
+// urlError describes a destination URL that can't be shortened, or returns
+// false if err isn't about the URL
+func urlError(err error) (apiError, bool) {
+    switch err {
+    case service.ErrInvalidURL:
+        return apiError{http.StatusBadRequest, "Invalid URL format", ""}, true
+    case service.ErrDomainBlocked:
+        return apiError{http.StatusForbidden, "Domain is not allowed",
+            "This service doesn't shorten URLs on that domain."}, true
+    case service.ErrSelfLink:
+        return apiError{http.StatusBadRequest, "Cannot shorten links to this service",
+            "The URL is already a short link of this service and would redirect to itself."}, true
+    case service.ErrShortenerChain:
+        return apiError{http.StatusBadRequest, "URL is a short link of another shortener",
+            "Shorten the destination it redirects to instead."}, true
+    case service.ErrResolveFailed:
+        return apiError{http.StatusBadRequest, "Could not resolve short link",
+            "The link of the other shortener didn't lead to a destination."}, true
+    }
+    return apiError{}, false
+}
+
+// createError describes why creating a short URL failed
+func createError(err error) apiError {
+    if e, ok := urlError(err); ok {
+        return e
+    }
+    switch err {
+    case service.ErrInvalidExpiry:
+        return apiError{http.StatusBadRequest, "Expiry must be in the future", ""}
+    case service.ErrInvalidPassword:
+        return apiError{http.StatusBadRequest, "Invalid password",
+            fmt.Sprintf("Passwords must be %d to %d bytes long.", service.MinPasswordLength, service.MaxPasswordLength)}
+    case service.ErrInvalidSchedule:
+        return apiError{http.StatusBadRequest, "Invalid activation window", "not_after must be later than not_before."}
+    case service.ErrInvalidFallbackURL:
+        return apiError{http.StatusBadRequest, "Invalid fallback_url",
+            "fallback_url must be an HTTP or HTTPS URL on an allowed domain, not a link of this service."}
+    case service.ErrInvalidMaxClicks:
+        return apiError{http.StatusBadRequest, "Invalid max_clicks", "max_clicks must be a positive number, or omitted for no limit."}
+    case service.ErrInvalidRedirectStatus:
+        return apiError{http.StatusBadRequest, "Invalid redirect status", "redirect_status must be 301, 302, 307 or 308."}
+    case service.ErrInvalidAlias:
+        return apiError{http.StatusBadRequest, "Invalid alias format",
+            "Aliases must be 4-10 letters, digits, hyphens or underscores, starting and ending with a letter or digit."}
+    case service.ErrAliasReserved:
+        return apiError{http.StatusBadRequest, "Alias is reserved", ""}
+    case service.ErrAliasTaken:
+        return apiError{http.StatusConflict, "Alias is already taken", ""}
+    case service.ErrURLAlreadyShortened:
+        return apiError{http.StatusConflict, "URL has already been shortened", ""}
+    case service.ErrShortCodeUnavailable:
+        return apiError{http.StatusServiceUnavailable, "Could not generate a unique short code", "Please try again."}
+    }
+    return apiError{http.StatusInternalServerError, "Failed to create short URL", ""}
+}
+
+// respondURLError reports a destination URL that can't be shortened and
+// returns true, or returns false if err isn't about the URL
+func respondURLError(c *gin.Context, err error) bool {
+    e, ok := urlError(err)
+    if ok {
+        c.JSON(e.status, e.body())
+    }
+    return ok
+}
+
+// respondLinkError reports a failed lookup of an existing link, using message
+// for unexpected errors
+func respondLinkError(c *gin.Context, err error, message string) {
+    if err == url.ErrURLNotFound {
+        c.JSON(http.StatusNotFound, gin.H{"error": "URL not found"})
+        return
+    }
+    if err == service.ErrForbidden {
+        c.JSON(http.StatusForbidden, gin.H{"error": "URL belongs to another API key"})
+        return
+    }
+    c.Error(err)
+    c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+}
+
+// newURLResponse builds the API representation of a shortened URL
+func (h *ShortenerHandler) newURLResponse(link model.URL) URLResponse {
+    shortURL := h.shortenerService.GenerateShortURL(link.ShortCode)
+    response := URLResponse{
+        ShortCode:   link.ShortCode,
         ShortURL:    shortURL,
-        OriginalURL: url.Original,
-    })
+        QRCodeURL:   shortURL + "/qr",
+        PreviewURL:  shortURL + "/preview",
+        OriginalURL: link.Original,
+        CreatedAt:   link.CreatedAt,
+        ExpiresAt:   link.ExpiresAt,
+        Owner:       link.Owner,
+
+        RedirectStatus: link.RedirectStatus,
+        Preview:        link.Preview,
+
+        PasswordProtected: link.HasPassword(),
+
+        MaxClicks: link.MaxClicks,
+
+        NotBefore: link.NotBefore,
+        NotAfter:  link.NotAfter,
+
+        FallbackURL: link.FallbackURL,
+    }
+    if link.MaxClicks > 0 {
+        remaining := max(link.MaxClicks-link.Clicks, 0)
+        response.ClicksRemaining = &remaining
+    }
+    return response
 }
\ No newline at end of file
@@ -0,0 +1,157 @@
+package handlers
+
+import (
+    "bytes"
+    "html/template"
+    "maps"
+    "net/http"
+
+    "github.com/gin-gonic/gin"
+    "github.com/gatij/goUrlShortener/internal/model"
+    "github.com/gatij/goUrlShortener/internal/service"
+    "github.com/gatij/goUrlShortener/internal/storage/url"
+)
+
+// unavailableTemplate is the page browsers get for a short URL that can't be followed
+var unavailableTemplate = template.Must(template.New("unavailable").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>{{.Title}}</title>
+<style>
+body { font-family: system-ui, sans-serif; max-width: 30rem; margin: 3rem auto; padding: 0 1rem; color: #222; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p>{{.Message}}</p>
+{{- if .Help}}
+<p>{{.Help}}</p>
+{{- end}}
+</body>
+</html>
+`))
+
+// unavailablePage holds what the unavailable page shows
+type unavailablePage struct {
+    Title   string
+    Message string
+    Help    string
+}
+
+// linkProblem is the answer to a visit of a short URL that can't be followed
+type linkProblem struct {
+    status int
+    body   gin.H // JSON body; its error, message and help also fill the page
+}
+
+// linkProblems maps the errors that stop a short URL from being followed to their answers
+var linkProblems = map[error]linkProblem{
+    url.ErrURLNotFound: {http.StatusNotFound, gin.H{
+        "error": "URL not found",
+        "message": "The shortened URL you're trying to access doesn't exist or has expired.",
+        "help": "Please check the URL and try again, or create a new shortened URL at /api/v1/urls.",
+        "create_url_endpoint": "/api/v1/urls",
+    }},
+    service.ErrURLExpired: {http.StatusGone, gin.H{
+        "error": "URL expired",
+        "message": "The shortened URL you're trying to access has expired.",
+        "help": "Please ask the link owner for a new link, or create a new shortened URL at /api/v1/urls.",
+        "create_url_endpoint": "/api/v1/urls",
+    }},
+    service.ErrClickLimitReached: {http.StatusGone, gin.H{
+        "error": "Click limit reached",
+        "message": "The shortened URL you're trying to access could only be followed a limited number of times, and that limit has been reached.",
+        "help": "Please ask the link owner for a new link.",
+    }},
+    service.ErrDomainBlocked: {http.StatusForbidden, gin.H{
+        "error": "Destination blocked",
+        "message": "The shortened URL points to a domain that is no longer allowed.",
+    }},
+    service.ErrLinkNotYetActive: {http.StatusNotFound, gin.H{
+        "error": "Link not active yet",
+        "message": "The shortened URL you're trying to access doesn't work yet.",
+    }},
+    service.ErrLinkClosed: {http.StatusGone, gin.H{
+        "error": "Link no longer active",
+        "message": "The shortened URL you're trying to access has stopped working.",
+    }},
+}
+
+// respondUnavailable answers a visit to a short URL that err stops from
+// being followed. Browsers are sent to the fallback URL if there is one, or
+// get a page saying why; other clients get JSON, which names the fallback.
+// link is the URL as far as it was found. The answer isn't cached, as the
+// link may work again or be replaced.
+func (h *RedirectHandler) respondUnavailable(c *gin.Context, link model.URL, err error) {
+    problem, known := linkProblems[err]
+    if !known {
+        c.Error(err)
+        c.JSON(http.StatusInternalServerError, gin.H{
+            "error": "Failed to retrieve URL",
+            "message": "An internal error occurred while processing your request.",
+            "contact": "Please try again later or contact the administrator if the problem persists.",
+        })
+        return
+    }
+
+    c.Header("Cache-Control", "no-store")
+    fallbackURL := h.fallbackURL(link, err)
+    inactive := err == service.ErrLinkNotYetActive || err == service.ErrLinkClosed
+
+    if c.NegotiateFormat(gin.MIMEJSON, gin.MIMEHTML) == gin.MIMEHTML {
+        switch {
+        case fallbackURL != "":
+            c.Redirect(http.StatusFound, fallbackURL)
+        case inactive:
+            renderInactivePage(c, h.shortenerService, link, err)
+        default:
+            renderUnavailablePage(c, problem)
+        }
+        return
+    }
+
+    body := maps.Clone(problem.body)
+    if err == service.ErrLinkNotYetActive {
+        body["not_before"] = link.NotBefore
+        setRetryAfter(c, link)
+    }
+    if err == service.ErrLinkClosed {
+        body["not_after"] = link.NotAfter
+    }
+    if fallbackURL != "" {
+        body["fallback_url"] = fallbackURL
+    }
+    c.JSON(problem.status, body)
+}
+
+// fallbackURL returns where visitors of link go when err stops it from being
+// followed: the link's own fallback, else InactiveURL for links outside their
+// activation window, else the service-wide FallbackURL
+func (h *RedirectHandler) fallbackURL(link model.URL, err error) string {
+    if link.FallbackURL != "" {
+        return link.FallbackURL
+    }
+    if (err == service.ErrLinkNotYetActive || err == service.ErrLinkClosed) && h.config.InactiveURL != "" {
+        return h.config.InactiveURL
+    }
+    return h.config.FallbackURL
+}
+
+// renderUnavailablePage writes the page explaining problem
+func renderUnavailablePage(c *gin.Context, problem linkProblem) {
+    page := unavailablePage{}
+    page.Title, _ = problem.body["error"].(string)
+    page.Message, _ = problem.body["message"].(string)
+    page.Help, _ = problem.body["help"].(string)
+
+    var buf bytes.Buffer
+    if err := unavailableTemplate.Execute(&buf, page); err != nil {
+        c.Error(err)
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render page"})
+        return
+    }
+    c.Data(problem.status, "text/html; charset=utf-8", buf.Bytes())
+}
@@ -1,13 +1,19 @@
 package middleware
 
 import (
-	"strconv"
+    "log/slog"
+    "net/http"
+    "runtime/debug"
+    "strings"
     "time"
 
     "github.com/gin-gonic/gin"
+    "github.com/gatij/goUrlShortener/internal/logging"
 )
 
-// Logger is a middleware that logs request details
+// Logger is a middleware that logs request details with the request logger.
+// Errors handlers attached with c.Error are included, and requests that
+// failed with a server error are logged at error level.
 func Logger() gin.HandlerFunc {
     return func(c *gin.Context) {
         // Start time
@@ -16,22 +22,39 @@ func Logger() gin.HandlerFunc {
         // Process request
         c.Next()
 
-        // End time
-        endTime := time.Now()
-
         // Request details
-        latency := endTime.Sub(startTime)
-        clientIP := c.ClientIP()
-        method := c.Request.Method
         statusCode := c.Writer.Status()
-        path := c.Request.URL.Path
+        attrs := []slog.Attr{
+            slog.String("method", c.Request.Method),
+            slog.String("path", c.Request.URL.Path),
+            slog.Int("status", statusCode),
+            slog.Duration("latency", time.Since(startTime)),
+            slog.String("client_ip", c.ClientIP()),
+            slog.Int("bytes", c.Writer.Size()),
+        }
+        if len(c.Errors) > 0 {
+            attrs = append(attrs, slog.String("error", strings.Join(c.Errors.Errors(), "; ")))
+        }
+
+        level := slog.LevelInfo
+        if statusCode >= http.StatusInternalServerError {
+            level = slog.LevelError
+        }
 
         // Log request
-		gin.DefaultWriter.Write([]byte(
-			"[GIN] " + endTime.Format("2006/01/02 - 15:04:05") +
-			" | " + strconv.Itoa(statusCode) + 
-			" | " + latency.String() +
-			" | " + clientIP + " | " + method + " | " + path + "\n",
-		))
+        ctx := c.Request.Context()
+        logging.FromContext(ctx).LogAttrs(ctx, level, "Request", attrs...)
     }
-}
\ No newline at end of file
+}
+
+// Recovery is a middleware that turns a panic into a 500 response, logging it
+// with the stack trace
+func Recovery() gin.HandlerFunc {
+    return gin.CustomRecoveryWithWriter(nil, func(c *gin.Context, recovered any) {
+        logging.FromContext(c.Request.Context()).Error("Recovered from panic",
+            slog.Any("panic", recovered),
+            slog.String("stack", string(debug.Stack())),
+        )
+        c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+    })
+}
@@ -0,0 +1,31 @@
+package middleware
+
+import (
+    "time"
+
+    "github.com/gin-gonic/gin"
+)
+
+// unmatchedRoute labels requests that didn't match any route
+const unmatchedRoute = "unmatched"
+
+// RequestObserver records finished HTTP requests
+type RequestObserver interface {
+    ObserveRequest(route, method string, status int, duration time.Duration)
+}
+
+// Instrument is a middleware that reports every request to observer. Requests
+// are grouped by route pattern, such as "/:shortCode", rather than by path so
+// short codes don't each get their own series.
+func Instrument(observer RequestObserver) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        start := time.Now()
+        c.Next()
+
+        route := c.FullPath()
+        if route == "" {
+            route = unmatchedRoute
+        }
+        observer.ObserveRequest(route, c.Request.Method, c.Writer.Status(), time.Since(start))
+    }
+}
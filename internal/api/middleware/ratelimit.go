@@ -0,0 +1,168 @@
+package middleware
+
+import (
+    "math"
+    "net/http"
+    "strconv"
+    "sync"
+    "time"
+
+    "github.com/gin-gonic/gin"
+)
+
+// rateLimitSweepInterval is how often buckets that have refilled are dropped
+const rateLimitSweepInterval = time.Minute
+
+// RateLimit configures a token bucket: clients get Burst requests at once,
+// refilled at PerMinute requests per minute. A zero PerMinute disables it.
+type RateLimit struct {
+    PerMinute float64 // Sustained requests per minute
+    Burst     int     // Requests allowed in a burst (at least 1)
+}
+
+// Enabled reports whether the limit should be enforced
+func (l RateLimit) Enabled() bool {
+    return l.PerMinute > 0
+}
+
+// bucket is the state of a single client
+type bucket struct {
+    tokens  float64   // Requests the client can make right now
+    updated time.Time // When tokens was last brought up to date
+}
+
+// rateDecision is the outcome of a request against a bucket
+type rateDecision struct {
+    allowed    bool
+    remaining  int           // Whole tokens left after this request
+    retryAfter time.Duration // Until a token is available again, if not allowed
+    reset      time.Duration // Until the bucket is full again
+}
+
+// RateLimiter enforces a RateLimit per client with a token bucket each. State
+// is kept in process memory, so every server instance limits independently.
+type RateLimiter struct {
+    limit     RateLimit
+    perSecond float64
+    now       func() time.Time // Clock, replaceable in tests
+
+    mu        sync.Mutex
+    buckets   map[string]*bucket
+    lastSweep time.Time
+}
+
+// NewRateLimiter creates a rate limiter for limit
+func NewRateLimiter(limit RateLimit) *RateLimiter {
+    if limit.Burst < 1 {
+        limit.Burst = 1
+    }
+    return &RateLimiter{
+        limit:     limit,
+        perSecond: limit.PerMinute / 60,
+        now:       time.Now,
+        buckets:   make(map[string]*bucket),
+    }
+}
+
+// take spends a token from the bucket of key, if one is available
+func (l *RateLimiter) take(key string) rateDecision {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+
+    now := l.now()
+    l.sweep(now)
+
+    b, exists := l.buckets[key]
+    if !exists {
+        b = &bucket{tokens: float64(l.limit.Burst), updated: now}
+        l.buckets[key] = b
+    } else {
+        b.tokens = l.refill(b, now)
+        b.updated = now
+    }
+
+    decision := rateDecision{}
+    if b.tokens >= 1 {
+        b.tokens--
+        decision.allowed = true
+    } else {
+        decision.retryAfter = l.duration(1 - b.tokens)
+    }
+    decision.remaining = int(b.tokens)
+    decision.reset = l.duration(float64(l.limit.Burst) - b.tokens)
+
+    return decision
+}
+
+// Allow spends a token from the bucket of key, for limits on something other
+// than requests. If none is available it returns false and how long until
+// there is one.
+func (l *RateLimiter) Allow(key string) (bool, time.Duration) {
+    decision := l.take(key)
+    return decision.allowed, decision.retryAfter
+}
+
+// refill returns the tokens in b as of now. Caller must hold the lock.
+func (l *RateLimiter) refill(b *bucket, now time.Time) float64 {
+    elapsed := now.Sub(b.updated).Seconds()
+    if elapsed <= 0 {
+        return b.tokens
+    }
+    return math.Min(float64(l.limit.Burst), b.tokens+elapsed*l.perSecond)
+}
+
+// duration returns how long it takes to refill the given number of tokens
+func (l *RateLimiter) duration(tokens float64) time.Duration {
+    return time.Duration(tokens / l.perSecond * float64(time.Second))
+}
+
+// sweep drops buckets that have refilled completely, since a new bucket is
+// equivalent. Caller must hold the lock.
+func (l *RateLimiter) sweep(now time.Time) {
+    if now.Sub(l.lastSweep) < rateLimitSweepInterval {
+        return
+    }
+    l.lastSweep = now
+
+    for key, b := range l.buckets {
+        if l.refill(b, now) >= float64(l.limit.Burst) {
+            delete(l.buckets, key)
+        }
+    }
+}
+
+// ClientKey returns the key a client is limited by: its API key if
+// Authenticate ran before and accepted one, and its IP address otherwise. The
+// IP address honours X-Forwarded-For only from the router's trusted proxies.
+func ClientKey(c *gin.Context) string {
+    if apiKey, ok := APIKeyFrom(c); ok {
+        return "key:" + apiKey.ID
+    }
+    return "ip:" + c.ClientIP()
+}
+
+// RateLimitByClient is a middleware that limits each client, told apart by
+// ClientKey, with limiter
+func RateLimitByClient(limiter *RateLimiter) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        decision := limiter.take(ClientKey(c))
+        c.Header("X-RateLimit-Limit", strconv.Itoa(limiter.limit.Burst))
+        c.Header("X-RateLimit-Remaining", strconv.Itoa(decision.remaining))
+        c.Header("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(decision.reset)))
+
+        if !decision.allowed {
+            c.Header("Retry-After", strconv.Itoa(ceilSeconds(decision.retryAfter)))
+            c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+                "error": "Rate limit exceeded",
+                "message": "Too many requests, please retry after the time in the Retry-After header.",
+            })
+            return
+        }
+        c.Next()
+    }
+}
+
+// ceilSeconds rounds a duration up to whole seconds
+func ceilSeconds(d time.Duration) int {
+    return int(math.Ceil(d.Seconds()))
+}
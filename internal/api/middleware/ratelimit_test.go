@@ -0,0 +1,144 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gatij/goUrlShortener/internal/model"
+)
+
+// fakeClock is a manually advanced clock
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func newTestLimiter(limit RateLimit) (*RateLimiter, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+	limiter := NewRateLimiter(limit)
+	limiter.now = clock.Now
+	return limiter, clock
+}
+
+func TestRateLimiter_TokenBucket(t *testing.T) {
+	// 3 at once, then one every 10 seconds
+	limiter, clock := newTestLimiter(RateLimit{PerMinute: 6, Burst: 3})
+
+	for i := 0; i < 3; i++ {
+		decision := limiter.take("client")
+		if !decision.allowed {
+			t.Fatalf("Request %d: expected the burst to be allowed", i+1)
+		}
+		if decision.remaining != 2-i {
+			t.Errorf("Request %d: expected %d remaining but got %d", i+1, 2-i, decision.remaining)
+		}
+	}
+
+	decision := limiter.take("client")
+	if decision.allowed {
+		t.Fatal("Expected the request after the burst to be limited")
+	}
+	if decision.retryAfter != 10*time.Second {
+		t.Errorf("Expected retry after 10s but got %v", decision.retryAfter)
+	}
+	if decision.reset != 30*time.Second {
+		t.Errorf("Expected reset after 30s but got %v", decision.reset)
+	}
+
+	// Other clients have their own bucket
+	if !limiter.take("other").allowed {
+		t.Error("Expected another client to be allowed")
+	}
+
+	// A token comes back after 10 seconds
+	clock.now = clock.now.Add(10 * time.Second)
+	if !limiter.take("client").allowed {
+		t.Error("Expected a request to be allowed after the refill")
+	}
+	if limiter.take("client").allowed {
+		t.Error("Expected only one token to have refilled")
+	}
+
+	// Buckets never hold more than the burst
+	clock.now = clock.now.Add(time.Hour)
+	for i := 0; i < 3; i++ {
+		limiter.take("client")
+	}
+	if limiter.take("client").allowed {
+		t.Error("Expected the bucket to be capped at the burst")
+	}
+}
+
+func TestRateLimiter_SweepsFullBuckets(t *testing.T) {
+	limiter, clock := newTestLimiter(RateLimit{PerMinute: 60, Burst: 2})
+
+	limiter.take("idle")
+	clock.now = clock.now.Add(2 * rateLimitSweepInterval)
+	limiter.take("active")
+
+	if _, exists := limiter.buckets["idle"]; exists {
+		t.Error("Expected the refilled bucket to be dropped")
+	}
+	if _, exists := limiter.buckets["active"]; !exists {
+		t.Error("Expected the active bucket to be kept")
+	}
+}
+
+func TestRateLimitByClient(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	limiter, _ := newTestLimiter(RateLimit{PerMinute: 1, Burst: 1})
+
+	router := gin.New()
+	router.SetTrustedProxies(nil)
+	router.Use(func(c *gin.Context) {
+		if id := c.GetHeader("X-Test-Key"); id != "" {
+			c.Set(apiKeyContextKey, model.APIKey{ID: id})
+		}
+	})
+	router.GET("/", RateLimitByClient(limiter), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	do := func(remoteAddr, forwardedFor, key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = remoteAddr
+		if forwardedFor != "" {
+			req.Header.Set("X-Forwarded-For", forwardedFor)
+		}
+		if key != "" {
+			req.Header.Set("X-Test-Key", key)
+		}
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		return resp
+	}
+
+	first := do("192.0.2.1:1234", "", "")
+	if first.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d but got %d", http.StatusOK, first.Code)
+	}
+	if first.Header().Get("X-RateLimit-Limit") != "1" || first.Header().Get("X-RateLimit-Remaining") != "0" || first.Header().Get("X-RateLimit-Reset") != "60" {
+		t.Errorf("Unexpected rate limit headers: %v", first.Header())
+	}
+
+	// A spoofed X-Forwarded-For from an untrusted client doesn't get a new bucket
+	limited := do("192.0.2.1:1234", "203.0.113.7", "")
+	if limited.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected status code %d but got %d", http.StatusTooManyRequests, limited.Code)
+	}
+	if limited.Header().Get("Retry-After") != "60" {
+		t.Errorf("Expected Retry-After 60 but got %q", limited.Header().Get("Retry-After"))
+	}
+
+	// API keys are limited on their own, wherever they come from
+	if resp := do("192.0.2.1:1234", "", "key1"); resp.Code != http.StatusOK {
+		t.Errorf("Expected an API key to have its own bucket but got %d", resp.Code)
+	}
+	if resp := do("192.0.2.2:1234", "", "key1"); resp.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected the API key's bucket to be shared across IPs but got %d", resp.Code)
+	}
+}
@@ -0,0 +1,44 @@
+package middleware
+
+import (
+    "crypto/rand"
+    "encoding/hex"
+    "log/slog"
+    "regexp"
+
+    "github.com/gin-gonic/gin"
+    "github.com/gatij/goUrlShortener/internal/logging"
+)
+
+// RequestIDHeader carries the ID of a request, from the client or a proxy in
+// front of the service, and back in the response
+const RequestIDHeader = "X-Request-ID"
+
+// requestIDPattern limits incoming request IDs to short, log-safe values
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// RequestID is a middleware that gives every request an ID, reusing a valid
+// X-Request-ID header and generating one otherwise. The ID is sent back in
+// the response, and the request context gets a logger that adds it to
+// every record, so the handlers, services and storage all log it.
+func RequestID() gin.HandlerFunc {
+    return func(c *gin.Context) {
+        id := c.GetHeader(RequestIDHeader)
+        if !requestIDPattern.MatchString(id) {
+            id = newRequestID()
+        }
+        c.Header(RequestIDHeader, id)
+
+        ctx := c.Request.Context()
+        logger := logging.FromContext(ctx).With(slog.String("request_id", id))
+        c.Request = c.Request.WithContext(logging.NewContext(ctx, logger))
+        c.Next()
+    }
+}
+
+// newRequestID returns a random request ID
+func newRequestID() string {
+    b := make([]byte, 12)
+    rand.Read(b)
+    return hex.EncodeToString(b)
+}
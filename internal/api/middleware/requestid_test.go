@@ -0,0 +1,106 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gatij/goUrlShortener/internal/logging"
+)
+
+// newLoggedRouter returns a router with the request ID and logging middleware
+// whose request logs are written to the returned buffer
+func newLoggedRouter(t *testing.T) (*gin.Engine, *bytes.Buffer) {
+	gin.SetMode(gin.TestMode)
+
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, nil)))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	router := gin.New()
+	router.Use(RequestID(), Logger(), Recovery())
+	router.GET("/ok", func(c *gin.Context) {
+		logging.FromContext(c.Request.Context()).Info("Handling")
+		c.Status(http.StatusNoContent)
+	})
+	router.GET("/panic", func(c *gin.Context) {
+		panic("boom")
+	})
+	return router, &buf
+}
+
+// logRecords decodes the JSON log records in buf
+func logRecords(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	var records []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var record map[string]any
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("Failed to decode log line %q: %v", line, err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestRequestID(t *testing.T) {
+	router, buf := newLoggedRouter(t)
+
+	// A valid incoming ID is kept
+	req := httptest.NewRequest(http.MethodGet, "/ok", nil)
+	req.Header.Set(RequestIDHeader, "abc-123")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if got := w.Header().Get(RequestIDHeader); got != "abc-123" {
+		t.Errorf("Expected the incoming request ID to be echoed but got %q", got)
+	}
+	records := logRecords(t, buf)
+	if len(records) != 2 {
+		t.Fatalf("Expected a handler and a request record but got %d", len(records))
+	}
+	for _, record := range records {
+		if record["request_id"] != "abc-123" {
+			t.Errorf("Expected request_id abc-123 in record %v", record)
+		}
+	}
+	if records[1]["msg"] != "Request" || records[1]["status"] != float64(http.StatusNoContent) {
+		t.Errorf("Unexpected request record %v", records[1])
+	}
+
+	// Missing and unsafe IDs are replaced
+	for _, incoming := range []string{"", "bad id\n", strings.Repeat("a", 129)} {
+		req := httptest.NewRequest(http.MethodGet, "/ok", nil)
+		req.Header.Set(RequestIDHeader, incoming)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		got := w.Header().Get(RequestIDHeader)
+		if got == "" || got == incoming || !requestIDPattern.MatchString(got) {
+			t.Errorf("Expected a generated request ID for %q but got %q", incoming, got)
+		}
+	}
+}
+
+func TestRecovery(t *testing.T) {
+	router, buf := newLoggedRouter(t)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/panic", nil))
+
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("Expected status %d but got %d", http.StatusInternalServerError, w.Code)
+	}
+	records := logRecords(t, buf)
+	if len(records) != 2 || records[0]["panic"] != "boom" {
+		t.Fatalf("Expected the panic to be logged but got %v", records)
+	}
+	if records[1]["level"] != "ERROR" || records[1]["request_id"] == nil {
+		t.Errorf("Expected the request to be logged as an error with its ID but got %v", records[1])
+	}
+}
@@ -1,27 +1,77 @@
 package api
 
 import (
+    "log/slog"
+
     "github.com/gin-gonic/gin"
     "github.com/gatij/goUrlShortener/internal/api/handlers"
     "github.com/gatij/goUrlShortener/internal/api/middleware"
     "github.com/gatij/goUrlShortener/internal/service"
+    "github.com/gatij/goUrlShortener/internal/telemetry"
 )
 
+// RouterConfig contains settings for the API routes
+type RouterConfig struct {
+    AllowAnonymousShorten bool // Let requests without an API key create short URLs
+
+    TrustedProxies    []string             // Proxy IPs or CIDRs whose X-Forwarded-For is believed (none if empty)
+    CreateRateLimit   middleware.RateLimit // Per-client limit on creating short URLs and the rest of the API
+    RedirectRateLimit middleware.RateLimit // Per-client limit on following short URLs and their QR codes and previews
+
+    Batch    handlers.BatchConfig    // Limits of the batch shortening endpoint
+    Redirect handlers.RedirectConfig // Default redirect status and caching of short URLs
+    Password handlers.PasswordConfig // Access cookies and throttling of password-protected links
+
+    Telemetry *telemetry.Telemetry // Prometheus metrics served at /metrics (disabled if nil)
+}
+
 // SetupRouter configures the API routes
 func SetupRouter(
     shortenerService *service.ShortenerService, 
     metricsService *service.MetricsService,
+    analyticsService *service.AnalyticsService,
+    apiKeyService *service.APIKeyService,
+    config RouterConfig,
 ) *gin.Engine {
-    // Create router with default middleware
-    router := gin.Default()
+    // Create router, tagging each request with an ID before it is logged
+    router := gin.New()
+    router.Use(middleware.RequestID(), middleware.Logger(), middleware.Recovery())
+    if config.Telemetry != nil {
+        router.Use(middleware.Instrument(config.Telemetry))
+    }
+
+    // Only take the client IP from X-Forwarded-For when a trusted proxy sent it,
+    // otherwise clients could pick their own IP and dodge rate limits
+    if err := router.SetTrustedProxies(config.TrustedProxies); err != nil {
+        slog.Warn("Invalid trusted proxies, trusting none", "error", err)
+        router.SetTrustedProxies(nil)
+    }
 
-    // Add custom logging middleware
-    router.Use(middleware.Logger())
+    // Single and batch shortening share a limit with the rest of the API. A
+    // batch is let in like a single request, and every URL after its first
+    // is charged as another.
+    createLimiter := newRateLimiter(config.CreateRateLimit)
+    createLimit := limitWith(createLimiter)
+    batchConfig := config.Batch
+    batchConfig.Limiter = createLimiter
+
+    // Every way of visiting a short URL shares one limit
+    redirectLimit := limitWith(newRateLimiter(config.RedirectRateLimit))
 
     // Create handlers
     shortenerHandler := handlers.NewShortenerHandler(shortenerService)
-    redirectHandler := handlers.NewRedirectHandler(shortenerService)
+    batchHandler := handlers.NewBatchHandler(shortenerService, batchConfig)
+    passwordGate := handlers.NewPasswordGate(config.Password)
+    redirectHandler := handlers.NewRedirectHandler(shortenerService, analyticsService, config.Redirect, passwordGate)
+    qrHandler := handlers.NewQRHandler(shortenerService)
+    previewHandler := handlers.NewPreviewHandler(shortenerService, passwordGate)
     metricsHandler := handlers.NewMetricsHandler(metricsService)
+    analyticsHandler := handlers.NewAnalyticsHandler(shortenerService, analyticsService)
+    apiKeyHandler := handlers.NewAPIKeyHandler(apiKeyService)
+
+    // API key checks
+    requireKey := middleware.Authenticate(apiKeyService, false)
+    shortenKey := middleware.Authenticate(apiKeyService, config.AllowAnonymousShorten)
 
 	// Root endpoint - provides service information
     router.GET("/", handlers.RootHandler)
@@ -29,20 +79,63 @@ func SetupRouter(
     // API routes
     api := router.Group("/api/v1")
     {
-        // URL shortening endpoint
-        api.POST("/urls", shortenerHandler.CreateShortURL)
+        // URL shortening endpoints
+        api.POST("/urls", shortenKey, createLimit, shortenerHandler.CreateShortURL)
+        api.POST("/urls/batch", shortenKey, createLimit, batchHandler.CreateShortURLs)
+        
+        // Link management, limited to the links the API key created
+        links := api.Group("/urls", requireKey, createLimit)
+        links.GET("", shortenerHandler.ListURLs)
+        links.GET("/:code", shortenerHandler.GetShortURL)
+        links.PATCH("/:code", shortenerHandler.UpdateShortURL)
+        links.DELETE("/:code", shortenerHandler.DeleteShortURL)
+        
+        // Per-link click analytics
+        links.GET("/:code/stats", analyticsHandler.GetLinkStats)
+        
+        // API key management, admin only
+        keys := api.Group("/keys", requireKey, createLimit, middleware.RequireAdmin())
+        keys.POST("", apiKeyHandler.CreateAPIKey)
+        keys.GET("", apiKeyHandler.ListAPIKeys)
+        keys.DELETE("/:id", apiKeyHandler.DeleteAPIKey)
         
         // Metrics endpoint
         api.GET("/metrics/domains", metricsHandler.GetTopDomains)
+        api.GET("/metrics/collisions", metricsHandler.GetShortCodeStats)
     }
 
     // Redirect route - must be last to catch all other paths
-    router.GET("/:shortCode", redirectHandler.RedirectToOriginal)
+    router.GET("/:shortCode", redirectLimit, redirectHandler.RedirectToOriginal)
+    router.POST("/:shortCode", redirectLimit, redirectHandler.SubmitPassword)
+    router.GET("/:shortCode/qr", redirectLimit, qrHandler.GetQRCode)
+    router.GET("/:shortCode/preview", redirectLimit, previewHandler.GetPreview)
 
     // Health check
     router.GET("/health", func(c *gin.Context) {
         c.String(200, "OK")
     })
 
+    // Operational metrics for Prometheus
+    if config.Telemetry != nil {
+        router.GET("/metrics", gin.WrapH(config.Telemetry.Handler()))
+    }
+
     return router
-}
\ No newline at end of file
+}
+
+// newRateLimiter creates a limiter enforcing limit, or returns nil if limit is disabled
+func newRateLimiter(limit middleware.RateLimit) *middleware.RateLimiter {
+    if !limit.Enabled() {
+        return nil
+    }
+    return middleware.NewRateLimiter(limit)
+}
+
+// limitWith returns a middleware limiting each client with limiter, or one
+// that lets everything through if limiter is nil
+func limitWith(limiter *middleware.RateLimiter) gin.HandlerFunc {
+    if limiter == nil {
+        return func(c *gin.Context) { c.Next() }
+    }
+    return middleware.RateLimitByClient(limiter)
+}
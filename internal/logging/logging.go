@@ -0,0 +1,51 @@
+package logging
+
+import (
+    "context"
+    "fmt"
+    "io"
+    "log/slog"
+    "strings"
+)
+
+// Log formats that can be selected with LOG_FORMAT
+const (
+    FormatJSON = "json"
+    FormatText = "text"
+)
+
+// contextKey is the type of the context key holding the logger
+type contextKey struct{}
+
+// New creates a logger writing to w in format (json or text), dropping
+// records below level (debug, info, warn or error)
+func New(w io.Writer, format, level string) (*slog.Logger, error) {
+    var lvl slog.Level
+    if err := lvl.UnmarshalText([]byte(level)); err != nil {
+        return nil, fmt.Errorf("unknown log level %q", level)
+    }
+    opts := &slog.HandlerOptions{Level: lvl}
+
+    switch strings.ToLower(format) {
+    case FormatJSON:
+        return slog.New(slog.NewJSONHandler(w, opts)), nil
+    case FormatText:
+        return slog.New(slog.NewTextHandler(w, opts)), nil
+    default:
+        return nil, fmt.Errorf("unknown log format %q", format)
+    }
+}
+
+// NewContext returns a copy of ctx carrying logger
+func NewContext(ctx context.Context, logger *slog.Logger) context.Context {
+    return context.WithValue(ctx, contextKey{}, logger)
+}
+
+// FromContext returns the logger carried by ctx, such as the request logger
+// with the request ID, or the default logger if there is none
+func FromContext(ctx context.Context) *slog.Logger {
+    if logger, ok := ctx.Value(contextKey{}).(*slog.Logger); ok {
+        return logger
+    }
+    return slog.Default()
+}
@@ -0,0 +1,73 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestNew_Formats(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, FormatJSON, "info")
+	if err != nil {
+		t.Fatalf("Failed to create JSON logger: %v", err)
+	}
+	logger.Info("hello", "key", "value")
+
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("Expected a JSON record but got %q: %v", buf.String(), err)
+	}
+	if record["msg"] != "hello" || record["key"] != "value" {
+		t.Errorf("Unexpected record %v", record)
+	}
+
+	buf.Reset()
+	logger, err = New(&buf, "TEXT", "info")
+	if err != nil {
+		t.Fatalf("Failed to create text logger: %v", err)
+	}
+	logger.Info("hello", "key", "value")
+	if !strings.Contains(buf.String(), "msg=hello key=value") {
+		t.Errorf("Expected a text record but got %q", buf.String())
+	}
+
+	if _, err := New(&buf, "xml", "info"); err == nil {
+		t.Error("Expected an error for an unknown format")
+	}
+	if _, err := New(&buf, FormatJSON, "loud"); err == nil {
+		t.Error("Expected an error for an unknown level")
+	}
+}
+
+func TestNew_Level(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, FormatText, "WARN")
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+
+	logger.Info("dropped")
+	logger.Warn("kept")
+	if strings.Contains(buf.String(), "dropped") {
+		t.Error("Expected info records to be dropped at warn level")
+	}
+	if !strings.Contains(buf.String(), "kept") {
+		t.Error("Expected warn records to be logged at warn level")
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	if FromContext(context.Background()) != slog.Default() {
+		t.Error("Expected the default logger for a context without one")
+	}
+
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	ctx := NewContext(context.Background(), logger)
+	if FromContext(ctx) != logger {
+		t.Error("Expected the logger stored in the context")
+	}
+}
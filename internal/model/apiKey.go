@@ -0,0 +1,23 @@
+package model
+
+import "time"
+
+// API key scopes
+const (
+	ScopeUser  = "user"  // Can create links and manage the links it created
+	ScopeAdmin = "admin" // Can manage every link and API key
+)
+
+// APIKey is a credential for the API. Only a hash of the secret is stored.
+type APIKey struct {
+	ID        string    `json:"id"`         // Public identifier, recorded as the owner of links
+	Name      string    `json:"name"`       // Human readable label
+	Hash      string    `json:"hash"`       // Hex encoded SHA-256 of the secret
+	Scope     string    `json:"scope"`      // ScopeUser or ScopeAdmin
+	CreatedAt time.Time `json:"created_at"` // Timestamp when the key was created
+}
+
+// IsAdmin reports whether the key has the admin scope
+func (k APIKey) IsAdmin() bool {
+	return k.Scope == ScopeAdmin
+}
@@ -0,0 +1,13 @@
+package model
+
+import "time"
+
+// Click represents a single visit to a short URL
+type Click struct {
+	ShortCode     string    `json:"short_code"`      // Short code that was visited
+	LinkCreatedAt time.Time `json:"link_created_at"` // When the visited link was created, telling apart links that reused the code
+	Timestamp     time.Time `json:"timestamp"`       // When the redirect happened
+	Referrer      string    `json:"referrer"`        // Referer header sent by the client, if any
+	UserAgent     string    `json:"user_agent"`      // User-Agent header sent by the client
+	IP            string    `json:"ip"`              // Client IP address
+}
@@ -0,0 +1,22 @@
+package model
+
+// LinkStats summarizes the clicks on a single short URL
+type LinkStats struct {
+	ShortCode     string        `json:"short_code"`      // Short code the stats are for
+	TotalClicks   int           `json:"total_clicks"`    // Clicks since the link was created
+	ClicksPerDay  []DailyClicks `json:"clicks_per_day"`  // Clicks per UTC day, oldest first
+	TopReferrers  []CountEntry  `json:"top_referrers"`   // Most common referring hosts
+	TopUserAgents []CountEntry  `json:"top_user_agents"` // Most common browser families
+}
+
+// DailyClicks is the number of clicks on a single UTC day
+type DailyClicks struct {
+	Date   string `json:"date"`   // Day in YYYY-MM-DD format
+	Clicks int    `json:"clicks"` // Clicks on that day
+}
+
+// CountEntry is a value with the number of times it was seen
+type CountEntry struct {
+	Name  string `json:"name"`  // The counted value
+	Count int    `json:"count"` // Number of occurrences
+}
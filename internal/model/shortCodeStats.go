@@ -0,0 +1,8 @@
+package model
+
+// ShortCodeStats summarizes short code generation since the service started
+type ShortCodeStats struct {
+	Collisions  int64 `json:"collisions"`  // Generated codes that were already taken
+	Exhausted   int64 `json:"exhausted"`   // Requests that ran out of attempts
+	Escalations int64 `json:"escalations"` // Times the generated code length was increased
+}
@@ -4,8 +4,65 @@ import "time"
 
 // URL represents a shortened URL entry in the system
 type URL struct {
-	ID        string    `json:"id"`        // Unique identifier for the URL
-	ShortCode string    `json:"short_code"` // Shortened code for the URL
-	Original  string    `json:"original"`  // Original long URL
-	CreatedAt time.Time `json:"created_at"` // Timestamp when the URL was created
-}
\ No newline at end of file
+	ID             string     `json:"id"`                        // Unique identifier for the URL
+	ShortCode      string     `json:"short_code"`                // Shortened code for the URL
+	Original       string     `json:"original"`                  // Original long URL
+	CreatedAt      time.Time  `json:"created_at"`                // Timestamp when the URL was created
+	ExpiresAt      *time.Time `json:"expires_at,omitempty"`      // Optional timestamp after which the URL stops resolving
+	Owner          string     `json:"owner,omitempty"`           // ID of the API key that created the URL (empty if anonymous)
+	RedirectStatus int        `json:"redirect_status,omitempty"` // Status code of the redirect (0 for the service default)
+	Preview        bool       `json:"preview,omitempty"`         // Show the preview page on every visit instead of redirecting
+	PasswordHash   string     `json:"password_hash,omitempty"`   // bcrypt hash of the password visitors must enter (empty if none)
+	MaxClicks      int        `json:"max_clicks,omitempty"`      // Number of redirects after which the URL stops resolving (0 for unlimited)
+	Clicks         int        `json:"clicks,omitempty"`          // Redirects counted against MaxClicks so far
+	NotBefore      *time.Time `json:"not_before,omitempty"`      // Optional start of the window in which the URL redirects
+	NotAfter       *time.Time `json:"not_after,omitempty"`       // Optional end of the window in which the URL redirects
+	FallbackURL    string     `json:"fallback_url,omitempty"`    // Where visitors go once the URL can't be followed (empty for the service default)
+}
+
+// HasPassword reports whether visitors must enter a password to follow the URL
+func (u URL) HasPassword() bool {
+	return u.PasswordHash != ""
+}
+
+// ClicksExhausted reports whether the URL is click-limited and has used up all of its clicks
+func (u URL) ClicksExhausted() bool {
+	return u.MaxClicks > 0 && u.Clicks >= u.MaxClicks
+}
+
+// IsExpired reports whether the URL has an expiry that is at or before now
+func (u URL) IsExpired(now time.Time) bool {
+	return u.ExpiresAt != nil && !u.ExpiresAt.After(now)
+}
+
+// IsNotYetActive reports whether the URL has an activation window that starts after now
+func (u URL) IsNotYetActive(now time.Time) bool {
+	return u.NotBefore != nil && now.Before(*u.NotBefore)
+}
+
+// IsClosed reports whether the URL has an activation window that ended at or before now
+func (u URL) IsClosed(now time.Time) bool {
+	return u.NotAfter != nil && !u.NotAfter.After(now)
+}
+
+// IsSpent reports whether the URL can no longer be followed at now because it
+// has expired or used up its clicks, so its original URL may be shortened anew
+func (u URL) IsSpent(now time.Time) bool {
+	return u.IsExpired(now) || u.ClicksExhausted()
+}
+
+// KeepsFallback reports whether the URL has expired at now but has a fallback
+// URL to send its visitors to, so it is kept rather than replaced on sight
+func (u URL) KeepsFallback(now time.Time) bool {
+	return u.FallbackURL != "" && u.IsExpired(now)
+}
+
+// IsReplaceable reports whether the URL is spent at now and may be removed to
+// make way for a new link. A URL kept for its fallback URL only is once it has
+// been expired for longer than retention.
+func (u URL) IsReplaceable(now time.Time, retention time.Duration) bool {
+	if u.KeepsFallback(now) {
+		return u.IsExpired(now.Add(-retention))
+	}
+	return u.IsSpent(now)
+}
@@ -0,0 +1,64 @@
+package service
+
+import (
+    "context"
+    "time"
+
+    "github.com/gatij/goUrlShortener/internal/logging"
+    "github.com/gatij/goUrlShortener/internal/model"
+    "github.com/gatij/goUrlShortener/internal/storage/analytics"
+)
+
+const (
+    // DefaultStatsDays is how many days of daily clicks link stats cover by default
+    DefaultStatsDays = 30
+
+    // MaxStatsDays is the longest period of daily clicks link stats can cover
+    MaxStatsDays = 365
+
+    // DefaultStatsLimit is how many top referrers and user agents link stats list by default
+    DefaultStatsLimit = 5
+)
+
+// AnalyticsService handles click tracking and per-link analytics
+type AnalyticsService struct {
+    analyticsStore analytics.Storage // Click analytics storage
+}
+
+// NewAnalyticsService creates a new analytics service
+func NewAnalyticsService(analyticsStore analytics.Storage) *AnalyticsService {
+    return &AnalyticsService{
+        analyticsStore: analyticsStore,
+    }
+}
+
+// TrackClick records a click in the background so redirects aren't slowed
+// down by analytics. The request context is detached from cancellation so
+// the click isn't lost once the redirect has been sent.
+func (s *AnalyticsService) TrackClick(ctx context.Context, click model.Click) {
+    ctx = context.WithoutCancel(ctx)
+    go func() {
+        if err := s.analyticsStore.RecordClick(ctx, click); err != nil {
+            logging.FromContext(ctx).Error("Failed to record click", "short_code", click.ShortCode, "error", err)
+        }
+    }()
+}
+
+// GetLinkStats summarizes the clicks on link over the last days days, listing
+// at most limit top referrers and user agents. Clicks on earlier links that
+// had the same short code aren't counted.
+func (s *AnalyticsService) GetLinkStats(ctx context.Context, link model.URL, days, limit int) (model.LinkStats, error) {
+    if days <= 0 {
+        days = DefaultStatsDays
+    }
+    if days > MaxStatsDays {
+        days = MaxStatsDays
+    }
+    if limit <= 0 {
+        limit = DefaultStatsLimit
+    }
+
+    // Today counts as the first day
+    since := time.Now().UTC().AddDate(0, 0, -(days - 1))
+    return s.analyticsStore.GetLinkStats(ctx, link.ShortCode, link.CreatedAt, since, limit)
+}
@@ -0,0 +1,143 @@
+package service
+
+import (
+    "context"
+    "crypto/rand"
+    "crypto/sha256"
+    "crypto/subtle"
+    "encoding/base64"
+    "encoding/hex"
+    "errors"
+    "strings"
+    "time"
+
+    "github.com/gatij/goUrlShortener/internal/model"
+    "github.com/gatij/goUrlShortener/internal/storage/apikey"
+)
+
+var (
+    // ErrInvalidAPIKey is returned when a presented API key is unknown or revoked
+    ErrInvalidAPIKey = errors.New("invalid API key")
+
+    // ErrInvalidScope is returned when a new API key is given an unknown scope
+    ErrInvalidScope = errors.New("invalid API key scope")
+
+    // ErrInvalidKeyName is returned when a new API key has no name
+    ErrInvalidKeyName = errors.New("API key name is required")
+)
+
+const (
+    // apiKeyPrefix starts every generated secret so leaked keys are easy to spot
+    apiKeyPrefix = "gus_"
+
+    // BootstrapKeyID is the ID of the admin key configured with ADMIN_API_KEY.
+    // Generated IDs are hex, so they can never clash with it.
+    BootstrapKeyID = "admin"
+)
+
+// APIKeyService issues and checks API keys. Secrets are only ever returned
+// once, when the key is created; storage holds their SHA-256 hash. A plain
+// hash is enough since generated secrets are random rather than passwords.
+type APIKeyService struct {
+    keyStore  apikey.Storage
+    bootstrap *model.APIKey // Admin key from the configuration, if any
+}
+
+// NewAPIKeyService creates a new API key service. A non-empty adminKey is
+// accepted as an admin key without being stored, so operators always have a
+// way in to create the first keys and can rotate it by changing the setting.
+func NewAPIKeyService(keyStore apikey.Storage, adminKey string) *APIKeyService {
+    s := &APIKeyService{keyStore: keyStore}
+    if adminKey != "" {
+        s.bootstrap = &model.APIKey{
+            ID:    BootstrapKeyID,
+            Name:  "Configured admin key",
+            Hash:  hashAPIKey(adminKey),
+            Scope: model.ScopeAdmin,
+        }
+    }
+    return s
+}
+
+// hashAPIKey returns the stored form of a secret
+func hashAPIKey(secret string) string {
+    sum := sha256.Sum256([]byte(secret))
+    return hex.EncodeToString(sum[:])
+}
+
+// CreateKey issues a new API key and returns it along with its secret, which
+// can't be recovered later
+func (s *APIKeyService) CreateKey(ctx context.Context, name, scope string) (model.APIKey, string, error) {
+    name = strings.TrimSpace(name)
+    if name == "" {
+        return model.APIKey{}, "", ErrInvalidKeyName
+    }
+    if scope == "" {
+        scope = model.ScopeUser
+    }
+    if scope != model.ScopeUser && scope != model.ScopeAdmin {
+        return model.APIKey{}, "", ErrInvalidScope
+    }
+
+    id, err := randomToken(8, hex.EncodeToString)
+    if err != nil {
+        return model.APIKey{}, "", err
+    }
+    secret, err := randomToken(24, base64.RawURLEncoding.EncodeToString)
+    if err != nil {
+        return model.APIKey{}, "", err
+    }
+    secret = apiKeyPrefix + secret
+
+    key := model.APIKey{
+        ID:        id,
+        Name:      name,
+        Hash:      hashAPIKey(secret),
+        Scope:     scope,
+        CreatedAt: time.Now(),
+    }
+    if err := s.keyStore.Save(ctx, key); err != nil {
+        return model.APIKey{}, "", err
+    }
+
+    return key, secret, nil
+}
+
+// randomToken returns n random bytes in the given encoding
+func randomToken(n int, encode func([]byte) string) (string, error) {
+    b := make([]byte, n)
+    if _, err := rand.Read(b); err != nil {
+        return "", err
+    }
+    return encode(b), nil
+}
+
+// Authenticate returns the API key with the given secret. Returns
+// ErrInvalidAPIKey if there is none.
+func (s *APIKeyService) Authenticate(ctx context.Context, secret string) (model.APIKey, error) {
+    if secret == "" {
+        return model.APIKey{}, ErrInvalidAPIKey
+    }
+    hash := hashAPIKey(secret)
+
+    if s.bootstrap != nil && subtle.ConstantTimeCompare([]byte(hash), []byte(s.bootstrap.Hash)) == 1 {
+        return *s.bootstrap, nil
+    }
+
+    key, err := s.keyStore.GetByHash(ctx, hash)
+    if err == apikey.ErrKeyNotFound {
+        return model.APIKey{}, ErrInvalidAPIKey
+    }
+    return key, err
+}
+
+// ListKeys returns every stored API key, oldest first
+func (s *APIKeyService) ListKeys(ctx context.Context) ([]model.APIKey, error) {
+    return s.keyStore.List(ctx)
+}
+
+// RevokeKey deletes an API key so it can no longer authenticate. Links it
+// created keep it as their owner and remain manageable by admins.
+func (s *APIKeyService) RevokeKey(ctx context.Context, id string) error {
+    return s.keyStore.Delete(ctx, id)
+}
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/gatij/goUrlShortener/internal/model"
+	"github.com/gatij/goUrlShortener/internal/storage/apikey"
+)
+
+func TestAPIKeyService_CreateAuthenticateRevoke(t *testing.T) {
+	store := apikey.NewMemoryStorage()
+	service := NewAPIKeyService(store, "")
+	ctx := context.Background()
+
+	key, secret, err := service.CreateKey(ctx, "dashboard", "")
+	if err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	if key.Scope != model.ScopeUser {
+		t.Errorf("Expected default scope %s but got %s", model.ScopeUser, key.Scope)
+	}
+	if !strings.HasPrefix(secret, apiKeyPrefix) {
+		t.Errorf("Expected secret to start with %s but got %s", apiKeyPrefix, secret)
+	}
+
+	// Only the hash is stored
+	keys, _ := store.List(ctx)
+	if len(keys) != 1 || keys[0].Hash == secret || strings.Contains(keys[0].Hash, secret) {
+		t.Errorf("Expected only a hash of the secret to be stored but got %+v", keys)
+	}
+
+	authenticated, err := service.Authenticate(ctx, secret)
+	if err != nil {
+		t.Fatalf("Failed to authenticate: %v", err)
+	}
+	if authenticated.ID != key.ID {
+		t.Errorf("Expected key %s but got %s", key.ID, authenticated.ID)
+	}
+	if _, err := service.Authenticate(ctx, secret+"x"); err != ErrInvalidAPIKey {
+		t.Errorf("Expected ErrInvalidAPIKey for a wrong secret but got: %v", err)
+	}
+
+	if err := service.RevokeKey(ctx, key.ID); err != nil {
+		t.Fatalf("Failed to revoke key: %v", err)
+	}
+	if _, err := service.Authenticate(ctx, secret); err != ErrInvalidAPIKey {
+		t.Errorf("Expected ErrInvalidAPIKey after revoking but got: %v", err)
+	}
+}
+
+func TestAPIKeyService_CreateKeyValidation(t *testing.T) {
+	service := NewAPIKeyService(apikey.NewMemoryStorage(), "")
+	ctx := context.Background()
+
+	if _, _, err := service.CreateKey(ctx, "  ", model.ScopeUser); err != ErrInvalidKeyName {
+		t.Errorf("Expected ErrInvalidKeyName but got: %v", err)
+	}
+	if _, _, err := service.CreateKey(ctx, "ops", "root"); err != ErrInvalidScope {
+		t.Errorf("Expected ErrInvalidScope but got: %v", err)
+	}
+	if key, _, err := service.CreateKey(ctx, "ops", model.ScopeAdmin); err != nil || !key.IsAdmin() {
+		t.Errorf("Expected an admin key but got %+v (err: %v)", key, err)
+	}
+}
+
+func TestAPIKeyService_BootstrapKey(t *testing.T) {
+	store := apikey.NewMemoryStorage()
+	service := NewAPIKeyService(store, "configured-secret")
+	ctx := context.Background()
+
+	key, err := service.Authenticate(ctx, "configured-secret")
+	if err != nil {
+		t.Fatalf("Failed to authenticate with the configured key: %v", err)
+	}
+	if key.ID != BootstrapKeyID || !key.IsAdmin() {
+		t.Errorf("Expected the bootstrap admin key but got %+v", key)
+	}
+
+	// The configured key isn't stored, so changing the setting rotates it
+	if keys, _ := store.List(ctx); len(keys) != 0 {
+		t.Errorf("Expected no stored keys but got %+v", keys)
+	}
+	if _, err := NewAPIKeyService(store, "new-secret").Authenticate(ctx, "configured-secret"); err != ErrInvalidAPIKey {
+		t.Errorf("Expected the old configured key to stop working but got: %v", err)
+	}
+}
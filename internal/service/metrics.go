@@ -2,6 +2,7 @@ package service
 
 import (
     "context"
+    "sync/atomic"
 
     "github.com/gatij/goUrlShortener/internal/model"
     "github.com/gatij/goUrlShortener/internal/storage/metrics"
@@ -10,6 +11,11 @@ import (
 // MetricsService handles URL metrics operations
 type MetricsService struct {
     metricsStore metrics.Storage // Metrics storage
+
+    // Short code generation counters, kept per process
+    collisions  atomic.Int64
+    exhausted   atomic.Int64
+    escalations atomic.Int64
 }
 
 // NewMetricsService creates a new metrics service
@@ -30,23 +36,31 @@ func (s *MetricsService) GetTopDomains(ctx context.Context, limit int) ([]model.
 
 // IncrementDomainShortenCount increments the shorten count for a domain
 func (s *MetricsService) IncrementDomainShortenCount(ctx context.Context, domain string) error {
-    // Direct lookup - O(1) operation
-    metrics, exists, err := s.metricsStore.GetDomainMetrics(ctx, domain)
-    if err != nil {
-        return err
-    }
-    
-    if !exists {
-        // Create new metrics if not found
-        metrics = model.DomainMetrics{
-            Domain:      domain,
-            ShortenCount: 1, // Initialize with 1
-        }
-    } else {
-        // Increment counter for existing domain
-        metrics.ShortenCount++
+    // Let the storage perform the increment atomically so concurrent
+    // shortenings of the same domain are never lost
+    return s.metricsStore.IncrementDomainShortenCount(ctx, domain)
+}
+
+// RecordShortCodeCollision counts a generated short code that was already taken
+func (s *MetricsService) RecordShortCodeCollision() {
+    s.collisions.Add(1)
+}
+
+// RecordShortCodeExhausted counts a request that gave up after too many collisions
+func (s *MetricsService) RecordShortCodeExhausted() {
+    s.exhausted.Add(1)
+}
+
+// RecordCodeLengthEscalation counts an increase of the generated code length
+func (s *MetricsService) RecordCodeLengthEscalation() {
+    s.escalations.Add(1)
+}
+
+// GetShortCodeStats returns the short code generation counters
+func (s *MetricsService) GetShortCodeStats() model.ShortCodeStats {
+    return model.ShortCodeStats{
+        Collisions:  s.collisions.Load(),
+        Exhausted:   s.exhausted.Load(),
+        Escalations: s.escalations.Load(),
     }
-    
-    // Save updated metrics
-    return s.metricsStore.SaveDomainMetrics(ctx, metrics)
 }
@@ -28,6 +28,14 @@ func (m *MockMetricsStorage) GetDomainMetrics(ctx context.Context, domain string
 	return metrics, exists, nil
 }
 
+func (m *MockMetricsStorage) IncrementDomainShortenCount(ctx context.Context, domain string) error {
+	metrics := m.domains[domain]
+	metrics.Domain = domain
+	metrics.ShortenCount++
+	m.domains[domain] = metrics
+	return nil
+}
+
 func (m *MockMetricsStorage) GetTopDomains(ctx context.Context, limit int) ([]model.DomainMetrics, error) {
 	domains := make([]model.DomainMetrics, 0, len(m.domains))
 	for _, metrics := range m.domains {
@@ -3,8 +3,14 @@ package service
 import (
     "context"
     "errors"
+    "math"
+    "net/http"
+    neturl "net/url"
+    "sync/atomic"
     "time"
 
+    "golang.org/x/crypto/bcrypt"
+    "github.com/gatij/goUrlShortener/internal/logging"
     "github.com/gatij/goUrlShortener/internal/model"
     urlStorage "github.com/gatij/goUrlShortener/internal/storage/url"
     "github.com/gatij/goUrlShortener/pkg/utils"
@@ -13,12 +19,185 @@ import (
 var (
     // ErrInvalidURL is returned when the URL is invalid
     ErrInvalidURL = errors.New("invalid URL format")
+
+    // ErrInvalidExpiry is returned when a requested expiry is not in the future
+    ErrInvalidExpiry = errors.New("expiry must be in the future")
+
+    // ErrURLExpired is returned when a short code exists but its URL has expired
+    ErrURLExpired = errors.New("URL has expired")
+
+    // ErrInvalidAlias is returned when a custom alias has an invalid format
+    ErrInvalidAlias = errors.New("invalid alias format")
+
+    // ErrAliasReserved is returned when a custom alias clashes with a service route
+    ErrAliasReserved = errors.New("alias is reserved")
+
+    // ErrAliasTaken is returned when a custom alias is already in use
+    ErrAliasTaken = errors.New("alias is already taken")
+
+    // ErrURLAlreadyShortened is returned when an alias is requested for a URL
+    // that already has a different short code
+    ErrURLAlreadyShortened = errors.New("URL has already been shortened")
+
+    // ErrShortCodeUnavailable is returned when no unused short code was found
+    // within the configured number of attempts
+    ErrShortCodeUnavailable = errors.New("could not generate a unique short code")
+
+    // ErrDomainBlocked is returned when the domain policy refuses a URL's domain
+    ErrDomainBlocked = errors.New("domain is not allowed")
+
+    // ErrSelfLink is returned when a URL points back at this service, which
+    // would redirect to itself
+    ErrSelfLink = errors.New("cannot shorten links to this service")
+
+    // ErrShortenerChain is returned when a URL is a short link of another
+    // shortener and resolving those is turned off
+    ErrShortenerChain = errors.New("URL is a short link of another shortener")
+
+    // ErrResolveFailed is returned when another shortener's link could not be
+    // followed to its destination
+    ErrResolveFailed = errors.New("could not resolve short link")
+
+    // ErrForbidden is returned when an API key manages a URL created by another key
+    ErrForbidden = errors.New("URL belongs to another API key")
+
+    // ErrInvalidRedirectStatus is returned when a redirect status code isn't
+    // one of 301, 302, 307 or 308
+    ErrInvalidRedirectStatus = errors.New("invalid redirect status")
+
+    // ErrInvalidPassword is returned when a link password is too short or too long
+    ErrInvalidPassword = errors.New("invalid password length")
+
+    // ErrInvalidMaxClicks is returned when a click limit is negative
+    ErrInvalidMaxClicks = errors.New("max clicks must not be negative")
+
+    // ErrClickLimitReached is returned when a click-limited URL has used up
+    // all of its clicks
+    ErrClickLimitReached = errors.New("URL has reached its click limit")
+
+    // ErrInvalidSchedule is returned when an activation window doesn't end
+    // after it starts
+    ErrInvalidSchedule = errors.New("activation window must end after it starts")
+
+    // ErrLinkNotYetActive is returned when a URL's activation window hasn't started
+    ErrLinkNotYetActive = errors.New("URL is not active yet")
+
+    // ErrLinkClosed is returned when a URL's activation window has ended
+    ErrLinkClosed = errors.New("URL is no longer active")
+
+    // ErrInvalidFallbackURL is returned when a fallback URL is malformed,
+    // points back at this service or is on a blocked domain
+    ErrInvalidFallbackURL = errors.New("invalid fallback URL")
+)
+
+const (
+    // DefaultMaxGenerateAttempts is how many short codes are tried before giving up
+    DefaultMaxGenerateAttempts = 5
+
+    // DefaultEscalationThreshold is the fraction of the keyspace in use at which
+    // generated codes get one character longer
+    DefaultEscalationThreshold = 0.1
+
+    // DefaultListLimit is the page size used when a listing doesn't ask for one
+    DefaultListLimit = 20
+
+    // MaxListLimit is the largest page size a listing may ask for
+    MaxListLimit = 100
+
+    // MinPasswordLength and MaxPasswordLength bound link passwords in bytes.
+    // bcrypt ignores everything past 72 bytes.
+    MinPasswordLength = 4
+    MaxPasswordLength = 72
 )
 
 // ShortenerConfig contains configuration for the URL shortener service
 type ShortenerConfig struct {
     BaseURL    string // Base URL for generating short links (e.g., "https://short.io")
     CodeLength int    // Length of generated short codes
+
+    MaxGenerateAttempts int     // Short codes tried per request before giving up
+    EscalationThreshold float64 // Fraction of the keyspace in use that triggers longer codes
+    Generator           utils.CodeGenerator // Short code generation strategy (random nanoid if nil)
+    DomainPolicy        *utils.DomainPolicy // Which destination domains are allowed (all if nil)
+    LoopGuard           *utils.LoopGuard    // Detects self links and other shorteners (derived from BaseURL if nil)
+    Resolver            *utils.RedirectResolver // Resolves other shorteners' links (refused if nil)
+    FallbackRetention   time.Duration           // How long expired links with a fallback URL are kept (a week if zero)
+    Observer            Observer            // Told about links created, redirects and errors (none if nil)
+}
+
+// Observer is told what the shortener service does, for monitoring
+type Observer interface {
+    LinkCreated()
+    RedirectServed()
+    ShortenerError(operation string, err error)
+}
+
+// noopObserver is the Observer used when none is configured
+type noopObserver struct{}
+
+func (noopObserver) LinkCreated()                 {}
+func (noopObserver) RedirectServed()              {}
+func (noopObserver) ShortenerError(string, error) {}
+
+// CreateOptions contains optional settings for a new shortened URL
+type CreateOptions struct {
+    ExpiresAt *time.Time // When the short URL stops resolving (nil means never)
+    Alias     string     // Custom short code chosen by the user (empty to generate one)
+    Owner     string     // ID of the API key creating the URL (empty if anonymous)
+
+    RedirectStatus int  // Status code of the redirect (0 for the service default)
+    Preview        bool // Show the preview page on every visit instead of redirecting
+
+    Password  string // Password visitors must enter to follow the URL (empty for none)
+    MaxClicks int    // Redirects after which the URL stops resolving (0 for unlimited)
+
+    NotBefore *time.Time // When the URL starts redirecting (nil for straight away)
+    NotAfter  *time.Time // When the URL stops redirecting (nil for never)
+
+    FallbackURL string // Where visitors go once the URL can't be followed (empty for the service default)
+}
+
+// UpdateOptions contains the changes to make to a shortened URL. Each end of
+// the activation window is only changed if its Set flag is, and nil then
+// leaves that end open.
+type UpdateOptions struct {
+    Original string // New destination (empty to keep the current one)
+
+    SetNotBefore bool
+    NotBefore    *time.Time
+    SetNotAfter  bool
+    NotAfter     *time.Time
+
+    SetFallbackURL bool   // Replace the fallback URL, empty removing it
+    FallbackURL    string
+}
+
+// ValidSchedule reports whether notBefore and notAfter, where set, make a
+// window that ends after it starts
+func ValidSchedule(notBefore, notAfter *time.Time) bool {
+    return notBefore == nil || notAfter == nil || notAfter.After(*notBefore)
+}
+
+// CheckSchedule returns ErrLinkNotYetActive or ErrLinkClosed if url is
+// outside its activation window at now
+func CheckSchedule(url model.URL, now time.Time) error {
+    if url.IsNotYetActive(now) {
+        return ErrLinkNotYetActive
+    }
+    if url.IsClosed(now) {
+        return ErrLinkClosed
+    }
+    return nil
+}
+
+// IsValidRedirectStatus reports whether status is a redirect status code
+// short URLs may use: 301, 302, 307 or 308
+func IsValidRedirectStatus(status int) bool {
+    switch status {
+    case http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+        return true
+    }
+    return false
 }
 
 // ShortenerService handles URL shortening operations
@@ -26,6 +205,8 @@ type ShortenerService struct {
     urlStore      urlStorage.Storage
     metricsService *MetricsService
     config        ShortenerConfig
+
+    codeLength atomic.Int64 // Current length of generated codes, grows as the keyspace fills
 }
 
 // NewShortenerService creates a new shortener service
@@ -34,65 +215,576 @@ func NewShortenerService(
     metricsService *MetricsService, 
     config ShortenerConfig,
 ) *ShortenerService {
-    return &ShortenerService{
+    if config.CodeLength <= 0 {
+        config.CodeLength = utils.DefaultShortCodeLength
+    }
+    if config.MaxGenerateAttempts <= 0 {
+        config.MaxGenerateAttempts = DefaultMaxGenerateAttempts
+    }
+    if config.EscalationThreshold <= 0 {
+        config.EscalationThreshold = DefaultEscalationThreshold
+    }
+    if config.Generator == nil {
+        config.Generator, _ = utils.NewCodeGenerator(utils.GeneratorOptions{Strategy: utils.StrategyRandom})
+    }
+    if config.Observer == nil {
+        config.Observer = noopObserver{}
+    }
+    if config.FallbackRetention <= 0 {
+        config.FallbackRetention = urlStorage.DefaultFallbackRetention
+    }
+    if config.LoopGuard == nil {
+        config.LoopGuard = utils.NewLoopGuard(config.BaseURL, nil, utils.DefaultShortenerDomains)
+    }
+    
+    s := &ShortenerService{
         urlStore:      urlStore,
         metricsService: metricsService,
         config:        config,
     }
+    s.codeLength.Store(int64(config.CodeLength))
+    return s
+}
+
+// CodeLength returns the length currently used for generated short codes
+func (s *ShortenerService) CodeLength() int {
+    return int(s.codeLength.Load())
 }
 
 // CreateShortURL creates a new shortened URL
-func (s *ShortenerService) CreateShortURL(ctx context.Context, originalURL string) (model.URL, error) {
+func (s *ShortenerService) CreateShortURL(ctx context.Context, originalURL string, opts CreateOptions) (_ model.URL, err error) {
+    defer s.observeError("create", &err)
+    
+    now := time.Now()
+    
+    // Validate expiry
+    if opts.ExpiresAt != nil && !opts.ExpiresAt.After(now) {
+        return model.URL{}, ErrInvalidExpiry
+    }
+    
+    // Validate redirect status
+    if opts.RedirectStatus != 0 && !IsValidRedirectStatus(opts.RedirectStatus) {
+        return model.URL{}, ErrInvalidRedirectStatus
+    }
+    
+    // Validate password
+    if opts.Password != "" && (len(opts.Password) < MinPasswordLength || len(opts.Password) > MaxPasswordLength) {
+        return model.URL{}, ErrInvalidPassword
+    }
+    
+    // Validate click limit
+    if opts.MaxClicks < 0 {
+        return model.URL{}, ErrInvalidMaxClicks
+    }
+    
+    // Validate activation window
+    if !ValidSchedule(opts.NotBefore, opts.NotAfter) {
+        return model.URL{}, ErrInvalidSchedule
+    }
+    
+    // Validate fallback URL
+    fallbackURL, err := s.checkFallbackURL(opts.FallbackURL)
+    if err != nil {
+        return model.URL{}, err
+    }
+    
+    // Validate custom alias
+    if opts.Alias != "" {
+        if !utils.IsValidShortCode(opts.Alias) {
+            return model.URL{}, ErrInvalidAlias
+        }
+        if utils.IsReservedShortCode(opts.Alias) {
+            return model.URL{}, ErrAliasReserved
+        }
+    }
+    
     // Validate URL
-    urlInfo, err := utils.ProcessURL(originalURL, true)
+    urlInfo, err := s.processURL(ctx, originalURL)
     if err != nil {
-        return model.URL{}, ErrInvalidURL
+        return model.URL{}, err
     }
     
     // Use normalized URL with HTTPS
     normalizedURL := urlInfo.NormalizedURL
     
-    // Check if URL already exists in storage
-    existingURL, err := s.urlStore.GetByOriginalURL(ctx, normalizedURL)
-    if err == nil {
+    // Create URL record - using same value for ID and ShortCode
+    url := model.URL{
+        Original:  normalizedURL,
+        CreatedAt: now,
+        ExpiresAt: opts.ExpiresAt,
+        Owner:     opts.Owner,
+
+        RedirectStatus: opts.RedirectStatus,
+        Preview:        opts.Preview,
+        MaxClicks:      opts.MaxClicks,
+        NotBefore:      opts.NotBefore,
+        NotAfter:       opts.NotAfter,
+        FallbackURL:    fallbackURL,
+    }
+    if opts.Password != "" {
+        hash, err := bcrypt.GenerateFromPassword([]byte(opts.Password), bcrypt.DefaultCost)
+        if err != nil {
+            return model.URL{}, err
+        }
+        url.PasswordHash = string(hash)
+    }
+    
+    // Store the URL unless it was already shortened. This is a single atomic
+    // operation so concurrent requests for the same URL all get the same record.
+    stored, created, err := s.getOrCreate(ctx, url, opts.Alias, now)
+    if err == nil && !created && stored.IsReplaceable(now, s.config.FallbackRetention) {
+        // The storage keeps expired links for their fallback URL until the
+        // reaper gets to them, which this one has outlived
+        if err := s.release(ctx, stored); err != nil {
+            return model.URL{}, err
+        }
+        stored, created, err = s.getOrCreate(ctx, url, opts.Alias, now)
+    }
+    if err != nil {
+        return model.URL{}, err
+    }
+    
+    if !created {
+        // The URL can only have one short code, so a different alias is a conflict.
+        // The existing URL is returned so callers can report its short code.
+        if opts.Alias != "" && stored.ShortCode != opts.Alias {
+            return stored, ErrURLAlreadyShortened
+        }
+        
+        // Returning the existing link must neither drop a requested setting
+        // nor hand out one the caller didn't ask for, such as a password
+        if !s.matchesOptions(stored, opts, fallbackURL) {
+            return stored, ErrURLAlreadyShortened
+        }
+        
+        // A click budget belongs to a single link, so a click-limited link is
+        // never handed out twice and never replaces a request for another kind
+        if opts.MaxClicks > 0 || stored.MaxClicks > 0 {
+            return stored, ErrURLAlreadyShortened
+        }
+        
         // URL already exists, return it
         // No need to update metrics as it's not a new shortening
-        return existingURL, nil
-    } else if err != urlStorage.ErrURLNotFound {
-        // Unexpected error occurred
+        return stored, nil
+    }
+    
+    // Extract domain and update metrics asynchronously
+    // Only increment metrics for new URLs. The request context is detached
+    // from cancellation so the update isn't aborted once the response is sent.
+    s.config.Observer.LinkCreated()
+    domain := urlInfo.Domain
+    go s.metricsService.IncrementDomainShortenCount(context.WithoutCancel(ctx), domain)
+    
+    return stored, nil
+}
+
+// matchesOptions reports whether the existing link url has exactly the
+// per-link settings requested by opts, with fallbackURL as checked
+func (s *ShortenerService) matchesOptions(url model.URL, opts CreateOptions, fallbackURL string) bool {
+    if !sameTime(opts.ExpiresAt, url.ExpiresAt) || opts.RedirectStatus != url.RedirectStatus || opts.Preview != url.Preview {
+        return false
+    }
+    if opts.Password == "" && url.HasPassword() {
+        return false
+    }
+    if opts.Password != "" && (!url.HasPassword() || !s.CheckPassword(url, opts.Password)) {
+        return false
+    }
+    if !sameTime(opts.NotBefore, url.NotBefore) || !sameTime(opts.NotAfter, url.NotAfter) {
+        return false
+    }
+    return url.FallbackURL == fallbackURL
+}
+
+// sameTime reports whether two optional timestamps are both unset or equal
+func sameTime(a, b *time.Time) bool {
+    if a == nil || b == nil {
+        return a == b
+    }
+    return a.Equal(*b)
+}
+
+// processURL validates and normalizes a URL to shorten, checking its domain
+// against the loop guard and the domain policy. Short links of other
+// shorteners are replaced by their destination if a resolver is configured.
+func (s *ShortenerService) processURL(ctx context.Context, originalURL string) (utils.URLInfo, error) {
+    urlInfo, err := utils.ProcessURL(originalURL, true)
+    if err != nil {
+        return utils.URLInfo{}, ErrInvalidURL
+    }
+    
+    err = s.checkLoop(urlInfo)
+    if err == ErrShortenerChain && s.config.Resolver != nil {
+        final, resolveErr := s.config.Resolver.Resolve(ctx, urlInfo.OriginalURL)
+        if errors.Is(resolveErr, utils.ErrInfiniteLoopDetected) {
+            return utils.URLInfo{}, ErrSelfLink
+        }
+        if resolveErr != nil {
+            logging.FromContext(ctx).Warn("Failed to resolve short link", "url", urlInfo.OriginalURL, "error", resolveErr)
+            return utils.URLInfo{}, ErrResolveFailed
+        }
+        
+        // The destination is checked like any other URL, but not resolved again
+        urlInfo, err = utils.ProcessURL(final, true)
+        if err != nil {
+            return utils.URLInfo{}, ErrInvalidURL
+        }
+        err = s.checkLoop(urlInfo)
+    }
+    if err != nil {
+        return utils.URLInfo{}, err
+    }
+    
+    if err := s.config.DomainPolicy.Check(urlInfo.Domain); err != nil {
+        return utils.URLInfo{}, ErrDomainBlocked
+    }
+    
+    return urlInfo, nil
+}
+
+// checkFallbackURL validates and normalizes a fallback URL. An empty one is
+// left empty. Fallbacks at this service are refused, as they could send
+// visitors in circles between unavailable links.
+func (s *ShortenerService) checkFallbackURL(fallbackURL string) (string, error) {
+    if fallbackURL == "" {
+        return "", nil
+    }
+    
+    urlInfo, err := utils.ProcessURL(fallbackURL, true)
+    if err != nil || s.checkLoop(urlInfo) == ErrSelfLink || s.config.DomainPolicy.Check(urlInfo.Domain) != nil {
+        return "", ErrInvalidFallbackURL
+    }
+    return urlInfo.NormalizedURL, nil
+}
+
+// checkLoop returns ErrSelfLink if urlInfo points at this service and
+// ErrShortenerChain if it points at another shortener
+func (s *ShortenerService) checkLoop(urlInfo utils.URLInfo) error {
+    switch s.config.LoopGuard.Check(urlInfo.Domain) {
+    case utils.ErrInfiniteLoopDetected:
+        return ErrSelfLink
+    case utils.ErrShortenerChain:
+        return ErrShortenerChain
+    }
+    return nil
+}
+
+// createWithGeneratedCode stores url under a freshly generated short code.
+// A code that is already taken is a collision: another code is tried, up to
+// MaxGenerateAttempts times, and the code length grows once the keyspace for
+// the current length is filling up.
+func (s *ShortenerService) createWithGeneratedCode(ctx context.Context, url model.URL) (model.URL, bool, error) {
+    for attempt := 0; attempt < s.config.MaxGenerateAttempts; attempt++ {
+        length := s.CodeLength()
+        shortCode, err := s.config.Generator.Generate(ctx, utils.CodeRequest{
+            OriginalURL: url.Original,
+            Length:      length,
+            Attempt:     attempt,
+        })
+        if err != nil {
+            return model.URL{}, false, err
+        }
+        
+        url.ID = shortCode // Using shortCode as ID
+        url.ShortCode = shortCode
+        stored, created, err := s.urlStore.GetOrCreate(ctx, url)
+        if err != urlStorage.ErrURLExists {
+            return stored, created, err
+        }
+        
+        // The code belongs to a different URL, try again
+        s.metricsService.RecordShortCodeCollision()
+        if err := s.maybeEscalateCodeLength(ctx, length); err != nil {
+            return model.URL{}, false, err
+        }
+    }
+    
+    s.metricsService.RecordShortCodeExhausted()
+    return model.URL{}, false, ErrShortCodeUnavailable
+}
+
+// maybeEscalateCodeLength increases the generated code length by one when the
+// stored URLs fill more than EscalationThreshold of the keyspace for length.
+// Only the first caller that observes length moves it on.
+func (s *ShortenerService) maybeEscalateCodeLength(ctx context.Context, length int) error {
+    if length >= utils.MaxShortCodeLength {
+        return nil
+    }
+    
+    count, err := s.urlStore.Count(ctx)
+    if err != nil {
+        return err
+    }
+    
+    keyspace := math.Pow(float64(len(s.config.Generator.Alphabet())), float64(length))
+    if float64(count)/keyspace < s.config.EscalationThreshold {
+        return nil
+    }
+    
+    if s.codeLength.CompareAndSwap(int64(length), int64(length+1)) {
+        s.metricsService.RecordCodeLengthEscalation()
+        logging.FromContext(ctx).Info("Short code keyspace filling up, increasing code length",
+            "keyspace_used", float64(count)/keyspace, "code_length", length+1)
+    }
+    return nil
+}
+
+// getOrCreate stores url under alias, or under a generated short code if
+// alias is empty, unless its original URL was already shortened
+func (s *ShortenerService) getOrCreate(ctx context.Context, url model.URL, alias string, now time.Time) (model.URL, bool, error) {
+    if alias == "" {
+        return s.createWithGeneratedCode(ctx, url)
+    }
+    
+    if err := s.releaseExpiredAlias(ctx, alias, now); err != nil {
+        return model.URL{}, false, err
+    }
+    url.ID = alias // Using shortCode as ID
+    url.ShortCode = alias
+    stored, created, err := s.urlStore.GetOrCreate(ctx, url)
+    if err == urlStorage.ErrURLExists {
+        return model.URL{}, false, ErrAliasTaken
+    }
+    return stored, created, err
+}
+
+// releaseExpiredAlias removes an expired or used up URL still holding the
+// alias so it can be reused. A live holder, or one still kept for its
+// fallback URL, is left alone and reported by GetOrCreate.
+func (s *ShortenerService) releaseExpiredAlias(ctx context.Context, alias string, now time.Time) error {
+    existing, err := s.urlStore.GetByShortCode(ctx, alias)
+    if err == urlStorage.ErrURLNotFound {
+        return nil
+    }
+    if err != nil {
+        return err
+    }
+    
+    if !existing.IsReplaceable(now, s.config.FallbackRetention) {
+        return nil
+    }
+    return s.release(ctx, existing)
+}
+
+// release deletes a URL that makes way for a new link, unless someone else
+// deleted it first
+func (s *ShortenerService) release(ctx context.Context, url model.URL) error {
+    if err := s.urlStore.Delete(ctx, url.ID); err != nil && err != urlStorage.ErrURLNotFound {
+        return err
+    }
+    return nil
+}
+
+// GetURL retrieves a URL by its short code. Returns ErrURLExpired if the URL
+// has expired but not yet been purged by the reaper, ErrClickLimitReached if
+// it has used up its clicks, ErrDomainBlocked if the domain policy has
+// refused its domain since it was shortened, and ErrLinkNotYetActive or
+// ErrLinkClosed outside its activation window. The URL is returned along with
+// any of these errors, so callers can use its fallback URL.
+func (s *ShortenerService) GetURL(ctx context.Context, shortCode string) (_ model.URL, err error) {
+    defer s.observeError("redirect", &err)
+    
+    url, err := s.GetActiveURL(ctx, shortCode)
+    if err != nil {
+        return url, err
+    }
+    
+    if err := CheckSchedule(url, time.Now()); err != nil {
+        return url, err
+    }
+    
+    return url, nil
+}
+
+// RedirectServed tells the observer that a visitor was redirected to a link's
+// destination. The redirect handler calls it once the redirect is written, so
+// password forms, preview pages and clicks beyond the limit aren't counted.
+func (s *ShortenerService) RedirectServed() {
+    s.config.Observer.RedirectServed()
+}
+
+// GetActiveURL retrieves a URL that can be followed, like GetURL, but without
+// checking its activation window. It is meant for pages
+// about a link, such as its QR code, which may be shared before launch.
+func (s *ShortenerService) GetActiveURL(ctx context.Context, shortCode string) (model.URL, error) {
+    url, err := s.urlStore.GetByShortCode(ctx, shortCode)
+    if err != nil {
         return model.URL{}, err
     }
     
-    // URL doesn't exist, create a new short code
-    shortCode, err := utils.GenerateShortCode(s.config.CodeLength)
+    if url.IsExpired(time.Now()) {
+        return url, ErrURLExpired
+    }
+    
+    if url.ClicksExhausted() {
+        return url, ErrClickLimitReached
+    }
+    
+    if parsed, err := neturl.Parse(url.Original); err == nil && s.config.DomainPolicy.Check(parsed.Host) != nil {
+        return url, ErrDomainBlocked
+    }
+    
+    return url, nil
+}
+
+// ConsumeClick spends one click of a click-limited url and returns it with the
+// new count, or ErrClickLimitReached if another visitor spent the last one
+// first. URLs without a limit are returned as they are.
+func (s *ShortenerService) ConsumeClick(ctx context.Context, url model.URL) (_ model.URL, err error) {
+    defer s.observeError("redirect", &err)
+    
+    if url.MaxClicks == 0 {
+        return url, nil
+    }
+    
+    url, err = s.urlStore.ConsumeClick(ctx, url.ID)
+    if err == urlStorage.ErrClicksExhausted {
+        return model.URL{}, ErrClickLimitReached
+    }
+    return url, err
+}
+
+// CheckPassword reports whether password opens url. URLs without a password
+// don't need one.
+func (s *ShortenerService) CheckPassword(url model.URL, password string) bool {
+    if !url.HasPassword() {
+        return true
+    }
+    return bcrypt.CompareHashAndPassword([]byte(url.PasswordHash), []byte(password)) == nil
+}
+
+// LinkWarnings returns reasons to look closely at where url leads before
+// following it, such as an IP address destination or another shortener
+func (s *ShortenerService) LinkWarnings(url model.URL) []string {
+    warnings := utils.LinkWarnings(url.Original)
+    if parsed, err := neturl.Parse(url.Original); err == nil && s.config.LoopGuard.IsShortener(parsed.Host) {
+        warnings = append(warnings, "The destination is another URL shortener, so it may redirect somewhere else again.")
+    }
+    return warnings
+}
+
+// GetLink retrieves a URL by its short code for management by caller. Unlike
+// GetURL it also returns URLs that have expired but not yet been purged.
+// Returns ErrForbidden unless caller created the URL or is an admin.
+func (s *ShortenerService) GetLink(ctx context.Context, caller model.APIKey, shortCode string) (model.URL, error) {
+    url, err := s.urlStore.GetByShortCode(ctx, shortCode)
     if err != nil {
         return model.URL{}, err
     }
     
-    // Create URL record - using same value for ID and ShortCode
-    url := model.URL{
-        ID:        shortCode, // Using shortCode as ID
-        ShortCode: shortCode,
-        Original:  normalizedURL,
-        CreatedAt: time.Now(),
+    if !canManage(caller, url) {
+        return model.URL{}, ErrForbidden
+    }
+    
+    return url, nil
+}
+
+// canManage reports whether caller may manage url. Admins manage every URL,
+// other keys only the URLs they created. Anonymous URLs belong to admins only.
+func canManage(caller model.APIKey, url model.URL) bool {
+    return caller.IsAdmin() || caller.ID != "" && url.Owner == caller.ID
+}
+
+// UpdateURL points an existing short code at a new original URL. If the new
+// URL already has a different short code, that URL is returned along with
+// ErrURLAlreadyShortened.
+func (s *ShortenerService) UpdateURL(ctx context.Context, caller model.APIKey, shortCode, originalURL string) (model.URL, error) {
+    return s.UpdateLink(ctx, caller, shortCode, UpdateOptions{Original: originalURL})
+}
+
+// UpdateLink makes the changes in opts to an existing short code, like
+// UpdateURL. Returns ErrInvalidSchedule if the resulting activation window
+// doesn't end after it starts and ErrInvalidFallbackURL for a fallback URL
+// that can't be used.
+func (s *ShortenerService) UpdateLink(ctx context.Context, caller model.APIKey, shortCode string, opts UpdateOptions) (_ model.URL, err error) {
+    defer s.observeError("update", &err)
+    
+    // Validate URL
+    var urlInfo utils.URLInfo
+    if opts.Original != "" {
+        if urlInfo, err = s.processURL(ctx, opts.Original); err != nil {
+            return model.URL{}, err
+        }
     }
     
-    // Save URL
-    if err := s.urlStore.Save(ctx, url); err != nil {
+    url, err := s.GetLink(ctx, caller, shortCode)
+    if err != nil {
         return model.URL{}, err
     }
     
-    // Extract domain and update metrics asynchronously
-    // Only increment metrics for new URLs
-    domain := urlInfo.Domain
-    go s.metricsService.IncrementDomainShortenCount(ctx, domain)
+    if opts.Original != "" {
+        url.Original = urlInfo.NormalizedURL
+    }
+    if opts.SetNotBefore {
+        url.NotBefore = opts.NotBefore
+    }
+    if opts.SetNotAfter {
+        url.NotAfter = opts.NotAfter
+    }
+    if opts.SetFallbackURL {
+        if url.FallbackURL, err = s.checkFallbackURL(opts.FallbackURL); err != nil {
+            return model.URL{}, err
+        }
+    }
+    if !ValidSchedule(url.NotBefore, url.NotAfter) {
+        return model.URL{}, ErrInvalidSchedule
+    }
+    
+    err = s.urlStore.Update(ctx, url)
+    if err == urlStorage.ErrOriginalURLExists {
+        existing, lookupErr := s.urlStore.GetByOriginalURL(ctx, url.Owner, url.Original)
+        if lookupErr != nil {
+            return model.URL{}, lookupErr
+        }
+        return existing, ErrURLAlreadyShortened
+    }
+    if err != nil {
+        return model.URL{}, err
+    }
     
     return url, nil
 }
 
-// GetURL retrieves a URL by its short code
-func (s *ShortenerService) GetURL(ctx context.Context, shortCode string) (model.URL, error) {
-    return s.urlStore.GetByShortCode(ctx, shortCode)
+// DeleteURL removes a short URL on behalf of caller
+func (s *ShortenerService) DeleteURL(ctx context.Context, caller model.APIKey, shortCode string) (err error) {
+    defer s.observeError("delete", &err)
+    
+    url, err := s.GetLink(ctx, caller, shortCode)
+    if err != nil {
+        return err
+    }
+    
+    return s.urlStore.Delete(ctx, url.ID)
+}
+
+// ListURLs returns a page of short URLs matching opts that caller may manage.
+// Admins see every URL and may filter by owner; other keys only see their own.
+// The page size defaults to DefaultListLimit and is capped at MaxListLimit.
+func (s *ShortenerService) ListURLs(ctx context.Context, caller model.APIKey, opts urlStorage.ListOptions) (_ urlStorage.ListResult, err error) {
+    defer s.observeError("list", &err)
+    
+    if !caller.IsAdmin() {
+        if caller.ID == "" {
+            return urlStorage.ListResult{}, ErrForbidden
+        }
+        opts.Owner = caller.ID
+    }
+    if opts.Limit <= 0 {
+        opts.Limit = DefaultListLimit
+    }
+    if opts.Limit > MaxListLimit {
+        opts.Limit = MaxListLimit
+    }
+    
+    return s.urlStore.List(ctx, opts)
+}
+
+// observeError tells the observer about *err, if the operation failed. It is
+// deferred with a pointer to the named error result.
+func (s *ShortenerService) observeError(operation string, err *error) {
+    if *err != nil {
+        s.config.Observer.ShortenerError(operation, *err)
+    }
 }
 
 // GenerateShortURL creates the full shortened URL given a short code
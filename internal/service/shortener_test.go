@@ -2,10 +2,15 @@ package service
 
 import (
 	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/gatij/goUrlShortener/internal/model"
+	"github.com/gatij/goUrlShortener/internal/storage/metrics"
 	"github.com/gatij/goUrlShortener/internal/storage/url"
 	"github.com/gatij/goUrlShortener/pkg/utils"
 )
@@ -29,6 +34,16 @@ func (m *MockURLStorage) Save(ctx context.Context, urlObj model.URL) error {
 	return nil
 }
 
+func (m *MockURLStorage) GetOrCreate(ctx context.Context, urlObj model.URL) (model.URL, bool, error) {
+	if existing, err := m.GetByOriginalURL(ctx, urlObj.Owner, urlObj.Original); err == nil {
+		return existing, false, nil
+	}
+	if err := m.Save(ctx, urlObj); err != nil {
+		return model.URL{}, false, err
+	}
+	return urlObj, true, nil
+}
+
 func (m *MockURLStorage) GetByID(ctx context.Context, id string) (model.URL, error) {
 	urlObj, exists := m.urls[id]
 	if !exists {
@@ -46,15 +61,36 @@ func (m *MockURLStorage) GetByShortCode(ctx context.Context, shortCode string) (
 	return model.URL{}, url.ErrURLNotFound
 }
 
-func (m *MockURLStorage) GetByOriginalURL(ctx context.Context, originalURL string) (model.URL, error) {
+func (m *MockURLStorage) GetByOriginalURL(ctx context.Context, owner, originalURL string) (model.URL, error) {
 	for _, urlObj := range m.urls {
-		if urlObj.Original == originalURL {
+		if urlObj.Owner == owner && urlObj.Original == originalURL {
 			return urlObj, nil
 		}
 	}
 	return model.URL{}, url.ErrURLNotFound
 }
 
+func (m *MockURLStorage) Update(ctx context.Context, urlObj model.URL) error {
+	if _, exists := m.urls[urlObj.ID]; !exists {
+		return url.ErrURLNotFound
+	}
+	m.urls[urlObj.ID] = urlObj
+	return nil
+}
+
+func (m *MockURLStorage) ConsumeClick(ctx context.Context, id string) (model.URL, error) {
+	urlObj, exists := m.urls[id]
+	if !exists {
+		return model.URL{}, url.ErrURLNotFound
+	}
+	if urlObj.ClicksExhausted() {
+		return model.URL{}, url.ErrClicksExhausted
+	}
+	urlObj.Clicks++
+	m.urls[id] = urlObj
+	return urlObj, nil
+}
+
 func (m *MockURLStorage) Delete(ctx context.Context, id string) error {
 	if _, exists := m.urls[id]; !exists {
 		return url.ErrURLNotFound
@@ -63,6 +99,32 @@ func (m *MockURLStorage) Delete(ctx context.Context, id string) error {
 	return nil
 }
 
+func (m *MockURLStorage) List(ctx context.Context, opts url.ListOptions) (url.ListResult, error) {
+	var result url.ListResult
+	for _, urlObj := range m.urls {
+		if len(result.URLs) == opts.Limit {
+			break
+		}
+		result.URLs = append(result.URLs, urlObj)
+	}
+	return result, nil
+}
+
+func (m *MockURLStorage) Count(ctx context.Context) (int, error) {
+	return len(m.urls), nil
+}
+
+func (m *MockURLStorage) DeleteExpired(ctx context.Context, now time.Time, fallbackRetention time.Duration) (int, error) {
+	removed := 0
+	for id, urlObj := range m.urls {
+		if urlObj.IsExpired(now) && urlObj.IsReplaceable(now, fallbackRetention) {
+			delete(m.urls, id)
+			removed++
+		}
+	}
+	return removed, nil
+}
+
 // We need to modify the ShortenerService to work with our mock metrics service
 // This is a modified version of the original ShortenerService with a more generic metrics service interface
 type TestShortenerService struct {
@@ -99,7 +161,7 @@ func (s *TestShortenerService) CreateShortURL(ctx context.Context, originalURL s
 	}
 
 	// Check if URL already exists in storage
-	existingURL, err := s.urlStore.GetByOriginalURL(ctx, originalURL)
+	existingURL, err := s.urlStore.GetByOriginalURL(ctx, "", originalURL)
 	if err == nil {
 		// URL already exists, return it
 		return existingURL, nil
@@ -272,3 +334,691 @@ func TestShortenerService_GenerateShortURL(t *testing.T) {
 		t.Errorf("Expected short URL to be %s but got %s", expected, shortURL)
 	}
 }
+
+// newShortenerService creates a real shortener service backed by in-memory storage
+func newShortenerService() (*ShortenerService, *url.MemoryStorage) {
+	urlStore := url.NewMemoryStorage()
+	metricsService := NewMetricsService(metrics.NewMemoryStorage())
+	config := ShortenerConfig{
+		BaseURL:    "http://localhost:3000",
+		CodeLength: 6,
+	}
+	return NewShortenerService(urlStore, metricsService, config), urlStore
+}
+
+func TestShortenerService_CreateShortURLWithExpiry(t *testing.T) {
+	service, urlStore := newShortenerService()
+	ctx := context.Background()
+
+	// Expiry in the past is rejected
+	past := time.Now().Add(-time.Minute)
+	if _, err := service.CreateShortURL(ctx, "https://github.com/user/repo", CreateOptions{ExpiresAt: &past}); err != ErrInvalidExpiry {
+		t.Errorf("Expected ErrInvalidExpiry but got: %v", err)
+	}
+
+	expiresAt := time.Now().Add(time.Hour)
+	created, err := service.CreateShortURL(ctx, "https://github.com/user/repo", CreateOptions{ExpiresAt: &expiresAt})
+	if err != nil {
+		t.Fatalf("Failed to create short URL: %v", err)
+	}
+	if created.ExpiresAt == nil || !created.ExpiresAt.Equal(expiresAt) {
+		t.Errorf("Expected expiry %v but got %v", expiresAt, created.ExpiresAt)
+	}
+
+	if _, err := service.GetURL(ctx, created.ShortCode); err != nil {
+		t.Errorf("Expected unexpired URL to resolve but got: %v", err)
+	}
+
+	// Force the stored URL into the past, as if time had moved on
+	stored, _ := urlStore.GetByID(ctx, created.ID)
+	urlStore.Delete(ctx, created.ID)
+	stored.ExpiresAt = &past
+	urlStore.Save(ctx, stored)
+
+	if _, err := service.GetURL(ctx, created.ShortCode); err != ErrURLExpired {
+		t.Errorf("Expected ErrURLExpired but got: %v", err)
+	}
+
+	// Shortening the same URL again replaces the expired entry
+	recreated, err := service.CreateShortURL(ctx, "https://github.com/user/repo", CreateOptions{})
+	if err != nil {
+		t.Fatalf("Failed to reshorten expired URL: %v", err)
+	}
+	if recreated.ShortCode == created.ShortCode {
+		t.Errorf("Expected a new short code for the expired URL")
+	}
+	if _, err := service.GetURL(ctx, recreated.ShortCode); err != nil {
+		t.Errorf("Expected reshortened URL to resolve but got: %v", err)
+	}
+}
+
+func TestShortenerService_CreateShortURLWithRedirectStatus(t *testing.T) {
+	service, _ := newShortenerService()
+	ctx := context.Background()
+
+	for _, status := range []int{200, 303, 404} {
+		if _, err := service.CreateShortURL(ctx, "https://github.com/user/repo", CreateOptions{RedirectStatus: status}); err != ErrInvalidRedirectStatus {
+			t.Errorf("Status %d: expected ErrInvalidRedirectStatus but got: %v", status, err)
+		}
+	}
+
+	created, err := service.CreateShortURL(ctx, "https://github.com/user/repo", CreateOptions{RedirectStatus: http.StatusTemporaryRedirect})
+	if err != nil {
+		t.Fatalf("Failed to create short URL: %v", err)
+	}
+	got, err := service.GetURL(ctx, created.ShortCode)
+	if err != nil || got.RedirectStatus != http.StatusTemporaryRedirect {
+		t.Errorf("Expected redirect status 307 to be stored but got %d (err: %v)", got.RedirectStatus, err)
+	}
+}
+
+func TestShortenerService_CreateShortURLWithPassword(t *testing.T) {
+	service, urlStore := newShortenerService()
+	ctx := context.Background()
+
+	for _, password := range []string{"abc", string(make([]byte, MaxPasswordLength+1))} {
+		if _, err := service.CreateShortURL(ctx, "https://github.com/user/repo", CreateOptions{Password: password}); err != ErrInvalidPassword {
+			t.Errorf("Password of %d bytes: expected ErrInvalidPassword but got: %v", len(password), err)
+		}
+	}
+
+	created, err := service.CreateShortURL(ctx, "https://github.com/user/repo", CreateOptions{Password: "s3cret!"})
+	if err != nil {
+		t.Fatalf("Failed to create short URL: %v", err)
+	}
+	stored, _ := urlStore.GetByID(ctx, created.ID)
+	if !stored.HasPassword() || stored.PasswordHash == "s3cret!" {
+		t.Fatalf("Expected a password hash to be stored but got %q", stored.PasswordHash)
+	}
+	if !service.CheckPassword(stored, "s3cret!") {
+		t.Error("Expected the right password to be accepted")
+	}
+	if service.CheckPassword(stored, "wrong") {
+		t.Error("Expected a wrong password to be refused")
+	}
+	if !service.CheckPassword(model.URL{}, "") {
+		t.Error("Expected links without a password to be open")
+	}
+
+	// The same password gets the same link, anything else can't reuse it
+	if again, err := service.CreateShortURL(ctx, "https://github.com/user/repo", CreateOptions{Password: "s3cret!"}); err != nil || again.ShortCode != created.ShortCode {
+		t.Errorf("Expected the existing link but got %+v (err: %v)", again, err)
+	}
+	if _, err := service.CreateShortURL(ctx, "https://github.com/user/repo", CreateOptions{Password: "other!"}); err != ErrURLAlreadyShortened {
+		t.Errorf("Expected ErrURLAlreadyShortened for another password but got: %v", err)
+	}
+	open, _ := service.CreateShortURL(ctx, "https://github.com/user/other", CreateOptions{})
+	if _, err := service.CreateShortURL(ctx, "https://github.com/user/other", CreateOptions{Password: "s3cret!"}); err != ErrURLAlreadyShortened {
+		t.Errorf("Expected ErrURLAlreadyShortened for a link without a password but got: %v", err)
+	}
+	if open.HasPassword() {
+		t.Error("Expected the link without a password to stay open")
+	}
+}
+
+func TestShortenerService_CreateShortURLWithMaxClicks(t *testing.T) {
+	service, _ := newShortenerService()
+	ctx := context.Background()
+
+	if _, err := service.CreateShortURL(ctx, "https://github.com/user/repo", CreateOptions{MaxClicks: -1}); err != ErrInvalidMaxClicks {
+		t.Errorf("Expected ErrInvalidMaxClicks but got: %v", err)
+	}
+
+	created, err := service.CreateShortURL(ctx, "https://github.com/user/repo", CreateOptions{MaxClicks: 1})
+	if err != nil || created.MaxClicks != 1 {
+		t.Fatalf("Expected a one-time link but got %+v (err: %v)", created, err)
+	}
+
+	// Click budgets aren't shared with other requests for the same URL
+	if _, err := service.CreateShortURL(ctx, "https://github.com/user/repo", CreateOptions{MaxClicks: 1}); err != ErrURLAlreadyShortened {
+		t.Errorf("Expected ErrURLAlreadyShortened for a second one-time link but got: %v", err)
+	}
+	if _, err := service.CreateShortURL(ctx, "https://github.com/user/repo", CreateOptions{}); err != ErrURLAlreadyShortened {
+		t.Errorf("Expected ErrURLAlreadyShortened for an unlimited link but got: %v", err)
+	}
+
+	link, err := service.GetURL(ctx, created.ShortCode)
+	if err != nil {
+		t.Fatalf("Expected the link to resolve before its click but got: %v", err)
+	}
+	if link, err = service.ConsumeClick(ctx, link); err != nil || link.Clicks != 1 {
+		t.Fatalf("Expected the click to be counted but got %+v (err: %v)", link, err)
+	}
+	if _, err := service.ConsumeClick(ctx, link); err != ErrClickLimitReached {
+		t.Errorf("Expected ErrClickLimitReached for a second click but got: %v", err)
+	}
+	if _, err := service.GetURL(ctx, created.ShortCode); err != ErrClickLimitReached {
+		t.Errorf("Expected ErrClickLimitReached after the last click but got: %v", err)
+	}
+
+	// Links without a limit aren't counted in storage
+	open, _ := service.CreateShortURL(ctx, "https://github.com/user/other", CreateOptions{})
+	if open, err = service.ConsumeClick(ctx, open); err != nil || open.Clicks != 0 {
+		t.Errorf("Expected unlimited link to be left alone but got %+v (err: %v)", open, err)
+	}
+}
+
+func TestShortenerService_Schedule(t *testing.T) {
+	service, _ := newShortenerService()
+	ctx := context.Background()
+	now := time.Now()
+	launch := now.Add(time.Hour)
+	earlier := now.Add(-time.Hour)
+
+	if _, err := service.CreateShortURL(ctx, "https://github.com/user/repo", CreateOptions{NotBefore: &launch, NotAfter: &earlier}); err != ErrInvalidSchedule {
+		t.Errorf("Expected ErrInvalidSchedule but got: %v", err)
+	}
+
+	created, err := service.CreateShortURL(ctx, "https://github.com/user/repo", CreateOptions{NotBefore: &launch})
+	if err != nil {
+		t.Fatalf("Failed to create short URL: %v", err)
+	}
+	if _, err := service.CreateShortURL(ctx, "https://github.com/user/repo", CreateOptions{NotAfter: &launch}); err != ErrURLAlreadyShortened {
+		t.Errorf("Expected ErrURLAlreadyShortened for another window but got: %v", err)
+	}
+
+	// Before launch the link is returned so the caller can say when it starts
+	link, err := service.GetURL(ctx, created.ShortCode)
+	if err != ErrLinkNotYetActive || link.ShortCode != created.ShortCode {
+		t.Errorf("Expected ErrLinkNotYetActive with the link but got %+v (err: %v)", link, err)
+	}
+	if _, err := service.GetActiveURL(ctx, created.ShortCode); err != nil {
+		t.Errorf("Expected pages about the link to work before launch but got: %v", err)
+	}
+
+	// Opening the start and closing the end
+	updated, err := service.UpdateLink(ctx, admin, created.ShortCode, UpdateOptions{SetNotBefore: true, SetNotAfter: true, NotAfter: &earlier})
+	if err != nil || updated.NotBefore != nil || updated.Original != created.Original {
+		t.Fatalf("Expected only the window to change but got %+v (err: %v)", updated, err)
+	}
+	if _, err := service.GetURL(ctx, created.ShortCode); err != ErrLinkClosed {
+		t.Errorf("Expected ErrLinkClosed but got: %v", err)
+	}
+
+	// The window is checked as a whole
+	if _, err := service.UpdateLink(ctx, admin, created.ShortCode, UpdateOptions{SetNotBefore: true, NotBefore: &now}); err != ErrInvalidSchedule {
+		t.Errorf("Expected ErrInvalidSchedule but got: %v", err)
+	}
+	if _, err := service.UpdateLink(ctx, admin, created.ShortCode, UpdateOptions{SetNotAfter: true}); err != nil {
+		t.Fatalf("Failed to reopen URL: %v", err)
+	}
+	if _, err := service.GetURL(ctx, created.ShortCode); err != nil {
+		t.Errorf("Expected reopened URL to resolve but got: %v", err)
+	}
+}
+
+func TestShortenerService_FallbackURL(t *testing.T) {
+	service, urlStore := newShortenerService()
+	ctx := context.Background()
+
+	for _, fallback := range []string{"not a url", "ftp://example.com/", "http://localhost:3000/abc123"} {
+		if _, err := service.CreateShortURL(ctx, "https://github.com/user/repo", CreateOptions{FallbackURL: fallback}); err != ErrInvalidFallbackURL {
+			t.Errorf("%s: expected ErrInvalidFallbackURL but got: %v", fallback, err)
+		}
+	}
+
+	created, err := service.CreateShortURL(ctx, "https://github.com/user/repo", CreateOptions{FallbackURL: "http://example.com/gone"})
+	if err != nil {
+		t.Fatalf("Failed to create short URL: %v", err)
+	}
+	if created.FallbackURL != "https://example.com/gone" {
+		t.Errorf("Expected the fallback URL to be normalized but got %s", created.FallbackURL)
+	}
+	if _, err := service.CreateShortURL(ctx, "https://github.com/user/repo", CreateOptions{FallbackURL: "https://example.com/other"}); err != ErrURLAlreadyShortened {
+		t.Errorf("Expected ErrURLAlreadyShortened for another fallback but got: %v", err)
+	}
+
+	// An expired link is returned so the caller can use its fallback
+	past := time.Now().Add(-time.Minute)
+	stored, _ := urlStore.GetByID(ctx, created.ID)
+	urlStore.Delete(ctx, created.ID)
+	stored.ExpiresAt = &past
+	urlStore.Save(ctx, stored)
+	link, err := service.GetURL(ctx, created.ShortCode)
+	if err != ErrURLExpired || link.FallbackURL != "https://example.com/gone" {
+		t.Errorf("Expected ErrURLExpired with the link but got %+v (err: %v)", link, err)
+	}
+
+	updated, err := service.UpdateLink(ctx, admin, created.ShortCode, UpdateOptions{SetFallbackURL: true})
+	if err != nil || updated.FallbackURL != "" || updated.Original != created.Original {
+		t.Errorf("Expected only the fallback URL to be removed but got %+v (err: %v)", updated, err)
+	}
+	if _, err := service.UpdateLink(ctx, admin, created.ShortCode, UpdateOptions{SetFallbackURL: true, FallbackURL: "http://localhost:3000/x"}); err != ErrInvalidFallbackURL {
+		t.Errorf("Expected ErrInvalidFallbackURL but got: %v", err)
+	}
+}
+
+func TestShortenerService_CreateShortURLWithAlias(t *testing.T) {
+	service, _ := newShortenerService()
+	ctx := context.Background()
+
+	created, err := service.CreateShortURL(ctx, "https://github.com/launch", CreateOptions{Alias: "launch2026"})
+	if err != nil {
+		t.Fatalf("Failed to create short URL with alias: %v", err)
+	}
+	if created.ShortCode != "launch2026" || created.ID != "launch2026" {
+		t.Errorf("Expected short code launch2026 but got %s", created.ShortCode)
+	}
+	if _, err := service.GetURL(ctx, "launch2026"); err != nil {
+		t.Errorf("Expected alias to resolve but got: %v", err)
+	}
+
+	// Requesting the same alias for the same URL returns the existing entry
+	again, err := service.CreateShortURL(ctx, "https://github.com/launch", CreateOptions{Alias: "launch2026"})
+	if err != nil || again.ShortCode != "launch2026" {
+		t.Errorf("Expected existing alias to be returned but got %s (err=%v)", again.ShortCode, err)
+	}
+
+	tests := []struct {
+		name        string
+		originalURL string
+		alias       string
+		expectedErr error
+	}{
+		{name: "Alias taken", originalURL: "https://github.com/other", alias: "launch2026", expectedErr: ErrAliasTaken},
+		{name: "Reserved alias", originalURL: "https://github.com/other", alias: "health", expectedErr: ErrAliasReserved},
+		{name: "Invalid alias", originalURL: "https://github.com/other", alias: "no way", expectedErr: ErrInvalidAlias},
+		{name: "URL already shortened", originalURL: "https://github.com/launch", alias: "launch-2", expectedErr: ErrURLAlreadyShortened},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := service.CreateShortURL(ctx, tt.originalURL, CreateOptions{Alias: tt.alias})
+			if err != tt.expectedErr {
+				t.Errorf("Expected %v but got %v", tt.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestShortenerService_CreateShortURLConcurrent(t *testing.T) {
+	service, _ := newShortenerService()
+	ctx := context.Background()
+
+	const workers = 20
+	var wg sync.WaitGroup
+	results := make([]model.URL, workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			url, err := service.CreateShortURL(ctx, "https://github.com/user/repo", CreateOptions{})
+			if err != nil {
+				t.Errorf("Failed to create short URL: %v", err)
+			}
+			results[i] = url
+		}(i)
+	}
+	wg.Wait()
+
+	// Every caller gets the one record that was actually stored
+	for _, url := range results {
+		if url.ShortCode != results[0].ShortCode {
+			t.Fatalf("Expected all callers to get %s but got %s", results[0].ShortCode, url.ShortCode)
+		}
+	}
+	if _, err := service.GetURL(ctx, results[0].ShortCode); err != nil {
+		t.Errorf("Expected returned short code to resolve but got: %v", err)
+	}
+}
+
+// stubGenerator generates codes with a function and records the requests
+type stubGenerator struct {
+	generate func(req utils.CodeRequest) string
+	requests []utils.CodeRequest
+}
+
+func (g *stubGenerator) Generate(ctx context.Context, req utils.CodeRequest) (string, error) {
+	g.requests = append(g.requests, req)
+	return g.generate(req), nil
+}
+
+func (g *stubGenerator) Alphabet() string {
+	return utils.DefaultAlphabet
+}
+
+// collidingGenerator returns the given codes in order, repeating the last one
+func collidingGenerator(codes ...string) *stubGenerator {
+	return &stubGenerator{generate: func(req utils.CodeRequest) string {
+		return codes[min(req.Attempt, len(codes)-1)]
+	}}
+}
+
+func TestShortenerService_CreateShortURLRetriesOnCollision(t *testing.T) {
+	service, urlStore := newShortenerService()
+	ctx := context.Background()
+
+	taken := model.URL{ID: "aaaa11", ShortCode: "aaaa11", Original: "https://github.com", CreatedAt: time.Now()}
+	if err := urlStore.Save(ctx, taken); err != nil {
+		t.Fatalf("Failed to save URL: %v", err)
+	}
+
+	// The first two codes collide, the third is free
+	service.config.Generator = collidingGenerator("aaaa11", "aaaa11", "bbbb22")
+	created, err := service.CreateShortURL(ctx, "https://go.dev", CreateOptions{})
+	if err != nil {
+		t.Fatalf("Expected collisions to be retried but got: %v", err)
+	}
+	if created.ShortCode != "bbbb22" {
+		t.Errorf("Expected short code bbbb22 but got %s", created.ShortCode)
+	}
+
+	// The colliding code must still point at its original URL
+	existing, err := urlStore.GetByShortCode(ctx, "aaaa11")
+	if err != nil || existing.Original != "https://github.com" {
+		t.Errorf("Expected aaaa11 to keep pointing at https://github.com but got %+v (err=%v)", existing, err)
+	}
+
+	stats := service.metricsService.GetShortCodeStats()
+	if stats.Collisions != 2 {
+		t.Errorf("Expected 2 collisions but got %d", stats.Collisions)
+	}
+}
+
+func TestShortenerService_CreateShortURLGivesUpAfterMaxAttempts(t *testing.T) {
+	service, urlStore := newShortenerService()
+	ctx := context.Background()
+
+	taken := model.URL{ID: "aaaa11", ShortCode: "aaaa11", Original: "https://github.com", CreatedAt: time.Now()}
+	if err := urlStore.Save(ctx, taken); err != nil {
+		t.Fatalf("Failed to save URL: %v", err)
+	}
+
+	service.config.Generator = collidingGenerator("aaaa11")
+	if _, err := service.CreateShortURL(ctx, "https://go.dev", CreateOptions{}); err != ErrShortCodeUnavailable {
+		t.Fatalf("Expected ErrShortCodeUnavailable but got: %v", err)
+	}
+
+	stats := service.metricsService.GetShortCodeStats()
+	if stats.Collisions != DefaultMaxGenerateAttempts {
+		t.Errorf("Expected %d collisions but got %d", DefaultMaxGenerateAttempts, stats.Collisions)
+	}
+	if stats.Exhausted != 1 {
+		t.Errorf("Expected 1 exhausted request but got %d", stats.Exhausted)
+	}
+}
+
+func TestShortenerService_EscalatesCodeLength(t *testing.T) {
+	urlStore := url.NewMemoryStorage()
+	metricsService := NewMetricsService(metrics.NewMemoryStorage())
+	service := NewShortenerService(urlStore, metricsService, ShortenerConfig{
+		BaseURL:    "http://localhost:3000",
+		CodeLength: 4,
+		// A single stored URL is enough to pass this threshold
+		EscalationThreshold: 1e-9,
+	})
+	ctx := context.Background()
+
+	taken := model.URL{ID: "aaaa", ShortCode: "aaaa", Original: "https://github.com", CreatedAt: time.Now()}
+	if err := urlStore.Save(ctx, taken); err != nil {
+		t.Fatalf("Failed to save URL: %v", err)
+	}
+
+	// Four character codes always collide, longer ones are free
+	generator := &stubGenerator{generate: func(req utils.CodeRequest) string {
+		if req.Length == 4 {
+			return "aaaa"
+		}
+		return "bbbbb"
+	}}
+	service.config.Generator = generator
+
+	created, err := service.CreateShortURL(ctx, "https://go.dev", CreateOptions{})
+	if err != nil {
+		t.Fatalf("Expected code length to be escalated but got: %v", err)
+	}
+	if created.ShortCode != "bbbbb" {
+		t.Errorf("Expected short code bbbbb but got %s", created.ShortCode)
+	}
+	if service.CodeLength() != 5 {
+		t.Errorf("Expected code length 5 but got %d", service.CodeLength())
+	}
+	if len(generator.requests) != 2 || generator.requests[0].Length != 4 || generator.requests[1].Length != 5 {
+		t.Errorf("Expected codes of length 4 then 5 but got %+v", generator.requests)
+	}
+	if escalations := metricsService.GetShortCodeStats().Escalations; escalations != 1 {
+		t.Errorf("Expected 1 escalation but got %d", escalations)
+	}
+}
+
+// admin is an API key that may manage every URL
+var admin = model.APIKey{ID: "admin", Scope: model.ScopeAdmin}
+
+func TestShortenerService_UpdateAndDeleteURL(t *testing.T) {
+	service, _ := newShortenerService()
+	ctx := context.Background()
+
+	first, err := service.CreateShortURL(ctx, "https://github.com/golang/go", CreateOptions{})
+	if err != nil {
+		t.Fatalf("Failed to create short URL: %v", err)
+	}
+	second, err := service.CreateShortURL(ctx, "https://go.dev", CreateOptions{})
+	if err != nil {
+		t.Fatalf("Failed to create short URL: %v", err)
+	}
+
+	// Retargeting keeps the short code
+	updated, err := service.UpdateURL(ctx, admin, first.ShortCode, "http://pkg.go.dev")
+	if err != nil {
+		t.Fatalf("Failed to update URL: %v", err)
+	}
+	if updated.ShortCode != first.ShortCode || updated.Original != "https://pkg.go.dev" {
+		t.Errorf("Expected %s to point to https://pkg.go.dev but got %+v", first.ShortCode, updated)
+	}
+	if got, _ := service.GetURL(ctx, first.ShortCode); got.Original != "https://pkg.go.dev" {
+		t.Errorf("Expected redirect target https://pkg.go.dev but got %s", got.Original)
+	}
+
+	if _, err := service.UpdateURL(ctx, admin, first.ShortCode, "not a url"); err != ErrInvalidURL {
+		t.Errorf("Expected ErrInvalidURL but got: %v", err)
+	}
+
+	// A destination that already has a short code is reported with that code
+	existing, err := service.UpdateURL(ctx, admin, first.ShortCode, "https://go.dev")
+	if err != ErrURLAlreadyShortened {
+		t.Fatalf("Expected ErrURLAlreadyShortened but got: %v", err)
+	}
+	if existing.ShortCode != second.ShortCode {
+		t.Errorf("Expected existing short code %s but got %s", second.ShortCode, existing.ShortCode)
+	}
+
+	if _, err := service.UpdateURL(ctx, admin, "nothere", "https://go.dev/doc"); err != url.ErrURLNotFound {
+		t.Errorf("Expected ErrURLNotFound but got: %v", err)
+	}
+
+	if err := service.DeleteURL(ctx, admin, first.ShortCode); err != nil {
+		t.Fatalf("Failed to delete URL: %v", err)
+	}
+	if _, err := service.GetLink(ctx, admin, first.ShortCode); err != url.ErrURLNotFound {
+		t.Errorf("Expected deleted URL to be gone but got: %v", err)
+	}
+	if err := service.DeleteURL(ctx, admin, first.ShortCode); err != url.ErrURLNotFound {
+		t.Errorf("Expected ErrURLNotFound on second delete but got: %v", err)
+	}
+}
+
+func TestShortenerService_ListURLs(t *testing.T) {
+	service, _ := newShortenerService()
+	ctx := context.Background()
+
+	for i := 0; i < MaxListLimit+5; i++ {
+		if _, err := service.CreateShortURL(ctx, fmt.Sprintf("https://github.com/golang/go/issues/%d", i), CreateOptions{}); err != nil {
+			t.Fatalf("Failed to create short URL: %v", err)
+		}
+	}
+
+	tests := []struct {
+		limit    int
+		expected int
+	}{
+		{0, DefaultListLimit},
+		{-1, DefaultListLimit},
+		{7, 7},
+		{MaxListLimit + 1, MaxListLimit},
+	}
+
+	for _, tt := range tests {
+		result, err := service.ListURLs(ctx, admin, url.ListOptions{Limit: tt.limit})
+		if err != nil {
+			t.Fatalf("Failed to list URLs: %v", err)
+		}
+		if len(result.URLs) != tt.expected {
+			t.Errorf("Limit %d: expected %d URLs but got %d", tt.limit, tt.expected, len(result.URLs))
+		}
+		if result.NextCursor == "" {
+			t.Errorf("Limit %d: expected a cursor for the next page", tt.limit)
+		}
+	}
+}
+
+func TestShortenerService_Ownership(t *testing.T) {
+	service, _ := newShortenerService()
+	ctx := context.Background()
+	alice := model.APIKey{ID: "alice", Scope: model.ScopeUser}
+	bob := model.APIKey{ID: "bob", Scope: model.ScopeUser}
+
+	link, err := service.CreateShortURL(ctx, "https://github.com/golang/go", CreateOptions{Owner: alice.ID})
+	if err != nil {
+		t.Fatalf("Failed to create short URL: %v", err)
+	}
+	if link.Owner != alice.ID {
+		t.Errorf("Expected owner %s but got %q", alice.ID, link.Owner)
+	}
+	anonymous, err := service.CreateShortURL(ctx, "https://go.dev", CreateOptions{})
+	if err != nil {
+		t.Fatalf("Failed to create short URL: %v", err)
+	}
+
+	// The owner and admins manage the link, other keys don't
+	if _, err := service.GetLink(ctx, alice, link.ShortCode); err != nil {
+		t.Errorf("Expected the owner to get the link but got: %v", err)
+	}
+	if _, err := service.GetLink(ctx, admin, link.ShortCode); err != nil {
+		t.Errorf("Expected an admin to get the link but got: %v", err)
+	}
+	if _, err := service.GetLink(ctx, bob, link.ShortCode); err != ErrForbidden {
+		t.Errorf("Expected ErrForbidden but got: %v", err)
+	}
+	if _, err := service.UpdateURL(ctx, bob, link.ShortCode, "https://pkg.go.dev"); err != ErrForbidden {
+		t.Errorf("Expected ErrForbidden on update but got: %v", err)
+	}
+	if err := service.DeleteURL(ctx, bob, link.ShortCode); err != ErrForbidden {
+		t.Errorf("Expected ErrForbidden on delete but got: %v", err)
+	}
+
+	// Anonymous links belong to admins only
+	if _, err := service.GetLink(ctx, model.APIKey{}, anonymous.ShortCode); err != ErrForbidden {
+		t.Errorf("Expected ErrForbidden without a key but got: %v", err)
+	}
+	if _, err := service.GetLink(ctx, alice, anonymous.ShortCode); err != ErrForbidden {
+		t.Errorf("Expected ErrForbidden for an anonymous link but got: %v", err)
+	}
+
+	// Updating keeps the owner
+	updated, err := service.UpdateURL(ctx, alice, link.ShortCode, "https://pkg.go.dev")
+	if err != nil {
+		t.Fatalf("Failed to update URL: %v", err)
+	}
+	if updated.Owner != alice.ID {
+		t.Errorf("Expected owner %s after update but got %q", alice.ID, updated.Owner)
+	}
+
+	// Listing only shows the caller's links unless it is an admin
+	tests := []struct {
+		caller   model.APIKey
+		expected int
+	}{
+		{alice, 1},
+		{bob, 0},
+		{admin, 2},
+	}
+	for _, tt := range tests {
+		result, err := service.ListURLs(ctx, tt.caller, url.ListOptions{})
+		if err != nil {
+			t.Fatalf("Failed to list URLs: %v", err)
+		}
+		if len(result.URLs) != tt.expected {
+			t.Errorf("%s: expected %d URLs but got %d", tt.caller.ID, tt.expected, len(result.URLs))
+		}
+	}
+	if _, err := service.ListURLs(ctx, model.APIKey{}, url.ListOptions{}); err != ErrForbidden {
+		t.Errorf("Expected ErrForbidden listing without a key but got: %v", err)
+	}
+}
+
+func TestShortenerService_DomainPolicy(t *testing.T) {
+	service, _ := newShortenerService()
+	ctx := context.Background()
+
+	link, err := service.CreateShortURL(ctx, "https://github.com/golang/go", CreateOptions{})
+	if err != nil {
+		t.Fatalf("Failed to create short URL: %v", err)
+	}
+
+	service.config.DomainPolicy = utils.NewDomainPolicy([]string{"github.com", "*.evil.test"}, nil)
+
+	if _, err := service.CreateShortURL(ctx, "https://gist.github.com/user/1", CreateOptions{}); err != ErrDomainBlocked {
+		t.Errorf("Expected ErrDomainBlocked but got: %v", err)
+	}
+	if _, err := service.CreateShortURL(ctx, "https://notgithub.com", CreateOptions{}); err != nil {
+		t.Errorf("Expected an unrelated domain to be allowed but got: %v", err)
+	}
+	if _, err := service.UpdateURL(ctx, admin, link.ShortCode, "https://cdn.evil.test/x"); err != ErrDomainBlocked {
+		t.Errorf("Expected ErrDomainBlocked on update but got: %v", err)
+	}
+
+	// Links created before the domain was blocked stop redirecting
+	if _, err := service.GetURL(ctx, link.ShortCode); err != ErrDomainBlocked {
+		t.Errorf("Expected ErrDomainBlocked on redirect but got: %v", err)
+	}
+	if _, err := service.GetLink(ctx, admin, link.ShortCode); err != nil {
+		t.Errorf("Expected the link to remain manageable but got: %v", err)
+	}
+}
+
+func TestShortenerService_LoopProtection(t *testing.T) {
+	service, _ := newShortenerService()
+	ctx := context.Background()
+
+	// Links back to the base URL host are refused, whatever the port
+	for _, target := range []string{"http://localhost:3000/abc123", "https://LOCALHOST/abc123"} {
+		if _, err := service.CreateShortURL(ctx, target, CreateOptions{}); err != ErrSelfLink {
+			t.Errorf("%s: expected ErrSelfLink but got: %v", target, err)
+		}
+	}
+	if _, err := service.CreateShortURL(ctx, "https://bit.ly/3xYz", CreateOptions{}); err != ErrShortenerChain {
+		t.Errorf("Expected ErrShortenerChain but got: %v", err)
+	}
+
+	// With a resolver the destination of the other shortener's link is stored
+	shortener := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/loop":
+			http.Redirect(w, r, "http://localhost:3000/abc123", http.StatusFound)
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			http.Redirect(w, r, "http://go.dev/doc", http.StatusMovedPermanently)
+		}
+	}))
+	defer shortener.Close()
+
+	guard := utils.NewLoopGuard("http://localhost:3000", nil, []string{"127.0.0.1"})
+	service.config.LoopGuard = guard
+	service.config.Resolver = utils.NewRedirectResolver(guard, 0, 0)
+
+	created, err := service.CreateShortURL(ctx, shortener.URL+"/3xYz", CreateOptions{})
+	if err != nil {
+		t.Fatalf("Failed to create short URL: %v", err)
+	}
+	if created.Original != "https://go.dev/doc" {
+		t.Errorf("Expected the resolved destination but got %s", created.Original)
+	}
+
+	if _, err := service.CreateShortURL(ctx, shortener.URL+"/loop", CreateOptions{}); err != ErrSelfLink {
+		t.Errorf("Expected ErrSelfLink for a link redirecting here but got: %v", err)
+	}
+	if _, err := service.CreateShortURL(ctx, shortener.URL+"/missing", CreateOptions{}); err != ErrShortenerChain {
+		t.Errorf("Expected ErrShortenerChain for an unresolved link but got: %v", err)
+	}
+}
@@ -0,0 +1,21 @@
+package analytics
+
+import (
+	"context"
+	"time"
+
+	"github.com/gatij/goUrlShortener/internal/model"
+)
+
+// Storage defines the interface for click analytics storage operations
+type Storage interface {
+	// RecordClick stores a click on a short URL
+	RecordClick(ctx context.Context, click model.Click) error
+
+	// GetLinkStats summarizes the clicks on the short URL created at
+	// createdAt. Clicks on earlier links that had the same short code are not
+	// counted. Daily counts start on the day of since; top referrers and user
+	// agents cover all clicks and are limited to limit entries each. A link
+	// without clicks has empty stats.
+	GetLinkStats(ctx context.Context, shortCode string, createdAt, since time.Time, limit int) (model.LinkStats, error)
+}
@@ -0,0 +1,82 @@
+package analytics
+
+import (
+    "context"
+    "sync"
+    "time"
+
+    "github.com/gatij/goUrlShortener/internal/model"
+)
+
+// linkClicks holds the click counters of a single short URL
+type linkClicks struct {
+    createdAt time.Time      // When the counted link was created
+    total     int
+    days      map[string]int // Clicks per UTC day
+    referrers map[string]int // Clicks per referring host
+    agents    map[string]int // Clicks per user agent family
+}
+
+// MemoryStorage implements the analytics Storage interface with in-memory
+// counters. Individual clicks are not kept, only their aggregates. Only the
+// latest link with a short code is counted; a newer link that reuses the code
+// starts over.
+type MemoryStorage struct {
+    mu    sync.RWMutex
+    links map[string]*linkClicks
+}
+
+// NewMemoryStorage creates a new in-memory analytics storage
+func NewMemoryStorage() *MemoryStorage {
+    return &MemoryStorage{
+        links: make(map[string]*linkClicks),
+    }
+}
+
+// RecordClick stores a click on a short URL
+func (s *MemoryStorage) RecordClick(ctx context.Context, click model.Click) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    link, exists := s.links[click.ShortCode]
+    if exists && click.LinkCreatedAt.Before(link.createdAt) {
+        // A late click on a link that has since been replaced
+        return nil
+    }
+    if !exists || !link.createdAt.Equal(click.LinkCreatedAt) {
+        link = &linkClicks{
+            createdAt: click.LinkCreatedAt,
+            days:      make(map[string]int),
+            referrers: make(map[string]int),
+            agents:    make(map[string]int),
+        }
+        s.links[click.ShortCode] = link
+    }
+
+    link.total++
+    link.days[clickDay(click.Timestamp)]++
+    link.referrers[clickReferrer(click)]++
+    link.agents[clickAgent(click)]++
+    return nil
+}
+
+// GetLinkStats summarizes the clicks on a short URL
+func (s *MemoryStorage) GetLinkStats(ctx context.Context, shortCode string, createdAt, since time.Time, limit int) (model.LinkStats, error) {
+    s.mu.RLock()
+    defer s.mu.RUnlock()
+
+    // A link without clicks yields empty stats, as does one whose code was
+    // only clicked while it belonged to an earlier link
+    link, exists := s.links[shortCode]
+    if !exists || !link.createdAt.Equal(createdAt) {
+        link = &linkClicks{}
+    }
+
+    return model.LinkStats{
+        ShortCode:     shortCode,
+        TotalClicks:   link.total,
+        ClicksPerDay:  dailyClicks(link.days, since),
+        TopReferrers:  topEntries(link.referrers, limit),
+        TopUserAgents: topEntries(link.agents, limit),
+    }, nil
+}
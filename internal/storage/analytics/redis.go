@@ -0,0 +1,104 @@
+package analytics
+
+import (
+    "context"
+    "strconv"
+    "time"
+
+    "github.com/redis/go-redis/v9"
+    "github.com/gatij/goUrlShortener/internal/model"
+)
+
+// RedisStorage implements the analytics Storage interface with per-link
+// counters in Redis. Individual clicks are not kept, only their aggregates.
+type RedisStorage struct {
+    client redis.UniversalClient
+    tag    string // Hash tag shared by every key
+}
+
+// NewRedisStorage creates a Redis-backed analytics storage
+func NewRedisStorage(client redis.UniversalClient, prefix string) *RedisStorage {
+    return &RedisStorage{
+        client: client,
+        tag:    "{" + prefix + "}:",
+    }
+}
+
+// linkKeys returns the keys holding the counters of the short URL created at
+// createdAt: the total click count, a hash of day to clicks and sorted sets of
+// referrers and agents. Keying by creation time keeps the counters of a link
+// apart from those of earlier links that had the same code.
+func (s *RedisStorage) linkKeys(shortCode string, createdAt time.Time) (total, days, referrers, agents string) {
+    base := s.tag + "clicks:" + shortCode + ":" + strconv.FormatInt(createdAt.UnixNano(), 10)
+    return base + ":total", base + ":days", base + ":referrers", base + ":agents"
+}
+
+// RecordClick stores a click on a short URL
+func (s *RedisStorage) RecordClick(ctx context.Context, click model.Click) error {
+    totalKey, daysKey, referrersKey, agentsKey := s.linkKeys(click.ShortCode, click.LinkCreatedAt)
+
+    // MULTI/EXEC so a click is never half counted
+    _, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+        pipe.Incr(ctx, totalKey)
+        pipe.HIncrBy(ctx, daysKey, clickDay(click.Timestamp), 1)
+        pipe.ZIncrBy(ctx, referrersKey, 1, clickReferrer(click))
+        pipe.ZIncrBy(ctx, agentsKey, 1, clickAgent(click))
+        return nil
+    })
+    return err
+}
+
+// GetLinkStats summarizes the clicks on a short URL
+func (s *RedisStorage) GetLinkStats(ctx context.Context, shortCode string, createdAt, since time.Time, limit int) (model.LinkStats, error) {
+    totalKey, daysKey, referrersKey, agentsKey := s.linkKeys(shortCode, createdAt)
+
+    // A stop index of -1 returns the whole set
+    stop := int64(limit) - 1
+    if limit <= 0 {
+        stop = -1
+    }
+
+    // Read every counter in one round trip
+    var (
+        total     *redis.StringCmd
+        days      *redis.MapStringStringCmd
+        referrers *redis.ZSliceCmd
+        agents    *redis.ZSliceCmd
+    )
+    _, err := s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+        total = pipe.Get(ctx, totalKey)
+        days = pipe.HGetAll(ctx, daysKey)
+        referrers = pipe.ZRevRangeWithScores(ctx, referrersKey, 0, stop)
+        agents = pipe.ZRevRangeWithScores(ctx, agentsKey, 0, stop)
+        return nil
+    })
+    // A link without clicks has no total key yet
+    if err != nil && err != redis.Nil {
+        return model.LinkStats{}, err
+    }
+
+    stats := model.LinkStats{
+        ShortCode:     shortCode,
+        TopReferrers:  countEntries(referrers.Val()),
+        TopUserAgents: countEntries(agents.Val()),
+    }
+    stats.TotalClicks, _ = total.Int()
+
+    dayCounts := make(map[string]int, len(days.Val()))
+    for day, clicks := range days.Val() {
+        dayCounts[day], _ = strconv.Atoi(clicks)
+    }
+    stats.ClicksPerDay = dailyClicks(dayCounts, since)
+
+    return stats, nil
+}
+
+// countEntries converts sorted set members and scores to count entries
+func countEntries(members []redis.Z) []model.CountEntry {
+    entries := make([]model.CountEntry, 0, len(members))
+    for _, member := range members {
+        name, _ := member.Member.(string)
+        entries = append(entries, model.CountEntry{Name: name, Count: int(member.Score)})
+    }
+    return entries
+}
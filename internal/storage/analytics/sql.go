@@ -0,0 +1,136 @@
+package analytics
+
+import (
+    "context"
+    "database/sql"
+    "fmt"
+    "time"
+
+    "github.com/gatij/goUrlShortener/internal/model"
+)
+
+// clicksSchema creates the clicks table. Every click is kept; the day,
+// referrer host and user agent family are stored alongside the raw values so
+// the stats queries can group on them directly. link_created is when the
+// clicked link was created, which tells apart links that reused a code.
+const clicksSchema = `
+CREATE TABLE IF NOT EXISTS clicks (
+    id            INTEGER PRIMARY KEY,
+    short_code    TEXT NOT NULL,
+    link_created  INTEGER NOT NULL,
+    clicked_at    INTEGER NOT NULL,
+    day           TEXT NOT NULL,
+    referrer      TEXT NOT NULL,
+    referrer_host TEXT NOT NULL,
+    user_agent    TEXT NOT NULL,
+    agent_family  TEXT NOT NULL,
+    ip            TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_clicks_link_day ON clicks (short_code, link_created, day);
+`
+
+// linkClause matches the clicks on one link, given its short code and creation time
+const linkClause = `short_code = ? AND link_created = ?`
+
+// SQLStorage implements the analytics Storage interface on top of a SQL database
+type SQLStorage struct {
+    db *sql.DB
+}
+
+// NewSQLStorage creates a SQL-backed analytics storage, creating the schema if needed
+func NewSQLStorage(ctx context.Context, db *sql.DB) (*SQLStorage, error) {
+    if _, err := db.ExecContext(ctx, clicksSchema); err != nil {
+        return nil, fmt.Errorf("create clicks schema: %w", err)
+    }
+
+    return &SQLStorage{db: db}, nil
+}
+
+// RecordClick stores a click on a short URL
+func (s *SQLStorage) RecordClick(ctx context.Context, click model.Click) error {
+    _, err := s.db.ExecContext(ctx,
+        `INSERT INTO clicks (short_code, link_created, clicked_at, day, referrer, referrer_host, user_agent, agent_family, ip)
+         VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+        click.ShortCode, click.LinkCreatedAt.UnixNano(), click.Timestamp.UnixNano(), clickDay(click.Timestamp),
+        click.Referrer, clickReferrer(click), click.UserAgent, clickAgent(click), click.IP,
+    )
+    return err
+}
+
+// GetLinkStats summarizes the clicks on a short URL
+func (s *SQLStorage) GetLinkStats(ctx context.Context, shortCode string, createdAt, since time.Time, limit int) (model.LinkStats, error) {
+    stats := model.LinkStats{ShortCode: shortCode}
+    link := []any{shortCode, createdAt.UnixNano()}
+
+    err := s.db.QueryRowContext(ctx,
+        `SELECT COUNT(*) FROM clicks WHERE `+linkClause, link...,
+    ).Scan(&stats.TotalClicks)
+    if err != nil {
+        return model.LinkStats{}, err
+    }
+
+    // Days in dayLayout sort chronologically as strings
+    rows, err := s.db.QueryContext(ctx,
+        `SELECT day, COUNT(*) FROM clicks
+         WHERE `+linkClause+` AND day >= ?
+         GROUP BY day ORDER BY day`,
+        append(link, clickDay(since))...,
+    )
+    if err != nil {
+        return model.LinkStats{}, err
+    }
+    defer rows.Close()
+
+    stats.ClicksPerDay = []model.DailyClicks{}
+    for rows.Next() {
+        var daily model.DailyClicks
+        if err := rows.Scan(&daily.Date, &daily.Clicks); err != nil {
+            return model.LinkStats{}, err
+        }
+        stats.ClicksPerDay = append(stats.ClicksPerDay, daily)
+    }
+    if err := rows.Err(); err != nil {
+        return model.LinkStats{}, err
+    }
+
+    if stats.TopReferrers, err = s.topValues(ctx, "referrer_host", link, limit); err != nil {
+        return model.LinkStats{}, err
+    }
+    if stats.TopUserAgents, err = s.topValues(ctx, "agent_family", link, limit); err != nil {
+        return model.LinkStats{}, err
+    }
+    return stats, nil
+}
+
+// topValues returns the most common values of column among the clicks on
+// link, the arguments of linkClause. column is always one of our own column
+// names, never user input.
+func (s *SQLStorage) topValues(ctx context.Context, column string, link []any, limit int) ([]model.CountEntry, error) {
+    // A negative LIMIT means no limit in SQLite
+    if limit <= 0 {
+        limit = -1
+    }
+
+    rows, err := s.db.QueryContext(ctx,
+        `SELECT `+column+`, COUNT(*) AS count FROM clicks
+         WHERE `+linkClause+`
+         GROUP BY `+column+`
+         ORDER BY count DESC, `+column+` ASC
+         LIMIT ?`,
+        append(link, limit)...,
+    )
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    entries := []model.CountEntry{}
+    for rows.Next() {
+        var entry model.CountEntry
+        if err := rows.Scan(&entry.Name, &entry.Count); err != nil {
+            return nil, err
+        }
+        entries = append(entries, entry)
+    }
+    return entries, rows.Err()
+}
@@ -0,0 +1,63 @@
+package analytics
+
+import (
+    "sort"
+    "time"
+
+    "github.com/gatij/goUrlShortener/internal/model"
+    "github.com/gatij/goUrlShortener/pkg/utils"
+)
+
+// dayLayout formats the UTC day a click is counted on
+const dayLayout = "2006-01-02"
+
+// clickDay returns the UTC day of a click
+func clickDay(t time.Time) string {
+    return t.UTC().Format(dayLayout)
+}
+
+// clickReferrer returns the referrer a click is counted under
+func clickReferrer(click model.Click) string {
+    return utils.ReferrerHost(click.Referrer)
+}
+
+// clickAgent returns the user agent family a click is counted under
+func clickAgent(click model.Click) string {
+    return utils.UserAgentFamily(click.UserAgent)
+}
+
+// topEntries returns the limit most frequent entries of counts, most frequent
+// first and ties in name order
+func topEntries(counts map[string]int, limit int) []model.CountEntry {
+    entries := make([]model.CountEntry, 0, len(counts))
+    for name, count := range counts {
+        entries = append(entries, model.CountEntry{Name: name, Count: count})
+    }
+    sort.Slice(entries, func(i, j int) bool {
+        if entries[i].Count != entries[j].Count {
+            return entries[i].Count > entries[j].Count
+        }
+        return entries[i].Name < entries[j].Name
+    })
+
+    if limit > 0 && len(entries) > limit {
+        entries = entries[:limit]
+    }
+    return entries
+}
+
+// dailyClicks returns the per-day counts from the day of since onwards, oldest first
+func dailyClicks(days map[string]int, since time.Time) []model.DailyClicks {
+    from := clickDay(since)
+    result := make([]model.DailyClicks, 0, len(days))
+    for day, clicks := range days {
+        // Days in dayLayout sort chronologically as strings
+        if day >= from {
+            result = append(result, model.DailyClicks{Date: day, Clicks: clicks})
+        }
+    }
+    sort.Slice(result, func(i, j int) bool {
+        return result[i].Date < result[j].Date
+    })
+    return result
+}
@@ -0,0 +1,144 @@
+package analytics
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gatij/goUrlShortener/internal/model"
+	"github.com/gatij/goUrlShortener/internal/storage/storagetest"
+)
+
+const (
+	chromeUA  = "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"
+	firefoxUA = "Mozilla/5.0 (X11; Linux x86_64; rv:121.0) Gecko/20100101 Firefox/121.0"
+)
+
+// storageFactories returns a fresh instance of every analytics backend
+func storageFactories(t *testing.T) map[string]func() Storage {
+	return map[string]func() Storage{
+		"memory": func() Storage { return NewMemoryStorage() },
+		"sql": func() Storage {
+			storage, err := NewSQLStorage(context.Background(), storagetest.SQLite(t))
+			if err != nil {
+				t.Fatalf("Failed to create SQL storage: %v", err)
+			}
+			return storage
+		},
+		"redis": func() Storage { return NewRedisStorage(storagetest.Redis(t), "test") },
+	}
+}
+
+func TestStorage_GetLinkStats(t *testing.T) {
+	day1 := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	day2 := day1.Add(24 * time.Hour)
+	day3 := day2.Add(24 * time.Hour)
+	created := day1.Add(-time.Hour)
+
+	clicks := []model.Click{
+		{ShortCode: "abc123", LinkCreatedAt: created, Timestamp: day1, Referrer: "https://news.ycombinator.com/item?id=1", UserAgent: chromeUA, IP: "192.0.2.1"},
+		{ShortCode: "abc123", LinkCreatedAt: created, Timestamp: day2, Referrer: "https://news.ycombinator.com/", UserAgent: chromeUA, IP: "192.0.2.2"},
+		{ShortCode: "abc123", LinkCreatedAt: created, Timestamp: day2.Add(time.Hour), Referrer: "https://twitter.com/someone", UserAgent: firefoxUA, IP: "192.0.2.3"},
+		{ShortCode: "abc123", LinkCreatedAt: created, Timestamp: day3, UserAgent: chromeUA, IP: "192.0.2.1"},
+		{ShortCode: "abc123", LinkCreatedAt: created, Timestamp: day3, Referrer: "https://news.ycombinator.com/", UserAgent: "curl/8.4.0", IP: "192.0.2.4"},
+		// Clicks on other links must not be counted
+		{ShortCode: "zzz999", LinkCreatedAt: created, Timestamp: day1, UserAgent: chromeUA, IP: "192.0.2.9"},
+	}
+
+	for name, newStorage := range storageFactories(t) {
+		t.Run(name, func(t *testing.T) {
+			storage := newStorage()
+			ctx := context.Background()
+
+			for _, click := range clicks {
+				if err := storage.RecordClick(ctx, click); err != nil {
+					t.Fatalf("Failed to record click: %v", err)
+				}
+			}
+
+			stats, err := storage.GetLinkStats(ctx, "abc123", created, day2, 2)
+			if err != nil {
+				t.Fatalf("Failed to get link stats: %v", err)
+			}
+
+			if stats.TotalClicks != 5 {
+				t.Errorf("Expected 5 total clicks but got %d", stats.TotalClicks)
+			}
+
+			// Day 1 is before since
+			wantDays := []model.DailyClicks{{Date: "2025-03-02", Clicks: 2}, {Date: "2025-03-03", Clicks: 2}}
+			if len(stats.ClicksPerDay) != len(wantDays) {
+				t.Fatalf("Expected clicks per day %v but got %v", wantDays, stats.ClicksPerDay)
+			}
+			for i, want := range wantDays {
+				if stats.ClicksPerDay[i] != want {
+					t.Errorf("Expected %v on day %d but got %v", want, i, stats.ClicksPerDay[i])
+				}
+			}
+
+			// Referrers are counted by host, without a limit there are three
+			if len(stats.TopReferrers) != 2 || stats.TopReferrers[0] != (model.CountEntry{Name: "news.ycombinator.com", Count: 3}) {
+				t.Errorf("Expected news.ycombinator.com to lead two referrers but got %v", stats.TopReferrers)
+			}
+
+			if len(stats.TopUserAgents) != 2 || stats.TopUserAgents[0] != (model.CountEntry{Name: "Chrome", Count: 3}) {
+				t.Errorf("Expected Chrome to lead two user agents but got %v", stats.TopUserAgents)
+			}
+		})
+	}
+}
+
+func TestStorage_GetLinkStatsOfReusedCode(t *testing.T) {
+	oldCreated := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	newCreated := oldCreated.Add(48 * time.Hour)
+
+	for name, newStorage := range storageFactories(t) {
+		t.Run(name, func(t *testing.T) {
+			storage := newStorage()
+			ctx := context.Background()
+
+			clicks := []model.Click{
+				{ShortCode: "abc123", LinkCreatedAt: oldCreated, Timestamp: oldCreated.Add(time.Hour), Referrer: "https://secret.internal/", UserAgent: chromeUA},
+				{ShortCode: "abc123", LinkCreatedAt: oldCreated, Timestamp: oldCreated.Add(2 * time.Hour), Referrer: "https://secret.internal/", UserAgent: chromeUA},
+				{ShortCode: "abc123", LinkCreatedAt: newCreated, Timestamp: newCreated.Add(time.Hour), UserAgent: firefoxUA},
+				// A click on the old link recorded after the code was reused
+				{ShortCode: "abc123", LinkCreatedAt: oldCreated, Timestamp: newCreated.Add(2 * time.Hour), Referrer: "https://secret.internal/", UserAgent: chromeUA},
+			}
+			for _, click := range clicks {
+				if err := storage.RecordClick(ctx, click); err != nil {
+					t.Fatalf("Failed to record click: %v", err)
+				}
+			}
+
+			stats, err := storage.GetLinkStats(ctx, "abc123", newCreated, time.Time{}, 5)
+			if err != nil {
+				t.Fatalf("Failed to get link stats: %v", err)
+			}
+			if stats.TotalClicks != 1 {
+				t.Errorf("Expected only the click on the new link but got %d clicks", stats.TotalClicks)
+			}
+			for _, referrer := range stats.TopReferrers {
+				if referrer.Name == "secret.internal" {
+					t.Errorf("Expected no referrers of the old link but got %v", stats.TopReferrers)
+				}
+			}
+		})
+	}
+}
+
+func TestStorage_GetLinkStatsWithoutClicks(t *testing.T) {
+	for name, newStorage := range storageFactories(t) {
+		t.Run(name, func(t *testing.T) {
+			stats, err := newStorage().GetLinkStats(context.Background(), "abc123", time.Now(), time.Time{}, 5)
+			if err != nil {
+				t.Fatalf("Failed to get link stats: %v", err)
+			}
+			if stats.TotalClicks != 0 || len(stats.ClicksPerDay) != 0 || len(stats.TopReferrers) != 0 || len(stats.TopUserAgents) != 0 {
+				t.Errorf("Expected empty stats but got %+v", stats)
+			}
+			if stats.ClicksPerDay == nil || stats.TopReferrers == nil || stats.TopUserAgents == nil {
+				t.Errorf("Expected empty lists rather than nil so they encode as []")
+			}
+		})
+	}
+}
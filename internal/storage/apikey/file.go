@@ -0,0 +1,126 @@
+package apikey
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "os"
+    "path/filepath"
+
+    "github.com/gatij/goUrlShortener/internal/model"
+)
+
+// keysFileName is the name of the API key file inside the data directory
+const keysFileName = "api_keys.json"
+
+// FileStorage implements the Storage interface with in-memory maps that are
+// written to a JSON file on every change. API keys change rarely, so
+// rewriting the whole file is simpler than keeping a log.
+type FileStorage struct {
+    *MemoryStorage
+
+    path string // File holding every API key
+}
+
+// NewFileStorage opens (or creates) a file-backed API key storage in dir
+func NewFileStorage(dir string) (*FileStorage, error) {
+    if err := os.MkdirAll(dir, 0o755); err != nil {
+        return nil, fmt.Errorf("create data directory: %w", err)
+    }
+
+    s := &FileStorage{
+        MemoryStorage: NewMemoryStorage(),
+        path:          filepath.Join(dir, keysFileName),
+    }
+
+    data, err := os.ReadFile(s.path)
+    if err != nil && !errors.Is(err, os.ErrNotExist) {
+        return nil, fmt.Errorf("read api keys: %w", err)
+    }
+    if err == nil {
+        var keys []model.APIKey
+        if err := json.Unmarshal(data, &keys); err != nil {
+            return nil, fmt.Errorf("decode api keys: %w", err)
+        }
+        for _, key := range keys {
+            s.insert(key)
+        }
+    }
+
+    return s, nil
+}
+
+// Save stores a new API key and rewrites the file
+func (s *FileStorage) Save(ctx context.Context, key model.APIKey) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    if err := s.checkNew(key); err != nil {
+        return err
+    }
+    s.insert(key)
+
+    if err := s.write(); err != nil {
+        s.remove(key.ID)
+        return err
+    }
+    return nil
+}
+
+// Delete removes an API key and rewrites the file
+func (s *FileStorage) Delete(ctx context.Context, id string) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    key, exists := s.keys[id]
+    if !exists {
+        return ErrKeyNotFound
+    }
+    s.remove(id)
+
+    if err := s.write(); err != nil {
+        s.insert(key)
+        return err
+    }
+    return nil
+}
+
+// write replaces the file with the current keys. The keys are written to a
+// temporary file and renamed into place so a crash never leaves a partial
+// file behind. Caller must hold the lock.
+func (s *FileStorage) write() error {
+    keys := make([]model.APIKey, 0, len(s.keys))
+    for _, key := range s.keys {
+        keys = append(keys, key)
+    }
+    sortKeys(keys)
+
+    data, err := json.Marshal(keys)
+    if err != nil {
+        return err
+    }
+
+    tmpPath := s.path + ".tmp"
+    // Owner-only permissions, the hashes are not secrets but needn't be public either
+    f, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+    if err != nil {
+        return fmt.Errorf("create api keys file: %w", err)
+    }
+    if _, err := f.Write(data); err != nil {
+        f.Close()
+        return fmt.Errorf("write api keys file: %w", err)
+    }
+    if err := f.Sync(); err != nil {
+        f.Close()
+        return fmt.Errorf("sync api keys file: %w", err)
+    }
+    if err := f.Close(); err != nil {
+        return fmt.Errorf("close api keys file: %w", err)
+    }
+    if err := os.Rename(tmpPath, s.path); err != nil {
+        return fmt.Errorf("install api keys file: %w", err)
+    }
+
+    return nil
+}
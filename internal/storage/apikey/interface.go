@@ -0,0 +1,23 @@
+package apikey
+
+import (
+	"context"
+
+	"github.com/gatij/goUrlShortener/internal/model"
+)
+
+// Storage defines the interface for API key storage operations
+type Storage interface {
+	// Save stores a new API key. Returns ErrKeyExists if the ID or the hash
+	// is already stored.
+	Save(ctx context.Context, key model.APIKey) error
+
+	// GetByHash retrieves an API key by the hash of its secret
+	GetByHash(ctx context.Context, hash string) (model.APIKey, error)
+
+	// List returns every API key, oldest first
+	List(ctx context.Context) ([]model.APIKey, error)
+
+	// Delete removes an API key, which stops it from authenticating
+	Delete(ctx context.Context, id string) error
+}
@@ -0,0 +1,117 @@
+package apikey
+
+import (
+    "context"
+    "errors"
+    "sort"
+    "sync"
+
+    "github.com/gatij/goUrlShortener/internal/model"
+)
+
+var (
+    // ErrKeyNotFound is returned when an API key is not found in storage
+    ErrKeyNotFound = errors.New("api key not found")
+
+    // ErrKeyExists is returned when attempting to save an API key whose ID or hash is already stored
+    ErrKeyExists = errors.New("api key already exists")
+)
+
+// MemoryStorage implements the Storage interface with in-memory maps
+type MemoryStorage struct {
+    keys   map[string]model.APIKey // Maps ID to API key
+    hashes map[string]string       // Maps hash to ID
+    mu     sync.RWMutex            // Protects the maps from concurrent access
+}
+
+// NewMemoryStorage creates a new in-memory API key storage
+func NewMemoryStorage() *MemoryStorage {
+    return &MemoryStorage{
+        keys:   make(map[string]model.APIKey),
+        hashes: make(map[string]string),
+    }
+}
+
+// Save stores a new API key
+func (s *MemoryStorage) Save(ctx context.Context, key model.APIKey) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    if err := s.checkNew(key); err != nil {
+        return err
+    }
+    s.insert(key)
+
+    return nil
+}
+
+// checkNew reports whether a key clashes with a stored one. Caller must hold the lock.
+func (s *MemoryStorage) checkNew(key model.APIKey) error {
+    if _, exists := s.keys[key.ID]; exists {
+        return ErrKeyExists
+    }
+    if _, exists := s.hashes[key.Hash]; exists {
+        return ErrKeyExists
+    }
+    return nil
+}
+
+// insert adds a key to both maps. Caller must hold the lock.
+func (s *MemoryStorage) insert(key model.APIKey) {
+    s.keys[key.ID] = key
+    s.hashes[key.Hash] = key.ID
+}
+
+// GetByHash retrieves an API key by the hash of its secret
+func (s *MemoryStorage) GetByHash(ctx context.Context, hash string) (model.APIKey, error) {
+    s.mu.RLock()
+    defer s.mu.RUnlock()
+
+    id, exists := s.hashes[hash]
+    if !exists {
+        return model.APIKey{}, ErrKeyNotFound
+    }
+    return s.keys[id], nil
+}
+
+// List returns every API key, oldest first
+func (s *MemoryStorage) List(ctx context.Context) ([]model.APIKey, error) {
+    s.mu.RLock()
+    keys := make([]model.APIKey, 0, len(s.keys))
+    for _, key := range s.keys {
+        keys = append(keys, key)
+    }
+    s.mu.RUnlock()
+
+    sortKeys(keys)
+    return keys, nil
+}
+
+// Delete removes an API key
+func (s *MemoryStorage) Delete(ctx context.Context, id string) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    if _, exists := s.keys[id]; !exists {
+        return ErrKeyNotFound
+    }
+    s.remove(id)
+
+    return nil
+}
+
+// remove deletes a key from both maps. Caller must hold the lock.
+func (s *MemoryStorage) remove(id string) {
+    delete(s.hashes, s.keys[id].Hash)
+    delete(s.keys, id)
+}
+
+// sortKeys orders keys by creation time, with the ID breaking ties
+func sortKeys(keys []model.APIKey) {
+    sort.Slice(keys, func(i, j int) bool {
+        if !keys[i].CreatedAt.Equal(keys[j].CreatedAt) {
+            return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+        }
+        return keys[i].ID < keys[j].ID
+    })
+}
@@ -0,0 +1,136 @@
+package apikey
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+
+    "github.com/redis/go-redis/v9"
+    "github.com/gatij/goUrlShortener/internal/model"
+)
+
+// saveKeyScript stores a key and its hash index atomically. KEYS are the keys
+// and hashes keys, ARGV the ID, JSON and hash. Returns 0 if the ID or hash exists.
+var saveKeyScript = redis.NewScript(`
+if redis.call('HEXISTS', KEYS[1], ARGV[1]) == 1 then return 0 end
+if redis.call('HEXISTS', KEYS[2], ARGV[3]) == 1 then return 0 end
+redis.call('HSET', KEYS[1], ARGV[1], ARGV[2])
+redis.call('HSET', KEYS[2], ARGV[3], ARGV[1])
+return 1
+`)
+
+// deleteKeyScript removes a key and its hash index atomically. ARGV is the ID
+// and hash. Returns 0 if the ID is unknown.
+var deleteKeyScript = redis.NewScript(`
+if redis.call('HDEL', KEYS[1], ARGV[1]) == 0 then return 0 end
+redis.call('HDEL', KEYS[2], ARGV[2])
+return 1
+`)
+
+// RedisStorage implements the Storage interface on top of Redis so every
+// server instance accepts the same keys
+type RedisStorage struct {
+    client    redis.UniversalClient
+    keysKey   string // Hash of ID to JSON encoded API key
+    hashesKey string // Hash of secret hash to ID
+}
+
+// NewRedisStorage creates a Redis-backed API key storage. Both keys share a
+// hash tag derived from prefix so the scripts also work against Redis Cluster.
+func NewRedisStorage(client redis.UniversalClient, prefix string) *RedisStorage {
+    tag := "{" + prefix + "}:"
+
+    return &RedisStorage{
+        client:    client,
+        keysKey:   tag + "api_keys",
+        hashesKey: tag + "api_key_hashes",
+    }
+}
+
+// Save stores a new API key
+func (s *RedisStorage) Save(ctx context.Context, key model.APIKey) error {
+    data, err := json.Marshal(key)
+    if err != nil {
+        return err
+    }
+
+    saved, err := saveKeyScript.Run(ctx, s.client, []string{s.keysKey, s.hashesKey},
+        key.ID, data, key.Hash,
+    ).Int()
+    if err != nil {
+        return err
+    }
+    if saved == 0 {
+        return ErrKeyExists
+    }
+    return nil
+}
+
+// GetByHash retrieves an API key by the hash of its secret
+func (s *RedisStorage) GetByHash(ctx context.Context, hash string) (model.APIKey, error) {
+    id, err := s.client.HGet(ctx, s.hashesKey, hash).Result()
+    if errors.Is(err, redis.Nil) {
+        return model.APIKey{}, ErrKeyNotFound
+    }
+    if err != nil {
+        return model.APIKey{}, err
+    }
+
+    return s.getByID(ctx, id)
+}
+
+// getByID retrieves an API key by its ID
+func (s *RedisStorage) getByID(ctx context.Context, id string) (model.APIKey, error) {
+    data, err := s.client.HGet(ctx, s.keysKey, id).Bytes()
+    if errors.Is(err, redis.Nil) {
+        return model.APIKey{}, ErrKeyNotFound
+    }
+    if err != nil {
+        return model.APIKey{}, err
+    }
+
+    var key model.APIKey
+    if err := json.Unmarshal(data, &key); err != nil {
+        return model.APIKey{}, err
+    }
+    return key, nil
+}
+
+// List returns every API key, oldest first
+func (s *RedisStorage) List(ctx context.Context) ([]model.APIKey, error) {
+    all, err := s.client.HGetAll(ctx, s.keysKey).Result()
+    if err != nil {
+        return nil, err
+    }
+
+    keys := make([]model.APIKey, 0, len(all))
+    for _, data := range all {
+        var key model.APIKey
+        if err := json.Unmarshal([]byte(data), &key); err != nil {
+            return nil, err
+        }
+        keys = append(keys, key)
+    }
+
+    sortKeys(keys)
+    return keys, nil
+}
+
+// Delete removes an API key
+func (s *RedisStorage) Delete(ctx context.Context, id string) error {
+    key, err := s.getByID(ctx, id)
+    if err != nil {
+        return err
+    }
+
+    deleted, err := deleteKeyScript.Run(ctx, s.client, []string{s.keysKey, s.hashesKey},
+        key.ID, key.Hash,
+    ).Int()
+    if err != nil {
+        return err
+    }
+    if deleted == 0 {
+        return ErrKeyNotFound
+    }
+    return nil
+}
@@ -0,0 +1,129 @@
+package apikey
+
+import (
+    "context"
+    "database/sql"
+    "errors"
+    "fmt"
+    "time"
+
+    "github.com/gatij/goUrlShortener/internal/model"
+)
+
+// apiKeySchema creates the api_keys table. Hashes are unique so a secret
+// always resolves to a single key.
+const apiKeySchema = `
+CREATE TABLE IF NOT EXISTS api_keys (
+    id         TEXT PRIMARY KEY,
+    name       TEXT NOT NULL,
+    hash       TEXT NOT NULL,
+    scope      TEXT NOT NULL,
+    created_at INTEGER NOT NULL
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_api_keys_hash ON api_keys (hash);
+`
+
+// apiKeyColumns lists the columns read by every query, in scan order
+const apiKeyColumns = `id, name, hash, scope, created_at`
+
+// SQLStorage implements the Storage interface on top of a SQL database
+type SQLStorage struct {
+    db *sql.DB
+}
+
+// NewSQLStorage creates a SQL-backed API key storage, creating the schema if needed
+func NewSQLStorage(ctx context.Context, db *sql.DB) (*SQLStorage, error) {
+    if _, err := db.ExecContext(ctx, apiKeySchema); err != nil {
+        return nil, fmt.Errorf("create api_keys schema: %w", err)
+    }
+
+    return &SQLStorage{db: db}, nil
+}
+
+// Save stores a new API key
+func (s *SQLStorage) Save(ctx context.Context, key model.APIKey) error {
+    result, err := s.db.ExecContext(ctx,
+        `INSERT INTO api_keys (id, name, hash, scope, created_at)
+         VALUES (?, ?, ?, ?, ?)
+         ON CONFLICT DO NOTHING`,
+        key.ID, key.Name, key.Hash, key.Scope, key.CreatedAt.UnixNano(),
+    )
+    if err != nil {
+        return err
+    }
+
+    inserted, err := result.RowsAffected()
+    if err != nil {
+        return err
+    }
+    if inserted == 0 {
+        return ErrKeyExists
+    }
+    return nil
+}
+
+// GetByHash retrieves an API key by the hash of its secret
+func (s *SQLStorage) GetByHash(ctx context.Context, hash string) (model.APIKey, error) {
+    row := s.db.QueryRowContext(ctx, `SELECT `+apiKeyColumns+` FROM api_keys WHERE hash = ?`, hash)
+    return scanKey(row)
+}
+
+// List returns every API key, oldest first
+func (s *SQLStorage) List(ctx context.Context) ([]model.APIKey, error) {
+    rows, err := s.db.QueryContext(ctx, `SELECT `+apiKeyColumns+` FROM api_keys ORDER BY created_at, id`)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    keys := make([]model.APIKey, 0)
+    for rows.Next() {
+        key, err := scanKey(rows)
+        if err != nil {
+            return nil, err
+        }
+        keys = append(keys, key)
+    }
+    return keys, rows.Err()
+}
+
+// Delete removes an API key
+func (s *SQLStorage) Delete(ctx context.Context, id string) error {
+    result, err := s.db.ExecContext(ctx, `DELETE FROM api_keys WHERE id = ?`, id)
+    if err != nil {
+        return err
+    }
+
+    deleted, err := result.RowsAffected()
+    if err != nil {
+        return err
+    }
+    if deleted == 0 {
+        return ErrKeyNotFound
+    }
+    return nil
+}
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+    Scan(dest ...any) error
+}
+
+// scanKey reads a single API key selected with apiKeyColumns
+func scanKey(row rowScanner) (model.APIKey, error) {
+    var (
+        key       model.APIKey
+        createdAt int64
+    )
+
+    err := row.Scan(&key.ID, &key.Name, &key.Hash, &key.Scope, &createdAt)
+    if errors.Is(err, sql.ErrNoRows) {
+        return model.APIKey{}, ErrKeyNotFound
+    }
+    if err != nil {
+        return model.APIKey{}, err
+    }
+
+    key.CreatedAt = time.Unix(0, createdAt)
+    return key, nil
+}
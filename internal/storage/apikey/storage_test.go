@@ -0,0 +1,122 @@
+package apikey
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gatij/goUrlShortener/internal/model"
+	"github.com/gatij/goUrlShortener/internal/storage/storagetest"
+)
+
+// storageFactories returns a fresh instance of every API key storage backend
+func storageFactories(t *testing.T) map[string]func() Storage {
+	return map[string]func() Storage{
+		"memory": func() Storage { return NewMemoryStorage() },
+		"file": func() Storage {
+			s, err := NewFileStorage(t.TempDir())
+			if err != nil {
+				t.Fatalf("Failed to open file storage: %v", err)
+			}
+			return s
+		},
+		"sql": func() Storage {
+			s, err := NewSQLStorage(context.Background(), storagetest.SQLite(t))
+			if err != nil {
+				t.Fatalf("Failed to create SQL storage: %v", err)
+			}
+			return s
+		},
+		"redis": func() Storage { return NewRedisStorage(storagetest.Redis(t), "test") },
+	}
+}
+
+func TestStorage_SaveGetListDelete(t *testing.T) {
+	now := time.Now().Truncate(time.Millisecond)
+	admin := model.APIKey{ID: "key1", Name: "ops", Hash: "hash1", Scope: model.ScopeAdmin, CreatedAt: now}
+	user := model.APIKey{ID: "key2", Name: "dashboard", Hash: "hash2", Scope: model.ScopeUser, CreatedAt: now.Add(time.Second)}
+
+	for name, newStorage := range storageFactories(t) {
+		t.Run(name, func(t *testing.T) {
+			storage := newStorage()
+			ctx := context.Background()
+
+			for _, key := range []model.APIKey{user, admin} {
+				if err := storage.Save(ctx, key); err != nil {
+					t.Fatalf("Failed to save key: %v", err)
+				}
+			}
+
+			// Neither the ID nor the hash may be reused
+			if err := storage.Save(ctx, model.APIKey{ID: "key1", Hash: "other", CreatedAt: now}); err != ErrKeyExists {
+				t.Errorf("Expected ErrKeyExists for a duplicate ID but got: %v", err)
+			}
+			if err := storage.Save(ctx, model.APIKey{ID: "key3", Hash: "hash2", CreatedAt: now}); err != ErrKeyExists {
+				t.Errorf("Expected ErrKeyExists for a duplicate hash but got: %v", err)
+			}
+
+			got, err := storage.GetByHash(ctx, "hash1")
+			if err != nil {
+				t.Fatalf("Failed to get key: %v", err)
+			}
+			if got.ID != admin.ID || got.Name != admin.Name || got.Scope != admin.Scope || !got.CreatedAt.Equal(admin.CreatedAt) {
+				t.Errorf("Expected %+v but got %+v", admin, got)
+			}
+			if _, err := storage.GetByHash(ctx, "unknown"); err != ErrKeyNotFound {
+				t.Errorf("Expected ErrKeyNotFound but got: %v", err)
+			}
+
+			keys, err := storage.List(ctx)
+			if err != nil {
+				t.Fatalf("Failed to list keys: %v", err)
+			}
+			if len(keys) != 2 || keys[0].ID != "key1" || keys[1].ID != "key2" {
+				t.Errorf("Expected keys oldest first but got %+v", keys)
+			}
+
+			if err := storage.Delete(ctx, "key1"); err != nil {
+				t.Fatalf("Failed to delete key: %v", err)
+			}
+			if _, err := storage.GetByHash(ctx, "hash1"); err != ErrKeyNotFound {
+				t.Errorf("Expected deleted key to stop resolving but got: %v", err)
+			}
+			if err := storage.Delete(ctx, "key1"); err != ErrKeyNotFound {
+				t.Errorf("Expected ErrKeyNotFound on second delete but got: %v", err)
+			}
+
+			// The hash of a deleted key is free again
+			if err := storage.Save(ctx, model.APIKey{ID: "key3", Hash: "hash1", CreatedAt: now}); err != nil {
+				t.Errorf("Failed to reuse the hash of a deleted key: %v", err)
+			}
+		})
+	}
+}
+
+func TestFileStorage_Reopen(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	storage, err := NewFileStorage(dir)
+	if err != nil {
+		t.Fatalf("Failed to open file storage: %v", err)
+	}
+	for _, id := range []string{"key1", "key2"} {
+		if err := storage.Save(ctx, model.APIKey{ID: id, Hash: "hash-" + id, Scope: model.ScopeUser, CreatedAt: time.Now()}); err != nil {
+			t.Fatalf("Failed to save key: %v", err)
+		}
+	}
+	if err := storage.Delete(ctx, "key1"); err != nil {
+		t.Fatalf("Failed to delete key: %v", err)
+	}
+
+	reopened, err := NewFileStorage(dir)
+	if err != nil {
+		t.Fatalf("Failed to reopen file storage: %v", err)
+	}
+	if _, err := reopened.GetByHash(ctx, "hash-key2"); err != nil {
+		t.Errorf("Expected key2 to survive a restart but got: %v", err)
+	}
+	if _, err := reopened.GetByHash(ctx, "hash-key1"); err != ErrKeyNotFound {
+		t.Errorf("Expected deleted key1 to stay deleted but got: %v", err)
+	}
+}
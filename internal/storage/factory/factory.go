@@ -0,0 +1,166 @@
+package factory
+
+import (
+    "context"
+    "database/sql"
+    "errors"
+    "fmt"
+    "os"
+    "path/filepath"
+
+    "github.com/redis/go-redis/v9"
+    "github.com/gatij/goUrlShortener/config"
+    "github.com/gatij/goUrlShortener/internal/storage/analytics"
+    "github.com/gatij/goUrlShortener/internal/storage/apikey"
+    "github.com/gatij/goUrlShortener/internal/storage/metrics"
+    "github.com/gatij/goUrlShortener/internal/storage/sequence"
+    "github.com/gatij/goUrlShortener/internal/storage/url"
+
+    // Pure-Go SQLite driver, works with CGO_ENABLED=0
+    _ "modernc.org/sqlite"
+)
+
+// Stores bundles the storage backends selected by the configuration
+type Stores struct {
+    URLs      url.Storage
+    Metrics   metrics.Storage
+    Sequence  sequence.Storage // Counter for counter-based short codes
+    Analytics analytics.Storage
+    APIKeys   apikey.Storage
+
+    closers []func() error // Cleanup functions for backends holding resources
+}
+
+// New creates the storage backends selected by cfg.StorageType
+func New(cfg *config.Config) (*Stores, error) {
+    switch cfg.StorageType {
+    case config.StorageMemory:
+        return &Stores{
+            URLs:      url.NewMemoryStorage(),
+            Metrics:   metrics.NewMemoryStorage(),
+            Sequence:  sequence.NewMemorySequence(),
+            Analytics: analytics.NewMemoryStorage(),
+            APIKeys:   apikey.NewMemoryStorage(),
+        }, nil
+
+    case config.StorageFile:
+        // Domain metrics and click analytics have no file backend and stay in memory
+        urlStore, err := url.NewFileStorage(cfg.DataDir, cfg.SnapshotThreshold)
+        if err != nil {
+            return nil, fmt.Errorf("open file storage: %w", err)
+        }
+        seq, err := sequence.NewFileSequence(cfg.DataDir)
+        if err != nil {
+            urlStore.Close()
+            return nil, fmt.Errorf("open file sequence: %w", err)
+        }
+        keyStore, err := apikey.NewFileStorage(cfg.DataDir)
+        if err != nil {
+            urlStore.Close()
+            return nil, fmt.Errorf("open file api keys: %w", err)
+        }
+        return &Stores{
+            URLs:      urlStore,
+            Metrics:   metrics.NewMemoryStorage(),
+            Sequence:  seq,
+            Analytics: analytics.NewMemoryStorage(),
+            APIKeys:   keyStore,
+            closers:   []func() error{urlStore.Close},
+        }, nil
+
+    case config.StorageSQLite:
+        return newSQLiteStores(cfg.SQLitePath)
+
+    case config.StorageRedis:
+        return newRedisStores(cfg)
+
+    default:
+        return nil, fmt.Errorf("unknown storage type %q", cfg.StorageType)
+    }
+}
+
+// newSQLiteStores opens the SQLite database at path and creates every store on it
+func newSQLiteStores(path string) (*Stores, error) {
+    if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+        return nil, fmt.Errorf("create database directory: %w", err)
+    }
+
+    // WAL journaling lets readers proceed while a write is in progress and the
+    // busy timeout makes concurrent writers wait instead of failing immediately
+    dsn := "file:" + path + "?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)"
+    db, err := sql.Open("sqlite", dsn)
+    if err != nil {
+        return nil, fmt.Errorf("open sqlite database: %w", err)
+    }
+
+    ctx := context.Background()
+    urlStore, err := url.NewSQLStorage(ctx, db)
+    if err != nil {
+        db.Close()
+        return nil, err
+    }
+    metricsStore, err := metrics.NewSQLStorage(ctx, db)
+    if err != nil {
+        db.Close()
+        return nil, err
+    }
+    seq, err := sequence.NewSQLSequence(ctx, db)
+    if err != nil {
+        db.Close()
+        return nil, err
+    }
+    analyticsStore, err := analytics.NewSQLStorage(ctx, db)
+    if err != nil {
+        db.Close()
+        return nil, err
+    }
+    keyStore, err := apikey.NewSQLStorage(ctx, db)
+    if err != nil {
+        db.Close()
+        return nil, err
+    }
+
+    return &Stores{
+        URLs:      urlStore,
+        Metrics:   metricsStore,
+        Sequence:  seq,
+        Analytics: analyticsStore,
+        APIKeys:   keyStore,
+        closers:   []func() error{db.Close},
+    }, nil
+}
+
+// newRedisStores connects to Redis and creates every store on the same client
+func newRedisStores(cfg *config.Config) (*Stores, error) {
+    client := redis.NewClient(&redis.Options{
+        Addr:     cfg.RedisAddr,
+        Password: cfg.RedisPassword,
+        DB:       cfg.RedisDB,
+    })
+
+    // Fail fast on startup rather than on the first request
+    if err := client.Ping(context.Background()).Err(); err != nil {
+        client.Close()
+        return nil, fmt.Errorf("connect to redis at %s: %w", cfg.RedisAddr, err)
+    }
+
+    return &Stores{
+        URLs:      url.NewRedisStorage(client, cfg.RedisKeyPrefix),
+        Metrics:   metrics.NewRedisStorage(client, cfg.RedisKeyPrefix),
+        Sequence:  sequence.NewRedisSequence(client, cfg.RedisKeyPrefix),
+        Analytics: analytics.NewRedisStorage(client, cfg.RedisKeyPrefix),
+        APIKeys:   apikey.NewRedisStorage(client, cfg.RedisKeyPrefix),
+        closers:   []func() error{client.Close},
+    }, nil
+}
+
+// Close releases any resources held by the storage backends
+func (s *Stores) Close() error {
+    var errs []error
+    for _, closeFn := range s.closers {
+        if err := closeFn(); err != nil {
+            errs = append(errs, err)
+        }
+    }
+    return errors.Join(errs...)
+}
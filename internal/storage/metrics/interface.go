@@ -16,4 +16,8 @@ type Storage interface {
 
 	// GetDomainMetrics retrieves metrics for a specific domain
     GetDomainMetrics(ctx context.Context, domain string) (model.DomainMetrics, bool, error)
+
+	// IncrementDomainShortenCount atomically adds one to a domain's shorten count,
+	// creating the domain's metrics if they don't exist yet
+	IncrementDomainShortenCount(ctx context.Context, domain string) error
 }
\ No newline at end of file
@@ -121,6 +121,7 @@ func (s *MemoryStorage) GetTopDomains(ctx context.Context, limit int) ([]model.D
     return result, nil
 }
 
+// GetDomainMetrics retrieves metrics for a specific domain
 func (s *MemoryStorage) GetDomainMetrics(ctx context.Context, domain string) (model.DomainMetrics, bool, error) {
     s.mu.RLock()
     defer s.mu.RUnlock()
@@ -131,4 +132,32 @@ func (s *MemoryStorage) GetDomainMetrics(ctx context.Context, domain string) (mo
     }
     
     return metrics, true, nil
+}
+
+// IncrementDomainShortenCount atomically adds one to a domain's shorten count
+func (s *MemoryStorage) IncrementDomainShortenCount(ctx context.Context, domain string) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    
+    metrics, exists := s.domains[domain]
+    if !exists {
+        metrics = model.DomainMetrics{Domain: domain}
+    }
+    metrics.ShortenCount++
+    s.domains[domain] = metrics
+    
+    // Update or insert in the heap
+    if item, exists := s.domainItems[domain]; exists {
+        item.shortenCount = metrics.ShortenCount
+        heap.Fix(s.domainHeap, item.index)
+    } else {
+        item := &DomainHeapItem{
+            domain:       domain,
+            shortenCount: metrics.ShortenCount,
+        }
+        heap.Push(s.domainHeap, item)
+        s.domainItems[domain] = item
+    }
+    
+    return nil
 }
\ No newline at end of file
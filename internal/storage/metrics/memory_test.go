@@ -137,3 +137,26 @@ func TestMemoryStorage_GetTopDomains(t *testing.T) {
 		t.Errorf("Expected some domains with default limit but got none")
 	}
 }
+
+func TestMemoryStorage_IncrementDomainShortenCount(t *testing.T) {
+	storage := NewMemoryStorage()
+	ctx := context.Background()
+
+	// Increment a new domain and an existing one
+	for i := 0; i < 3; i++ {
+		if err := storage.IncrementDomainShortenCount(ctx, "github.com"); err != nil {
+			t.Fatalf("Failed to increment domain count: %v", err)
+		}
+	}
+	if err := storage.IncrementDomainShortenCount(ctx, "golang.org"); err != nil {
+		t.Fatalf("Failed to increment domain count: %v", err)
+	}
+
+	topDomains, err := storage.GetTopDomains(ctx, 1)
+	if err != nil {
+		t.Fatalf("Failed to get top domains: %v", err)
+	}
+	if len(topDomains) != 1 || topDomains[0].Domain != "github.com" || topDomains[0].ShortenCount != 3 {
+		t.Errorf("Expected github.com with count 3 but got %+v", topDomains)
+	}
+}
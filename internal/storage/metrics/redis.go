@@ -0,0 +1,75 @@
+package metrics
+
+import (
+    "context"
+    "errors"
+
+    "github.com/redis/go-redis/v9"
+    "github.com/gatij/goUrlShortener/internal/model"
+)
+
+// RedisStorage implements the metrics Storage interface with a Redis sorted set
+// scored by shorten count, so the top domains come straight from ZREVRANGE
+type RedisStorage struct {
+    client     redis.UniversalClient
+    domainsKey string // Sorted set of domain to shorten count
+}
+
+// NewRedisStorage creates a Redis-backed metrics storage
+func NewRedisStorage(client redis.UniversalClient, prefix string) *RedisStorage {
+    return &RedisStorage{
+        client:     client,
+        domainsKey: "{" + prefix + "}:domains",
+    }
+}
+
+// SaveDomainMetrics stores metrics for a domain
+func (s *RedisStorage) SaveDomainMetrics(ctx context.Context, metrics model.DomainMetrics) error {
+    return s.client.ZAdd(ctx, s.domainsKey, redis.Z{
+        Score:  float64(metrics.ShortenCount),
+        Member: metrics.Domain,
+    }).Err()
+}
+
+// GetTopDomains retrieves the top N domains based on shorten count
+func (s *RedisStorage) GetTopDomains(ctx context.Context, limit int) ([]model.DomainMetrics, error) {
+    // A stop index of -1 returns the whole set
+    stop := int64(limit) - 1
+    if limit <= 0 {
+        stop = -1
+    }
+
+    entries, err := s.client.ZRevRangeWithScores(ctx, s.domainsKey, 0, stop).Result()
+    if err != nil {
+        return nil, err
+    }
+
+    result := make([]model.DomainMetrics, 0, len(entries))
+    for _, entry := range entries {
+        domain, _ := entry.Member.(string)
+        result = append(result, model.DomainMetrics{
+            Domain:       domain,
+            ShortenCount: int(entry.Score),
+        })
+    }
+
+    return result, nil
+}
+
+// GetDomainMetrics retrieves metrics for a specific domain
+func (s *RedisStorage) GetDomainMetrics(ctx context.Context, domain string) (model.DomainMetrics, bool, error) {
+    score, err := s.client.ZScore(ctx, s.domainsKey, domain).Result()
+    if errors.Is(err, redis.Nil) {
+        return model.DomainMetrics{}, false, nil
+    }
+    if err != nil {
+        return model.DomainMetrics{}, false, err
+    }
+
+    return model.DomainMetrics{Domain: domain, ShortenCount: int(score)}, true, nil
+}
+
+// IncrementDomainShortenCount atomically adds one to a domain's shorten count
+func (s *RedisStorage) IncrementDomainShortenCount(ctx context.Context, domain string) error {
+    return s.client.ZIncrBy(ctx, s.domainsKey, 1, domain).Err()
+}
@@ -0,0 +1,56 @@
+package metrics
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gatij/goUrlShortener/internal/model"
+	"github.com/gatij/goUrlShortener/internal/storage/storagetest"
+)
+
+func TestRedisStorage_TopDomains(t *testing.T) {
+	storage := NewRedisStorage(storagetest.Redis(t), "test")
+	ctx := context.Background()
+
+	if err := storage.SaveDomainMetrics(ctx, model.DomainMetrics{Domain: "example.com", ShortenCount: 5}); err != nil {
+		t.Fatalf("Failed to save domain metrics: %v", err)
+	}
+	for i := 0; i < 7; i++ {
+		if err := storage.IncrementDomainShortenCount(ctx, "github.com"); err != nil {
+			t.Fatalf("Failed to increment domain count: %v", err)
+		}
+	}
+	if err := storage.IncrementDomainShortenCount(ctx, "golang.org"); err != nil {
+		t.Fatalf("Failed to increment domain count: %v", err)
+	}
+
+	topDomains, err := storage.GetTopDomains(ctx, 2)
+	if err != nil {
+		t.Fatalf("Failed to get top domains: %v", err)
+	}
+	if len(topDomains) != 2 {
+		t.Fatalf("Expected 2 domains but got %d", len(topDomains))
+	}
+	if topDomains[0].Domain != "github.com" || topDomains[0].ShortenCount != 7 {
+		t.Errorf("Expected github.com with count 7 but got %+v", topDomains[0])
+	}
+	if topDomains[1].Domain != "example.com" || topDomains[1].ShortenCount != 5 {
+		t.Errorf("Expected example.com with count 5 but got %+v", topDomains[1])
+	}
+
+	allDomains, err := storage.GetTopDomains(ctx, 0)
+	if err != nil {
+		t.Fatalf("Failed to get all domains: %v", err)
+	}
+	if len(allDomains) != 3 {
+		t.Errorf("Expected 3 domains but got %d", len(allDomains))
+	}
+
+	metrics, exists, err := storage.GetDomainMetrics(ctx, "golang.org")
+	if err != nil || !exists || metrics.ShortenCount != 1 {
+		t.Errorf("Expected golang.org with count 1 but got %+v (exists=%v, err=%v)", metrics, exists, err)
+	}
+	if _, exists, _ := storage.GetDomainMetrics(ctx, "nonexistent.com"); exists {
+		t.Errorf("Domain metrics shouldn't exist but does")
+	}
+}
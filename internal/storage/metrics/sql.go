@@ -0,0 +1,99 @@
+package metrics
+
+import (
+    "context"
+    "database/sql"
+    "errors"
+    "fmt"
+
+    "github.com/gatij/goUrlShortener/internal/model"
+)
+
+// metricsSchema creates the domain_metrics table with an index for top-N queries
+const metricsSchema = `
+CREATE TABLE IF NOT EXISTS domain_metrics (
+    domain        TEXT PRIMARY KEY,
+    shorten_count INTEGER NOT NULL DEFAULT 0
+);
+CREATE INDEX IF NOT EXISTS idx_domain_metrics_shorten_count ON domain_metrics (shorten_count DESC);
+`
+
+// SQLStorage implements the metrics Storage interface on top of a SQL database
+type SQLStorage struct {
+    db *sql.DB
+}
+
+// NewSQLStorage creates a SQL-backed metrics storage, creating the schema if needed
+func NewSQLStorage(ctx context.Context, db *sql.DB) (*SQLStorage, error) {
+    if _, err := db.ExecContext(ctx, metricsSchema); err != nil {
+        return nil, fmt.Errorf("create metrics schema: %w", err)
+    }
+
+    return &SQLStorage{db: db}, nil
+}
+
+// SaveDomainMetrics stores metrics for a domain
+func (s *SQLStorage) SaveDomainMetrics(ctx context.Context, metrics model.DomainMetrics) error {
+    _, err := s.db.ExecContext(ctx,
+        `INSERT INTO domain_metrics (domain, shorten_count) VALUES (?, ?)
+         ON CONFLICT (domain) DO UPDATE SET shorten_count = excluded.shorten_count`,
+        metrics.Domain, metrics.ShortenCount,
+    )
+    return err
+}
+
+// GetTopDomains retrieves the top N domains based on shorten count
+func (s *SQLStorage) GetTopDomains(ctx context.Context, limit int) ([]model.DomainMetrics, error) {
+    // A negative LIMIT means no limit in SQLite
+    if limit <= 0 {
+        limit = -1
+    }
+
+    rows, err := s.db.QueryContext(ctx,
+        `SELECT domain, shorten_count FROM domain_metrics
+         ORDER BY shorten_count DESC, domain ASC
+         LIMIT ?`,
+        limit,
+    )
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    result := []model.DomainMetrics{}
+    for rows.Next() {
+        var metrics model.DomainMetrics
+        if err := rows.Scan(&metrics.Domain, &metrics.ShortenCount); err != nil {
+            return nil, err
+        }
+        result = append(result, metrics)
+    }
+
+    return result, rows.Err()
+}
+
+// GetDomainMetrics retrieves metrics for a specific domain
+func (s *SQLStorage) GetDomainMetrics(ctx context.Context, domain string) (model.DomainMetrics, bool, error) {
+    var metrics model.DomainMetrics
+    err := s.db.QueryRowContext(ctx,
+        `SELECT domain, shorten_count FROM domain_metrics WHERE domain = ?`, domain,
+    ).Scan(&metrics.Domain, &metrics.ShortenCount)
+    if errors.Is(err, sql.ErrNoRows) {
+        return model.DomainMetrics{}, false, nil
+    }
+    if err != nil {
+        return model.DomainMetrics{}, false, err
+    }
+
+    return metrics, true, nil
+}
+
+// IncrementDomainShortenCount atomically adds one to a domain's shorten count
+func (s *SQLStorage) IncrementDomainShortenCount(ctx context.Context, domain string) error {
+    _, err := s.db.ExecContext(ctx,
+        `INSERT INTO domain_metrics (domain, shorten_count) VALUES (?, 1)
+         ON CONFLICT (domain) DO UPDATE SET shorten_count = shorten_count + 1`,
+        domain,
+    )
+    return err
+}
@@ -0,0 +1,89 @@
+package metrics
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/gatij/goUrlShortener/internal/model"
+	"github.com/gatij/goUrlShortener/internal/storage/storagetest"
+)
+
+func newTestSQLStorage(t *testing.T) *SQLStorage {
+	t.Helper()
+
+	storage, err := NewSQLStorage(context.Background(), storagetest.SQLite(t))
+	if err != nil {
+		t.Fatalf("Failed to create SQL storage: %v", err)
+	}
+	return storage
+}
+
+func TestSQLStorage_GetTopDomains(t *testing.T) {
+	storage := newTestSQLStorage(t)
+	ctx := context.Background()
+
+	domains := []model.DomainMetrics{
+		{Domain: "example.com", ShortenCount: 5},
+		{Domain: "github.com", ShortenCount: 10},
+		{Domain: "golang.org", ShortenCount: 3},
+		{Domain: "google.com", ShortenCount: 7},
+	}
+	for _, domain := range domains {
+		if err := storage.SaveDomainMetrics(ctx, domain); err != nil {
+			t.Fatalf("Failed to save domain metrics: %v", err)
+		}
+	}
+
+	topDomains, err := storage.GetTopDomains(ctx, 2)
+	if err != nil {
+		t.Fatalf("Failed to get top domains: %v", err)
+	}
+	if len(topDomains) != 2 {
+		t.Fatalf("Expected 2 domains but got %d", len(topDomains))
+	}
+	if topDomains[0].Domain != "github.com" || topDomains[1].Domain != "google.com" {
+		t.Errorf("Expected github.com and google.com but got %+v", topDomains)
+	}
+
+	allDomains, err := storage.GetTopDomains(ctx, 0)
+	if err != nil {
+		t.Fatalf("Failed to get all domains: %v", err)
+	}
+	if len(allDomains) != 4 {
+		t.Errorf("Expected 4 domains but got %d", len(allDomains))
+	}
+}
+
+func TestSQLStorage_IncrementDomainShortenCount(t *testing.T) {
+	storage := newTestSQLStorage(t)
+	ctx := context.Background()
+
+	// Concurrent increments must not lose updates
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := storage.IncrementDomainShortenCount(ctx, "github.com"); err != nil {
+				t.Errorf("Failed to increment domain count: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	metrics, exists, err := storage.GetDomainMetrics(ctx, "github.com")
+	if err != nil {
+		t.Fatalf("Failed to get domain metrics: %v", err)
+	}
+	if !exists {
+		t.Fatalf("Domain metrics should exist but doesn't")
+	}
+	if metrics.ShortenCount != 20 {
+		t.Errorf("Expected ShortenCount 20 but got %d", metrics.ShortenCount)
+	}
+
+	if _, exists, _ := storage.GetDomainMetrics(ctx, "nonexistent.com"); exists {
+		t.Errorf("Domain metrics shouldn't exist but does")
+	}
+}
@@ -0,0 +1,10 @@
+package sequence
+
+import "context"
+
+// Storage defines the interface for the counter behind counter-based short codes
+type Storage interface {
+	// Next returns the next number of the sequence, starting at 1. A number is
+	// never returned twice, even across restarts, though some may be skipped.
+	Next(ctx context.Context) (uint64, error)
+}
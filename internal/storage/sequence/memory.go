@@ -0,0 +1,21 @@
+package sequence
+
+import (
+    "context"
+    "sync/atomic"
+)
+
+// MemorySequence implements the Storage interface with an in-process counter
+type MemorySequence struct {
+    last atomic.Uint64
+}
+
+// NewMemorySequence creates a sequence that starts at 1
+func NewMemorySequence() *MemorySequence {
+    return &MemorySequence{}
+}
+
+// Next returns the next number of the sequence
+func (s *MemorySequence) Next(ctx context.Context) (uint64, error) {
+    return s.last.Add(1), nil
+}
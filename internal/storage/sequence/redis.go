@@ -0,0 +1,27 @@
+package sequence
+
+import (
+    "context"
+
+    "github.com/redis/go-redis/v9"
+)
+
+// RedisSequence implements the Storage interface with INCR, so every instance
+// sharing the Redis server draws from the same sequence
+type RedisSequence struct {
+    client redis.UniversalClient
+    key    string
+}
+
+// NewRedisSequence creates a Redis-backed sequence
+func NewRedisSequence(client redis.UniversalClient, prefix string) *RedisSequence {
+    return &RedisSequence{
+        client: client,
+        key:    "{" + prefix + "}:sequence",
+    }
+}
+
+// Next returns the next number of the sequence
+func (s *RedisSequence) Next(ctx context.Context) (uint64, error) {
+    return s.client.Incr(ctx, s.key).Uint64()
+}
@@ -0,0 +1,101 @@
+package sequence
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/gatij/goUrlShortener/internal/storage/storagetest"
+)
+
+// sequenceFactories returns a fresh instance of every sequence backend
+func sequenceFactories(t *testing.T) map[string]func() Storage {
+	return map[string]func() Storage{
+		"memory": func() Storage { return NewMemorySequence() },
+		"file": func() Storage {
+			s, err := NewFileSequence(t.TempDir())
+			if err != nil {
+				t.Fatalf("Failed to open file sequence: %v", err)
+			}
+			return s
+		},
+		"sql": func() Storage {
+			s, err := NewSQLSequence(context.Background(), storagetest.SQLite(t))
+			if err != nil {
+				t.Fatalf("Failed to create SQL sequence: %v", err)
+			}
+			return s
+		},
+		"redis": func() Storage { return NewRedisSequence(storagetest.Redis(t), "test") },
+	}
+}
+
+func TestSequence_NextIsUnique(t *testing.T) {
+	for name, newSequence := range sequenceFactories(t) {
+		t.Run(name, func(t *testing.T) {
+			seq := newSequence()
+			ctx := context.Background()
+
+			first, err := seq.Next(ctx)
+			if err != nil {
+				t.Fatalf("Failed to get next number: %v", err)
+			}
+			if first != 1 {
+				t.Errorf("Expected sequence to start at 1 but got %d", first)
+			}
+
+			const workers = 20
+			var (
+				wg   sync.WaitGroup
+				mu   sync.Mutex
+				seen = map[uint64]bool{first: true}
+			)
+			for i := 0; i < workers; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					n, err := seq.Next(ctx)
+					if err != nil {
+						t.Errorf("Failed to get next number: %v", err)
+						return
+					}
+					mu.Lock()
+					defer mu.Unlock()
+					if seen[n] {
+						t.Errorf("Number %d was returned twice", n)
+					}
+					seen[n] = true
+				}()
+			}
+			wg.Wait()
+		})
+	}
+}
+
+func TestFileSequence_ResumesAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	seq, err := NewFileSequence(dir)
+	if err != nil {
+		t.Fatalf("Failed to open file sequence: %v", err)
+	}
+	for i := 0; i < fileSequenceBlock+5; i++ {
+		if _, err := seq.Next(ctx); err != nil {
+			t.Fatalf("Failed to get next number: %v", err)
+		}
+	}
+	last := seq.last
+
+	reopened, err := NewFileSequence(dir)
+	if err != nil {
+		t.Fatalf("Failed to reopen file sequence: %v", err)
+	}
+	n, err := reopened.Next(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get next number: %v", err)
+	}
+	if n <= last {
+		t.Errorf("Expected a number above %d after restart but got %d", last, n)
+	}
+}
@@ -0,0 +1,45 @@
+package sequence
+
+import (
+    "context"
+    "database/sql"
+    "fmt"
+)
+
+// sequenceSchema creates the sequences table, one row per named counter
+const sequenceSchema = `
+CREATE TABLE IF NOT EXISTS sequences (
+    name  TEXT PRIMARY KEY,
+    value INTEGER NOT NULL
+);
+`
+
+// shortCodeSequence is the row used for short code generation
+const shortCodeSequence = "short_codes"
+
+// SQLSequence implements the Storage interface with a row in a SQL database
+type SQLSequence struct {
+    db *sql.DB
+}
+
+// NewSQLSequence creates a SQL-backed sequence, creating the schema if needed
+func NewSQLSequence(ctx context.Context, db *sql.DB) (*SQLSequence, error) {
+    if _, err := db.ExecContext(ctx, sequenceSchema); err != nil {
+        return nil, fmt.Errorf("create sequences schema: %w", err)
+    }
+
+    return &SQLSequence{db: db}, nil
+}
+
+// Next returns the next number of the sequence
+func (s *SQLSequence) Next(ctx context.Context) (uint64, error) {
+    // The upsert increments and reads the counter in a single statement
+    var value uint64
+    err := s.db.QueryRowContext(ctx,
+        `INSERT INTO sequences (name, value) VALUES (?, 1)
+         ON CONFLICT (name) DO UPDATE SET value = value + 1
+         RETURNING value`,
+        shortCodeSequence,
+    ).Scan(&value)
+    return value, err
+}
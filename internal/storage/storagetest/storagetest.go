@@ -0,0 +1,45 @@
+// Package storagetest provides the databases that storage tests run their
+// SQL and Redis backends against
+package storagetest
+
+import (
+    "database/sql"
+    "testing"
+
+    "github.com/alicebob/miniredis/v2"
+    "github.com/redis/go-redis/v9"
+
+    // Pure-Go SQLite driver, works with CGO_ENABLED=0
+    _ "modernc.org/sqlite"
+)
+
+// SQLite opens an empty in-memory SQLite database that is closed when the test ends
+func SQLite(t testing.TB) *sql.DB {
+    t.Helper()
+
+    db, err := sql.Open("sqlite", ":memory:")
+    if err != nil {
+        t.Fatalf("Failed to open database: %v", err)
+    }
+    // Every connection to :memory: gets its own database, so use just one
+    db.SetMaxOpenConns(1)
+    t.Cleanup(func() { db.Close() })
+    return db
+}
+
+// Redis starts an in-process RESP server and returns a client for it
+func Redis(t testing.TB) *redis.Client {
+    t.Helper()
+
+    return RedisClient(t, miniredis.RunT(t))
+}
+
+// RedisClient returns a client for server that is closed when the test ends.
+// Tests that share a server between clients or move its clock use it directly.
+func RedisClient(t testing.TB, server *miniredis.Miniredis) *redis.Client {
+    t.Helper()
+
+    client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+    t.Cleanup(func() { client.Close() })
+    return client
+}
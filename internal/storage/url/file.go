@@ -0,0 +1,426 @@
+package url
+
+import (
+    "bufio"
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "os"
+    "path/filepath"
+    "time"
+
+    "github.com/gatij/goUrlShortener/internal/logging"
+    "github.com/gatij/goUrlShortener/internal/model"
+)
+
+const (
+    // walFileName is the name of the write-ahead log inside the data directory
+    walFileName = "urls.wal"
+
+    // snapshotFileName is the name of the compacted snapshot inside the data directory
+    snapshotFileName = "urls.snapshot"
+
+    // DefaultSnapshotThreshold is the number of WAL entries after which the log is compacted
+    DefaultSnapshotThreshold = 1000
+)
+
+// Operations recorded in the write-ahead log
+const (
+    walOpSave   = "save"
+    walOpUpdate = "update"
+    walOpDelete = "delete"
+)
+
+// walEntry is a single record in the write-ahead log
+type walEntry struct {
+    Op  string     `json:"op"`            // Operation type (save, update or delete)
+    URL *model.URL `json:"url,omitempty"` // URL for save and update operations
+    ID  string     `json:"id,omitempty"`  // URL ID for delete operations
+}
+
+// FileStorage implements the Storage interface with in-memory indexes that are
+// made durable through an on-disk write-ahead log and periodic snapshots
+type FileStorage struct {
+    *MemoryStorage
+
+    dir               string   // Directory holding the WAL and snapshot files
+    wal               *os.File // Open handle to the write-ahead log
+    walEntries        int      // Number of entries written since the last snapshot
+    snapshotThreshold int      // Compact the WAL once it holds this many entries
+}
+
+// NewFileStorage opens (or creates) a file-backed URL storage in dir, replaying
+// the last snapshot and the write-ahead log to rebuild the in-memory indexes
+func NewFileStorage(dir string, snapshotThreshold int) (*FileStorage, error) {
+    if snapshotThreshold <= 0 {
+        snapshotThreshold = DefaultSnapshotThreshold
+    }
+
+    if err := os.MkdirAll(dir, 0o755); err != nil {
+        return nil, fmt.Errorf("create data directory: %w", err)
+    }
+
+    s := &FileStorage{
+        MemoryStorage:     NewMemoryStorage(),
+        dir:               dir,
+        snapshotThreshold: snapshotThreshold,
+    }
+
+    // Restore state: snapshot first, then any mutations logged after it
+    if err := s.loadSnapshot(); err != nil {
+        return nil, err
+    }
+    if err := s.replayWAL(); err != nil {
+        return nil, err
+    }
+
+    wal, err := os.OpenFile(s.walPath(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+    if err != nil {
+        return nil, fmt.Errorf("open write-ahead log: %w", err)
+    }
+    s.wal = wal
+
+    return s, nil
+}
+
+// Save stores a new shortened URL, logging it before updating the indexes
+func (s *FileStorage) Save(ctx context.Context, url model.URL) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    normalizedURL, err := s.prepareSave(url)
+    if err != nil {
+        return err
+    }
+
+    if err := s.appendWAL(walEntry{Op: walOpSave, URL: &url}); err != nil {
+        return err
+    }
+    s.insert(url, normalizedURL)
+    s.maybeCompact(ctx)
+
+    return nil
+}
+
+// GetOrCreate returns the live URL url.Owner stored for url.Original, or stores url,
+// logging every change before updating the indexes
+func (s *FileStorage) GetOrCreate(ctx context.Context, url model.URL) (model.URL, bool, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    existing, found, expiredID := s.lookupForCreate(url)
+    if found {
+        return existing, false, nil
+    }
+    if expiredID != "" {
+        if err := s.appendWAL(walEntry{Op: walOpDelete, ID: expiredID}); err != nil {
+            return model.URL{}, false, err
+        }
+        s.remove(expiredID)
+    }
+
+    normalizedURL, err := s.prepareSave(url)
+    if err != nil {
+        return model.URL{}, false, err
+    }
+    if err := s.appendWAL(walEntry{Op: walOpSave, URL: &url}); err != nil {
+        return model.URL{}, false, err
+    }
+    s.insert(url, normalizedURL)
+    s.maybeCompact(ctx)
+
+    return url, true, nil
+}
+
+// Update replaces a stored URL, logging it before updating the indexes
+func (s *FileStorage) Update(ctx context.Context, url model.URL) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    url, normalizedURL, err := s.prepareUpdate(url)
+    if err != nil {
+        return err
+    }
+
+    if err := s.appendWAL(walEntry{Op: walOpUpdate, URL: &url}); err != nil {
+        return err
+    }
+    s.remove(url.ID)
+    s.insert(url, normalizedURL)
+    s.maybeCompact(ctx)
+
+    return nil
+}
+
+// ConsumeClick counts one click of a URL unless it has no clicks left,
+// logging the new count before updating the indexes
+func (s *FileStorage) ConsumeClick(ctx context.Context, id string) (model.URL, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    url, err := s.prepareConsume(id)
+    if err != nil {
+        return model.URL{}, err
+    }
+
+    if err := s.appendWAL(walEntry{Op: walOpUpdate, URL: &url}); err != nil {
+        return model.URL{}, err
+    }
+    s.urls[id] = url
+    s.maybeCompact(ctx)
+
+    return url, nil
+}
+
+// Delete removes a URL from storage, logging it before updating the indexes
+func (s *FileStorage) Delete(ctx context.Context, id string) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    if _, exists := s.urls[id]; !exists {
+        return ErrURLNotFound
+    }
+
+    if err := s.appendWAL(walEntry{Op: walOpDelete, ID: id}); err != nil {
+        return err
+    }
+    s.remove(id)
+    s.maybeCompact(ctx)
+
+    return nil
+}
+
+// DeleteExpired removes every URL that expired at or before now, keeping
+// those with a fallback URL until fallbackRetention has passed, and logs
+// each removal
+func (s *FileStorage) DeleteExpired(ctx context.Context, now time.Time, fallbackRetention time.Duration) (int, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    removed := 0
+    for _, id := range s.expiredIDs(now, fallbackRetention) {
+        if err := s.appendWAL(walEntry{Op: walOpDelete, ID: id}); err != nil {
+            return removed, err
+        }
+        s.remove(id)
+        removed++
+    }
+
+    if removed > 0 {
+        s.maybeCompact(ctx)
+    }
+    return removed, nil
+}
+
+// Snapshot compacts the write-ahead log into a fresh snapshot
+func (s *FileStorage) Snapshot() error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    return s.compact()
+}
+
+// Close writes a final snapshot and releases the write-ahead log
+func (s *FileStorage) Close() error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    if s.wal == nil {
+        return nil
+    }
+
+    compactErr := s.compact()
+    closeErr := s.wal.Close()
+    s.wal = nil
+
+    if compactErr != nil {
+        return compactErr
+    }
+    return closeErr
+}
+
+func (s *FileStorage) walPath() string {
+    return filepath.Join(s.dir, walFileName)
+}
+
+func (s *FileStorage) snapshotPath() string {
+    return filepath.Join(s.dir, snapshotFileName)
+}
+
+// appendWAL writes a single entry to the log and syncs it to disk. Caller must hold the lock.
+func (s *FileStorage) appendWAL(entry walEntry) error {
+    if s.wal == nil {
+        return errors.New("file storage is closed")
+    }
+
+    line, err := json.Marshal(entry)
+    if err != nil {
+        return err
+    }
+    line = append(line, '\n')
+
+    if _, err := s.wal.Write(line); err != nil {
+        return fmt.Errorf("write to write-ahead log: %w", err)
+    }
+    if err := s.wal.Sync(); err != nil {
+        return fmt.Errorf("sync write-ahead log: %w", err)
+    }
+
+    s.walEntries++
+    return nil
+}
+
+// maybeCompact compacts the log once it reaches the snapshot threshold. The
+// change that triggered it is already safe in the log, so a failure is only
+// logged and compaction is tried again after the next change. Caller must
+// hold the lock.
+func (s *FileStorage) maybeCompact(ctx context.Context) {
+    if s.walEntries < s.snapshotThreshold {
+        return
+    }
+    if err := s.compact(); err != nil {
+        logging.FromContext(ctx).Error("Failed to compact write-ahead log", "error", err)
+    }
+}
+
+// compact writes every stored URL to a new snapshot and truncates the log.
+// The snapshot is written to a temporary file and renamed into place so a
+// crash never leaves a partially written snapshot behind. Caller must hold the lock.
+func (s *FileStorage) compact() error {
+    urls := make([]model.URL, 0, len(s.urls))
+    for _, url := range s.urls {
+        urls = append(urls, url)
+    }
+
+    data, err := json.Marshal(urls)
+    if err != nil {
+        return err
+    }
+
+    tmpPath := s.snapshotPath() + ".tmp"
+    tmp, err := os.Create(tmpPath)
+    if err != nil {
+        return fmt.Errorf("create snapshot: %w", err)
+    }
+    if _, err := tmp.Write(data); err != nil {
+        tmp.Close()
+        return fmt.Errorf("write snapshot: %w", err)
+    }
+    if err := tmp.Sync(); err != nil {
+        tmp.Close()
+        return fmt.Errorf("sync snapshot: %w", err)
+    }
+    if err := tmp.Close(); err != nil {
+        return fmt.Errorf("close snapshot: %w", err)
+    }
+    if err := os.Rename(tmpPath, s.snapshotPath()); err != nil {
+        return fmt.Errorf("install snapshot: %w", err)
+    }
+    // Persist the rename before the log it replaces is truncated
+    if err := syncDir(s.dir); err != nil {
+        return fmt.Errorf("sync data directory: %w", err)
+    }
+
+    // Everything in the log is now covered by the snapshot. Replaying a log
+    // that survives a crash at this point is harmless since replay is idempotent.
+    if s.wal != nil {
+        if err := s.wal.Truncate(0); err != nil {
+            return fmt.Errorf("truncate write-ahead log: %w", err)
+        }
+    }
+    s.walEntries = 0
+
+    return nil
+}
+
+// syncDir flushes the entries of directory dir to disk
+func syncDir(dir string) error {
+    d, err := os.Open(dir)
+    if err != nil {
+        return err
+    }
+    syncErr := d.Sync()
+    closeErr := d.Close()
+    if syncErr != nil {
+        return syncErr
+    }
+    return closeErr
+}
+
+// loadSnapshot restores the indexes from the snapshot file if one exists
+func (s *FileStorage) loadSnapshot() error {
+    data, err := os.ReadFile(s.snapshotPath())
+    if errors.Is(err, os.ErrNotExist) {
+        return nil
+    }
+    if err != nil {
+        return fmt.Errorf("read snapshot: %w", err)
+    }
+
+    var urls []model.URL
+    if err := json.Unmarshal(data, &urls); err != nil {
+        return fmt.Errorf("decode snapshot: %w", err)
+    }
+
+    for _, url := range urls {
+        s.insert(url, originalKey(url.Owner, url.Original))
+    }
+
+    return nil
+}
+
+// replayWAL applies every logged mutation on top of the snapshot. A torn
+// final record (from a crash mid-write) is discarded and truncated away.
+func (s *FileStorage) replayWAL() error {
+    f, err := os.OpenFile(s.walPath(), os.O_RDWR, 0o644)
+    if errors.Is(err, os.ErrNotExist) {
+        return nil
+    }
+    if err != nil {
+        return fmt.Errorf("open write-ahead log: %w", err)
+    }
+    defer f.Close()
+
+    reader := bufio.NewReader(f)
+    var offset int64
+
+    for {
+        line, err := reader.ReadBytes('\n')
+        if err == io.EOF {
+            if len(line) > 0 {
+                // Incomplete trailing record, drop it
+                return f.Truncate(offset)
+            }
+            return nil
+        }
+        if err != nil {
+            return fmt.Errorf("read write-ahead log: %w", err)
+        }
+
+        var entry walEntry
+        if err := json.Unmarshal(line, &entry); err != nil {
+            return fmt.Errorf("decode write-ahead log at offset %d: %w", offset, err)
+        }
+        s.apply(entry)
+
+        offset += int64(len(line))
+        s.walEntries++
+    }
+}
+
+// apply replays a single log entry against the indexes
+func (s *FileStorage) apply(entry walEntry) {
+    switch entry.Op {
+    case walOpSave, walOpUpdate:
+        if entry.URL == nil {
+            return
+        }
+        // Replace any previous version so replay stays idempotent
+        s.remove(entry.URL.ID)
+        s.insert(*entry.URL, originalKey(entry.URL.Owner, entry.URL.Original))
+    case walOpDelete:
+        s.remove(entry.ID)
+    }
+}
@@ -0,0 +1,232 @@
+package url
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gatij/goUrlShortener/internal/model"
+)
+
+func TestFileStorage_PersistsAcrossRestarts(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	storage, err := NewFileStorage(dir, 100)
+	if err != nil {
+		t.Fatalf("Failed to open file storage: %v", err)
+	}
+
+	urls := []model.URL{
+		{ID: "abc123", ShortCode: "abc123", Original: "https://github.com/golang/go", CreatedAt: time.Now()},
+		{ID: "def456", ShortCode: "def456", Original: "https://go.dev/doc", CreatedAt: time.Now()},
+	}
+	for _, u := range urls {
+		if err := storage.Save(ctx, u); err != nil {
+			t.Fatalf("Failed to save URL: %v", err)
+		}
+	}
+	if err := storage.Delete(ctx, "def456"); err != nil {
+		t.Fatalf("Failed to delete URL: %v", err)
+	}
+
+	// Simulate a crash: drop the handle without writing a snapshot
+	storage.wal.Close()
+
+	reopened, err := NewFileStorage(dir, 100)
+	if err != nil {
+		t.Fatalf("Failed to reopen file storage: %v", err)
+	}
+	defer reopened.Close()
+
+	url, err := reopened.GetByShortCode(ctx, "abc123")
+	if err != nil {
+		t.Fatalf("Expected URL to survive restart but got: %v", err)
+	}
+	if url.Original != "https://github.com/golang/go" {
+		t.Errorf("Expected original URL https://github.com/golang/go but got %s", url.Original)
+	}
+
+	if _, err := reopened.GetByOriginalURL(ctx, "", "https://github.com/golang/go/"); err != nil {
+		t.Errorf("Expected normalized URL index to be rebuilt but got: %v", err)
+	}
+
+	if _, err := reopened.GetByID(ctx, "def456"); err != ErrURLNotFound {
+		t.Errorf("Expected deleted URL to stay deleted but got: %v", err)
+	}
+}
+
+func TestFileStorage_CompactsIntoSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	storage, err := NewFileStorage(dir, 2)
+	if err != nil {
+		t.Fatalf("Failed to open file storage: %v", err)
+	}
+
+	codes := []string{"aaaa11", "bbbb22", "cccc33"}
+	for i, code := range codes {
+		u := model.URL{
+			ID:        code,
+			ShortCode: code,
+			Original:  "https://github.com/repo/" + string(rune('a'+i)),
+			CreatedAt: time.Now(),
+		}
+		if err := storage.Save(ctx, u); err != nil {
+			t.Fatalf("Failed to save URL: %v", err)
+		}
+	}
+
+	// Two saves reached the threshold, so only the third is left in the log
+	if storage.walEntries != 1 {
+		t.Errorf("Expected 1 WAL entry after compaction but got %d", storage.walEntries)
+	}
+	if _, err := os.Stat(filepath.Join(dir, snapshotFileName)); err != nil {
+		t.Errorf("Expected snapshot file to exist: %v", err)
+	}
+
+	if err := storage.Close(); err != nil {
+		t.Fatalf("Failed to close file storage: %v", err)
+	}
+
+	reopened, err := NewFileStorage(dir, 2)
+	if err != nil {
+		t.Fatalf("Failed to reopen file storage: %v", err)
+	}
+	defer reopened.Close()
+
+	for _, code := range codes {
+		if _, err := reopened.GetByShortCode(ctx, code); err != nil {
+			t.Errorf("Expected %s to be restored but got: %v", code, err)
+		}
+	}
+}
+
+func TestFileStorage_CompactionFailureKeepsLog(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	storage, err := NewFileStorage(dir, 1)
+	if err != nil {
+		t.Fatalf("Failed to open file storage: %v", err)
+	}
+
+	// A directory in the way of the temporary snapshot makes compaction fail
+	if err := os.Mkdir(filepath.Join(dir, snapshotFileName+".tmp"), 0o755); err != nil {
+		t.Fatalf("Failed to block the snapshot: %v", err)
+	}
+
+	url := model.URL{ID: "abc123", ShortCode: "abc123", Original: "https://github.com/golang/go", CreatedAt: time.Now()}
+	if err := storage.Save(ctx, url); err != nil {
+		t.Fatalf("Expected the logged save to succeed but got: %v", err)
+	}
+
+	// Simulate a crash: the save must be replayed from the log
+	storage.wal.Close()
+
+	reopened, err := NewFileStorage(dir, 1)
+	if err != nil {
+		t.Fatalf("Failed to reopen file storage: %v", err)
+	}
+	defer reopened.wal.Close()
+
+	if _, err := reopened.GetByShortCode(ctx, "abc123"); err != nil {
+		t.Errorf("Expected URL to survive restart but got: %v", err)
+	}
+}
+
+func TestFileStorage_IgnoresTornRecord(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	storage, err := NewFileStorage(dir, 100)
+	if err != nil {
+		t.Fatalf("Failed to open file storage: %v", err)
+	}
+	u := model.URL{ID: "abc123", ShortCode: "abc123", Original: "https://github.com", CreatedAt: time.Now()}
+	if err := storage.Save(ctx, u); err != nil {
+		t.Fatalf("Failed to save URL: %v", err)
+	}
+
+	// Append a partial record as if the process died mid-write
+	storage.wal.Write([]byte(`{"op":"save","url":{"id":"zz`))
+	storage.wal.Close()
+
+	reopened, err := NewFileStorage(dir, 100)
+	if err != nil {
+		t.Fatalf("Expected torn record to be ignored but got: %v", err)
+	}
+	defer reopened.Close()
+
+	if _, err := reopened.GetByID(ctx, "abc123"); err != nil {
+		t.Errorf("Expected complete record to be restored but got: %v", err)
+	}
+}
+
+func TestFileStorage_DeleteExpiredIsPersisted(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	now := time.Now()
+
+	storage, err := NewFileStorage(dir, 100)
+	if err != nil {
+		t.Fatalf("Failed to open file storage: %v", err)
+	}
+
+	past := now.Add(-time.Minute)
+	u := model.URL{ID: "abc123", ShortCode: "abc123", Original: "https://github.com", CreatedAt: now, ExpiresAt: &past}
+	if err := storage.Save(ctx, u); err != nil {
+		t.Fatalf("Failed to save URL: %v", err)
+	}
+	if removed, err := storage.DeleteExpired(ctx, now, time.Hour); err != nil || removed != 1 {
+		t.Fatalf("Expected 1 expired URL to be removed but got %d (err=%v)", removed, err)
+	}
+	storage.wal.Close()
+
+	reopened, err := NewFileStorage(dir, 100)
+	if err != nil {
+		t.Fatalf("Failed to reopen file storage: %v", err)
+	}
+	defer reopened.Close()
+
+	if _, err := reopened.GetByID(ctx, "abc123"); err != ErrURLNotFound {
+		t.Errorf("Expected purged URL to stay purged but got: %v", err)
+	}
+}
+
+func TestFileStorage_UpdateIsPersisted(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	storage, err := NewFileStorage(dir, 100)
+	if err != nil {
+		t.Fatalf("Failed to open file storage: %v", err)
+	}
+
+	u := model.URL{ID: "abc123", ShortCode: "abc123", Original: "https://github.com", CreatedAt: time.Now()}
+	if err := storage.Save(ctx, u); err != nil {
+		t.Fatalf("Failed to save URL: %v", err)
+	}
+	u.Original = "https://go.dev"
+	if err := storage.Update(ctx, u); err != nil {
+		t.Fatalf("Failed to update URL: %v", err)
+	}
+	storage.wal.Close()
+
+	reopened, err := NewFileStorage(dir, 100)
+	if err != nil {
+		t.Fatalf("Failed to reopen file storage: %v", err)
+	}
+	defer reopened.Close()
+
+	got, err := reopened.GetByID(ctx, "abc123")
+	if err != nil || got.Original != "https://go.dev" {
+		t.Errorf("Expected updated URL to survive restart but got %+v (err=%v)", got, err)
+	}
+	if _, err := reopened.GetByOriginalURL(ctx, "", "https://github.com"); err != ErrURLNotFound {
+		t.Errorf("Expected old original URL to stay unindexed but got: %v", err)
+	}
+}
@@ -2,24 +2,59 @@ package url
 
 import (
     "context"
+    "time"
 
     "github.com/gatij/goUrlShortener/internal/model"
 )
 
 // Storage defines the interface for URL storage operations
 type Storage interface {
-    // Save stores a new shortened URL
+    // Save stores a new shortened URL. Returns ErrURLExists if the ID is
+    // already stored and ErrOriginalURLExists if the owner already has the
+    // normalized original URL.
     Save(ctx context.Context, url model.URL) error
 
+    // GetOrCreate atomically returns the URL url.Owner already stored for
+    // url.Original (compared in normalized form), or stores url if there is
+    // none, so different owners never share a URL. created reports whether
+    // url was stored. An existing entry that has expired as of url.CreatedAt
+    // or used up its clicks is replaced, unless it is kept for its fallback
+    // URL (see model.URL.KeepsFallback); DeleteExpired removes those. Returns
+    // ErrURLExists if url.ID is already taken by a different original URL.
+    GetOrCreate(ctx context.Context, url model.URL) (stored model.URL, created bool, err error)
+
     // GetByID retrieves a URL by its short ID
     GetByID(ctx context.Context, id string) (model.URL, error)
 
     // GetByShortCode retrieves a URL by its short code
     GetByShortCode(ctx context.Context, shortCode string) (model.URL, error)
 
-	// GetByOriginalURL retrieves a URL by its original URL
-    GetByOriginalURL(ctx context.Context, originalURL string) (model.URL, error)
+	// GetByOriginalURL retrieves the URL owner stored for an original URL
+    GetByOriginalURL(ctx context.Context, owner, originalURL string) (model.URL, error)
+
+    // Update replaces the stored URL that has url.ID, keeping its short code
+    // and click count. Returns ErrURLNotFound if the ID is unknown and
+    // ErrOriginalURLExists if the owner already has the new original URL
+    // under another ID.
+    Update(ctx context.Context, url model.URL) error
+
+    // ConsumeClick atomically counts one click of the URL with the given ID
+    // and returns it updated. Returns ErrClicksExhausted, without counting,
+    // if the URL is click-limited and has no clicks left.
+    ConsumeClick(ctx context.Context, id string) (model.URL, error)
 
     // Delete removes a URL from storage
     Delete(ctx context.Context, id string) error
+
+    // List returns a page of URLs that match the filters in opts, ordered by
+    // creation time. Returns ErrInvalidCursor for a malformed opts.Cursor.
+    List(ctx context.Context, opts ListOptions) (ListResult, error)
+
+    // Count returns the number of stored URLs
+    Count(ctx context.Context) (int, error)
+
+    // DeleteExpired removes every URL that expired at or before now and
+    // returns how many were removed. URLs with a fallback URL are kept for
+    // fallbackRetention after they expire, so their visitors are still sent there.
+    DeleteExpired(ctx context.Context, now time.Time, fallbackRetention time.Duration) (int, error)
 }
\ No newline at end of file
@@ -0,0 +1,137 @@
+package url
+
+import (
+    "encoding/base64"
+    "errors"
+    "fmt"
+    "net/url"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/gatij/goUrlShortener/internal/model"
+)
+
+// ErrInvalidCursor is returned when a pagination cursor can't be decoded
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// ListOptions filters and paginates List. URLs are ordered by creation time,
+// with the ID breaking ties, so pages stay stable while URLs are added.
+type ListOptions struct {
+    Owner         string    // Only URLs created by this API key (empty for all)
+    Domain        string    // Only URLs whose destination host is Domain (empty for all)
+    Contains      string    // Only URLs whose original URL contains this text, ignoring case
+    CreatedFrom   time.Time // Only URLs created at or after this time (zero for no bound)
+    CreatedBefore time.Time // Only URLs created before this time (zero for no bound)
+    Descending    bool      // Newest first instead of oldest first
+    Cursor        string    // Resume after the position returned as NextCursor
+    Limit         int       // Maximum number of URLs to return (must be positive)
+}
+
+// ListResult is a page of URLs
+type ListResult struct {
+    URLs       []model.URL
+    NextCursor string // Cursor for the next page, empty on the last page
+}
+
+// listPosition is a point in the (CreatedAt, ID) ordering used by List
+type listPosition struct {
+    createdAt int64 // Unix nanoseconds
+    id        string
+}
+
+// positionOf returns the position of a URL in the List ordering
+func positionOf(url model.URL) listPosition {
+    return listPosition{createdAt: url.CreatedAt.UnixNano(), id: url.ID}
+}
+
+// less reports whether p sorts before other in ascending order
+func (p listPosition) less(other listPosition) bool {
+    if p.createdAt != other.createdAt {
+        return p.createdAt < other.createdAt
+    }
+    return p.id < other.id
+}
+
+// encodeCursor turns a position into an opaque cursor
+func encodeCursor(p listPosition) string {
+    return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(p.createdAt, 10) + ":" + p.id))
+}
+
+// decodeCursor parses a cursor created by encodeCursor
+func decodeCursor(cursor string) (listPosition, error) {
+    data, err := base64.RawURLEncoding.DecodeString(cursor)
+    if err != nil {
+        return listPosition{}, ErrInvalidCursor
+    }
+
+    createdAt, id, found := strings.Cut(string(data), ":")
+    if !found || id == "" {
+        return listPosition{}, ErrInvalidCursor
+    }
+    ns, err := strconv.ParseInt(createdAt, 10, 64)
+    if err != nil {
+        return listPosition{}, ErrInvalidCursor
+    }
+
+    return listPosition{createdAt: ns, id: id}, nil
+}
+
+// urlDomain returns the lowercased host of an original URL without its port
+func urlDomain(originalURL string) string {
+    parsed, err := url.Parse(originalURL)
+    if err != nil {
+        return ""
+    }
+    return strings.ToLower(parsed.Hostname())
+}
+
+// matches reports whether a URL passes the filters in opts
+func (opts ListOptions) matches(url model.URL) bool {
+    if opts.Owner != "" && url.Owner != opts.Owner {
+        return false
+    }
+    if opts.Domain != "" && urlDomain(url.Original) != strings.ToLower(opts.Domain) {
+        return false
+    }
+    if opts.Contains != "" && !strings.Contains(strings.ToLower(url.Original), strings.ToLower(opts.Contains)) {
+        return false
+    }
+    if !opts.CreatedFrom.IsZero() && url.CreatedAt.Before(opts.CreatedFrom) {
+        return false
+    }
+    if !opts.CreatedBefore.IsZero() && !url.CreatedAt.Before(opts.CreatedBefore) {
+        return false
+    }
+    return true
+}
+
+// validate checks the options and decodes the cursor, if any
+func (opts ListOptions) validate() (cursor *listPosition, err error) {
+    if opts.Limit <= 0 {
+        return nil, fmt.Errorf("list limit must be positive, got %d", opts.Limit)
+    }
+    if opts.Cursor == "" {
+        return nil, nil
+    }
+
+    position, err := decodeCursor(opts.Cursor)
+    if err != nil {
+        return nil, err
+    }
+    return &position, nil
+}
+
+// pageOf turns up to limit+1 matching URLs into a page. The extra URL only
+// signals that another page exists.
+func pageOf(urls []model.URL, limit int) ListResult {
+    if len(urls) <= limit {
+        return ListResult{URLs: urls}
+    }
+
+    urls = urls[:limit]
+    return ListResult{
+        URLs:       urls,
+        NextCursor: encodeCursor(positionOf(urls[limit-1])),
+    }
+}
@@ -3,7 +3,9 @@ package url
 import (
     "context"
     "errors"
+    "sort"
     "sync"
+    "time"
 
     "github.com/PuerkitoBio/purell"
     "github.com/gatij/goUrlShortener/internal/model"
@@ -15,13 +17,21 @@ var (
     
     // ErrURLExists is returned when attempting to save a URL that already exists
     ErrURLExists = errors.New("url with this ID already exists")
+
+    // ErrOriginalURLExists is returned when attempting to save a URL whose
+    // normalized original URL is already stored under another ID
+    ErrOriginalURLExists = errors.New("url with this original URL already exists")
+
+    // ErrClicksExhausted is returned when counting a click of a URL that has
+    // used up all of its clicks
+    ErrClicksExhausted = errors.New("url has no clicks left")
 )
 
 // MemoryStorage implements the Storage interface with in-memory data structures
 type MemoryStorage struct {
     urls              map[string]model.URL  // Maps ID to URL object
     shortToURL        map[string]string     // Maps short code to ID
-    normalizedToShort map[string]string     // Maps normalized URL to short code
+    normalizedToShort map[string]string     // Maps owner and normalized URL (see originalKey) to short code
     mu                sync.RWMutex          // Protects the maps from concurrent access
 }
 
@@ -35,7 +45,7 @@ func NewMemoryStorage() *MemoryStorage {
 }
 
 // normalizeURL standardizes a URL for consistent lookups using purell
-func (s *MemoryStorage) normalizeURL(rawURL string) string {
+func normalizeURL(rawURL string) string {
     // Define normalization flags
     flags := purell.FlagsSafe | purell.FlagRemoveTrailingSlash | 
              purell.FlagRemoveDotSegments | purell.FlagRemoveDuplicateSlashes |
@@ -51,31 +61,92 @@ func (s *MemoryStorage) normalizeURL(rawURL string) string {
     return normalized
 }
 
+// originalKey is the key under which a URL is indexed by its original URL:
+// the normalized URL, prefixed by the owner's ID for owned links. Each API key
+// thus gets its own short code for a URL, and can't retarget another key's.
+func originalKey(owner, originalURL string) string {
+    if owner == "" {
+        return normalizeURL(originalURL)
+    }
+    return owner + " " + normalizeURL(originalURL)
+}
+
 // Save stores a new shortened URL
 func (s *MemoryStorage) Save(ctx context.Context, url model.URL) error {
     s.mu.Lock()
     defer s.mu.Unlock()
     
+    normalizedURL, err := s.prepareSave(url)
+    if err != nil {
+        return err
+    }
+    
+    s.insert(url, normalizedURL)
+    
+    return nil
+}
+
+// prepareSave checks whether a URL can be saved and returns its normalized form.
+// Caller must hold the lock.
+func (s *MemoryStorage) prepareSave(url model.URL) (string, error) {
     // Check if ID already exists
     if _, exists := s.urls[url.ID]; exists {
-        return ErrURLExists
+        return "", ErrURLExists
     }
     
     // Normalize the original URL
-    normalizedURL := s.normalizeURL(url.Original)
+    normalizedURL := originalKey(url.Owner, url.Original)
     
     // Check if the normalized URL already exists
-    if existingShort, exists := s.normalizedToShort[normalizedURL]; exists {
-        // Return the existing URL object
-        id := s.shortToURL[existingShort]
-        existingURL := s.urls[id]
-        
-        // Update the provided URL object with existing values (for return by reference)
-        url = existingURL
-        
-        return nil
+    if _, exists := s.normalizedToShort[normalizedURL]; exists {
+        return "", ErrOriginalURLExists
+    }
+    
+    return normalizedURL, nil
+}
+
+// GetOrCreate returns the live URL url.Owner stored for url.Original, or stores url
+func (s *MemoryStorage) GetOrCreate(ctx context.Context, url model.URL) (model.URL, bool, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    
+    existing, found, expiredID := s.lookupForCreate(url)
+    if found {
+        return existing, false, nil
+    }
+    if expiredID != "" {
+        s.remove(expiredID)
+    }
+    
+    normalizedURL, err := s.prepareSave(url)
+    if err != nil {
+        return model.URL{}, false, err
+    }
+    s.insert(url, normalizedURL)
+    
+    return url, true, nil
+}
+
+// lookupForCreate finds the URL stored for url.Original. found is true if it is
+// still live as of url.CreatedAt or kept for its fallback URL. Otherwise
+// expiredID holds the ID of an expired or used up entry that must be removed
+// first, if any. Caller must hold the lock.
+func (s *MemoryStorage) lookupForCreate(url model.URL) (existing model.URL, found bool, expiredID string) {
+    shortCode, exists := s.normalizedToShort[originalKey(url.Owner, url.Original)]
+    if !exists {
+        return model.URL{}, false, ""
+    }
+    
+    existing = s.urls[s.shortToURL[shortCode]]
+    if existing.IsSpent(url.CreatedAt) && !existing.KeepsFallback(url.CreatedAt) {
+        return model.URL{}, false, existing.ID
     }
     
+    return existing, true, ""
+}
+
+// insert adds a URL to all index maps. Caller must hold the lock.
+func (s *MemoryStorage) insert(url model.URL, normalizedURL string) {
     // Store URL by ID
     s.urls[url.ID] = url
     
@@ -84,8 +155,6 @@ func (s *MemoryStorage) Save(ctx context.Context, url model.URL) error {
     
     // Store mapping from normalized original URL to short code
     s.normalizedToShort[normalizedURL] = url.ShortCode
-    
-    return nil
 }
 
 // GetByID retrieves a URL by its ID
@@ -116,13 +185,13 @@ func (s *MemoryStorage) GetByShortCode(ctx context.Context, shortCode string) (m
     return s.urls[id], nil
 }
 
-// GetByOriginalURL retrieves a URL by its original URL
-func (s *MemoryStorage) GetByOriginalURL(ctx context.Context, originalURL string) (model.URL, error) {
+// GetByOriginalURL retrieves the URL owner stored for an original URL
+func (s *MemoryStorage) GetByOriginalURL(ctx context.Context, owner, originalURL string) (model.URL, error) {
     s.mu.RLock()
     defer s.mu.RUnlock()
     
     // Normalize the URL for consistent lookup
-    normalizedURL := s.normalizeURL(originalURL)
+    normalizedURL := originalKey(owner, originalURL)
     
     // Direct lookup from normalized URL to short code - O(1)
     shortCode, exists := s.normalizedToShort[normalizedURL]
@@ -135,24 +204,170 @@ func (s *MemoryStorage) GetByOriginalURL(ctx context.Context, originalURL string
     return s.urls[id], nil
 }
 
+// Update replaces a stored URL, keeping its short code
+func (s *MemoryStorage) Update(ctx context.Context, url model.URL) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    
+    url, normalizedURL, err := s.prepareUpdate(url)
+    if err != nil {
+        return err
+    }
+    
+    s.remove(url.ID)
+    s.insert(url, normalizedURL)
+    
+    return nil
+}
+
+// prepareUpdate checks whether url can replace the stored URL with the same ID
+// and returns it with the stored short code, along with its normalized form.
+// Caller must hold the lock.
+func (s *MemoryStorage) prepareUpdate(url model.URL) (model.URL, string, error) {
+    existing, exists := s.urls[url.ID]
+    if !exists {
+        return model.URL{}, "", ErrURLNotFound
+    }
+    url.ShortCode = existing.ShortCode
+    url.Clicks = existing.Clicks
+    
+    // The new original URL may only belong to this URL
+    normalizedURL := originalKey(url.Owner, url.Original)
+    if shortCode, exists := s.normalizedToShort[normalizedURL]; exists && shortCode != url.ShortCode {
+        return model.URL{}, "", ErrOriginalURLExists
+    }
+    
+    return url, normalizedURL, nil
+}
+
+// ConsumeClick counts one click of a URL unless it has no clicks left
+func (s *MemoryStorage) ConsumeClick(ctx context.Context, id string) (model.URL, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    
+    url, err := s.prepareConsume(id)
+    if err != nil {
+        return model.URL{}, err
+    }
+    s.urls[id] = url
+    
+    return url, nil
+}
+
+// prepareConsume returns the stored URL with one more click counted, if it
+// has a click left. Caller must hold the lock.
+func (s *MemoryStorage) prepareConsume(id string) (model.URL, error) {
+    url, exists := s.urls[id]
+    if !exists {
+        return model.URL{}, ErrURLNotFound
+    }
+    if url.ClicksExhausted() {
+        return model.URL{}, ErrClicksExhausted
+    }
+    url.Clicks++
+    
+    return url, nil
+}
+
 // Delete removes a URL from storage
 func (s *MemoryStorage) Delete(ctx context.Context, id string) error {
     s.mu.Lock()
     defer s.mu.Unlock()
     
+    if _, exists := s.urls[id]; !exists {
+        return ErrURLNotFound
+    }
+    
+    s.remove(id)
+    
+    return nil
+}
+
+// List returns a page of URLs matching opts. Every call filters and sorts all
+// stored URLs, which is fine for the sizes in-memory storage is meant for.
+func (s *MemoryStorage) List(ctx context.Context, opts ListOptions) (ListResult, error) {
+    cursor, err := opts.validate()
+    if err != nil {
+        return ListResult{}, err
+    }
+    
+    s.mu.RLock()
+    matched := make([]model.URL, 0)
+    for _, url := range s.urls {
+        if !opts.matches(url) {
+            continue
+        }
+        // Skip everything up to and including the cursor
+        if cursor != nil {
+            position := positionOf(url)
+            if opts.Descending && !position.less(*cursor) || !opts.Descending && !cursor.less(position) {
+                continue
+            }
+        }
+        matched = append(matched, url)
+    }
+    s.mu.RUnlock()
+    
+    sort.Slice(matched, func(i, j int) bool {
+        if opts.Descending {
+            return positionOf(matched[j]).less(positionOf(matched[i]))
+        }
+        return positionOf(matched[i]).less(positionOf(matched[j]))
+    })
+    
+    if len(matched) > opts.Limit+1 {
+        matched = matched[:opts.Limit+1]
+    }
+    return pageOf(matched, opts.Limit), nil
+}
+
+// Count returns the number of stored URLs
+func (s *MemoryStorage) Count(ctx context.Context) (int, error) {
+    s.mu.RLock()
+    defer s.mu.RUnlock()
+    
+    return len(s.urls), nil
+}
+
+// DeleteExpired removes every URL that expired at or before now, keeping
+// those with a fallback URL until fallbackRetention has passed
+func (s *MemoryStorage) DeleteExpired(ctx context.Context, now time.Time, fallbackRetention time.Duration) (int, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    
+    expired := s.expiredIDs(now, fallbackRetention)
+    for _, id := range expired {
+        s.remove(id)
+    }
+    
+    return len(expired), nil
+}
+
+// expiredIDs returns the IDs of all URLs expired at now that are no longer
+// kept for their fallback URL. Caller must hold the lock.
+func (s *MemoryStorage) expiredIDs(now time.Time, fallbackRetention time.Duration) []string {
+    var ids []string
+    for id, url := range s.urls {
+        if url.IsExpired(now) && url.IsReplaceable(now, fallbackRetention) {
+            ids = append(ids, id)
+        }
+    }
+    return ids
+}
+
+// remove deletes a URL from all index maps. Caller must hold the lock.
+func (s *MemoryStorage) remove(id string) {
     url, exists := s.urls[id]
     if !exists {
-        return ErrURLNotFound
+        return
     }
     
-    // Get the short code and normalize the original URL before deleting
+    // Get the short code and original URL key before deleting
     shortCode := url.ShortCode
-    normalizedURL := s.normalizeURL(url.Original)
+    normalizedURL := originalKey(url.Owner, url.Original)
     
     // Remove from all maps
     delete(s.urls, id)
     delete(s.shortToURL, shortCode)
     delete(s.normalizedToShort, normalizedURL)
-    
-    return nil
 }
\ No newline at end of file
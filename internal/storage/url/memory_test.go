@@ -136,3 +136,44 @@ func TestMemoryStorage_Delete(t *testing.T) {
 		t.Errorf("Expected ErrURLNotFound but got: %v", err)
 	}
 }
+
+func TestMemoryStorage_DeleteExpired(t *testing.T) {
+	storage := NewMemoryStorage()
+	ctx := context.Background()
+	now := time.Now()
+
+	past := now.Add(-time.Minute)
+	future := now.Add(time.Hour)
+	urls := []model.URL{
+		{ID: "expired1", ShortCode: "expired1", Original: "https://github.com/a", CreatedAt: now, ExpiresAt: &past},
+		{ID: "active1", ShortCode: "active1", Original: "https://github.com/b", CreatedAt: now, ExpiresAt: &future},
+		{ID: "forever1", ShortCode: "forever1", Original: "https://github.com/c", CreatedAt: now},
+	}
+	for _, u := range urls {
+		if err := storage.Save(ctx, u); err != nil {
+			t.Fatalf("Failed to save URL: %v", err)
+		}
+	}
+
+	removed, err := storage.DeleteExpired(ctx, now, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to delete expired URLs: %v", err)
+	}
+	if removed != 1 {
+		t.Errorf("Expected 1 expired URL to be removed but got %d", removed)
+	}
+
+	// The expired URL must be gone from every index
+	if _, err := storage.GetByShortCode(ctx, "expired1"); err != ErrURLNotFound {
+		t.Errorf("Expected expired short code to be purged but got: %v", err)
+	}
+	if _, err := storage.GetByOriginalURL(ctx, "", "https://github.com/a"); err != ErrURLNotFound {
+		t.Errorf("Expected expired original URL to be purged but got: %v", err)
+	}
+
+	for _, id := range []string{"active1", "forever1"} {
+		if _, err := storage.GetByID(ctx, id); err != nil {
+			t.Errorf("Expected %s to be kept but got: %v", id, err)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package url
+
+import (
+    "context"
+    "time"
+
+    "github.com/gatij/goUrlShortener/internal/logging"
+)
+
+// DefaultReapInterval is how often the reaper looks for expired URLs
+const DefaultReapInterval = time.Minute
+
+// DefaultFallbackRetention is how long expired URLs with a fallback URL are kept
+const DefaultFallbackRetention = 7 * 24 * time.Hour
+
+// Reaper periodically purges expired URLs from a storage so their short codes
+// stop resolving and their original URLs can be shortened again. URLs with a
+// fallback URL are left alone for fallbackRetention, as their visitors are
+// still sent there.
+type Reaper struct {
+    storage           Storage
+    interval          time.Duration
+    fallbackRetention time.Duration
+    now               func() time.Time // Clock, replaceable in tests
+}
+
+// NewReaper creates a reaper for storage that runs every interval and keeps
+// expired URLs with a fallback URL for fallbackRetention
+func NewReaper(storage Storage, interval, fallbackRetention time.Duration) *Reaper {
+    if interval <= 0 {
+        interval = DefaultReapInterval
+    }
+    if fallbackRetention <= 0 {
+        fallbackRetention = DefaultFallbackRetention
+    }
+
+    return &Reaper{
+        storage:           storage,
+        interval:          interval,
+        fallbackRetention: fallbackRetention,
+        now:               time.Now,
+    }
+}
+
+// Run purges expired URLs every interval until ctx is cancelled
+func (r *Reaper) Run(ctx context.Context) {
+    ticker := time.NewTicker(r.interval)
+    defer ticker.Stop()
+
+    for {
+        select {
+        case <-ctx.Done():
+            return
+        case <-ticker.C:
+            r.ReapOnce(ctx)
+        }
+    }
+}
+
+// ReapOnce purges the URLs that are expired right now and returns how many were removed
+func (r *Reaper) ReapOnce(ctx context.Context) int {
+    removed, err := r.storage.DeleteExpired(ctx, r.now(), r.fallbackRetention)
+    if err != nil {
+        logging.FromContext(ctx).Error("Failed to purge expired URLs", "error", err)
+    }
+    if removed > 0 {
+        logging.FromContext(ctx).Info("Purged expired URLs", "count", removed)
+    }
+    return removed
+}
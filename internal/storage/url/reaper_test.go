@@ -0,0 +1,50 @@
+package url
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gatij/goUrlShortener/internal/model"
+)
+
+func TestReaper_ReapOnce(t *testing.T) {
+	storage := NewMemoryStorage()
+	ctx := context.Background()
+	now := time.Now()
+
+	expiresAt := now.Add(time.Minute)
+	url := model.URL{ID: "abc123", ShortCode: "abc123", Original: "https://github.com", CreatedAt: now, ExpiresAt: &expiresAt}
+	withFallback := model.URL{ID: "def456", ShortCode: "def456", Original: "https://go.dev", CreatedAt: now, ExpiresAt: &expiresAt, FallbackURL: "https://go.dev/doc/"}
+	for _, url := range []model.URL{url, withFallback} {
+		if err := storage.Save(ctx, url); err != nil {
+			t.Fatalf("Failed to save URL: %v", err)
+		}
+	}
+
+	reaper := NewReaper(storage, time.Second, time.Hour)
+
+	// Not expired yet
+	reaper.now = func() time.Time { return now }
+	if removed := reaper.ReapOnce(ctx); removed != 0 {
+		t.Errorf("Expected nothing to be reaped but got %d", removed)
+	}
+
+	// Past the expiry
+	reaper.now = func() time.Time { return now.Add(2 * time.Minute) }
+	if removed := reaper.ReapOnce(ctx); removed != 1 {
+		t.Errorf("Expected 1 URL to be reaped but got %d", removed)
+	}
+	if _, err := storage.GetByID(ctx, "abc123"); err != ErrURLNotFound {
+		t.Errorf("Expected URL to be reaped but got: %v", err)
+	}
+
+	// Past the fallback retention
+	reaper.now = func() time.Time { return now.Add(2 * time.Hour) }
+	if removed := reaper.ReapOnce(ctx); removed != 1 {
+		t.Errorf("Expected the URL with a fallback to be reaped but got %d", removed)
+	}
+	if _, err := storage.GetByID(ctx, "def456"); err != ErrURLNotFound {
+		t.Errorf("Expected URL with a fallback to be reaped but got: %v", err)
+	}
+}
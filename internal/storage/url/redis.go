@@ -0,0 +1,519 @@
+package url
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/redis/go-redis/v9"
+    "github.com/gatij/goUrlShortener/internal/model"
+)
+
+// Lua fragments shared by the scripts below. KEYS are, in order, the URLs,
+// short codes, normalized URLs, expiry, list index, list positions and owner
+// list index keys (see RedisStorage). ARGV starts with the ID, JSON, short code, original URL
+// key, expiry as unix milliseconds (empty for none) and list position.
+const (
+    // luaInsert stores the URL in ARGV and all of its indexes
+    luaInsert = `
+redis.call('HSET', KEYS[1], ARGV[1], ARGV[2])
+redis.call('HSET', KEYS[2], ARGV[3], ARGV[1])
+redis.call('HSET', KEYS[3], ARGV[4], ARGV[3])
+if ARGV[5] ~= '' then
+    redis.call('ZADD', KEYS[4], ARGV[5], ARGV[1])
+end
+redis.call('ZADD', KEYS[5], 0, ARGV[6])
+redis.call('ZADD', KEYS[7], 0, ARGV[6])
+redis.call('HSET', KEYS[6], ARGV[1], ARGV[6])
+`
+
+    // luaUnlist defines unlist(id, ownerList), which drops an ID from the
+    // list index and from ownerList, the list index of its owner
+    luaUnlist = `
+local function unlist(id, ownerList)
+    local position = redis.call('HGET', KEYS[6], id)
+    if position then
+        redis.call('ZREM', KEYS[5], position)
+        redis.call('ZREM', ownerList, position)
+        redis.call('HDEL', KEYS[6], id)
+    end
+end
+`
+)
+
+// saveScript inserts a URL and its indexes atomically.
+// Returns 1 if the ID exists, 2 if the normalized URL exists and 0 on success.
+var saveScript = redis.NewScript(`
+if redis.call('HEXISTS', KEYS[1], ARGV[1]) == 1 then return 1 end
+if redis.call('HEXISTS', KEYS[3], ARGV[4]) == 1 then return 2 end
+` + luaInsert + `
+return 0
+`)
+
+// getOrCreateScript returns the live URL stored for a normalized URL or inserts
+// a new one, atomically. ARGV[7] is the current time as unix milliseconds; an
+// existing entry expired by then or out of clicks is removed first.
+// Returns {0} when inserted, {1, json} for an existing URL and {2} if the ID exists.
+var getOrCreateScript = redis.NewScript(luaUnlist + `
+local existingCode = redis.call('HGET', KEYS[3], ARGV[4])
+if existingCode then
+    local existingID = redis.call('HGET', KEYS[2], existingCode)
+    if existingID then
+        local expiry = redis.call('ZSCORE', KEYS[4], existingID)
+        local data = redis.call('HGET', KEYS[1], existingID)
+        if data then
+            local existing = cjson.decode(data)
+            local expired = expiry and tonumber(expiry) <= tonumber(ARGV[7])
+            local maxClicks = existing['max_clicks'] or 0
+            if expired and (existing['fallback_url'] or '') ~= '' then
+                -- Kept for its fallback URL until DeleteExpired removes it
+                return {1, data}
+            end
+            if not expired and (maxClicks == 0 or (existing['clicks'] or 0) < maxClicks) then
+                return {1, data}
+            end
+        end
+        redis.call('HDEL', KEYS[1], existingID)
+        redis.call('ZREM', KEYS[4], existingID)
+        unlist(existingID, KEYS[7])
+    end
+    redis.call('HDEL', KEYS[2], existingCode)
+    redis.call('HDEL', KEYS[3], ARGV[4])
+end
+if redis.call('HEXISTS', KEYS[1], ARGV[1]) == 1 then return {2} end
+` + luaInsert + `
+return {0}
+`)
+
+// updateScript replaces a URL and moves its index entries, provided the stored
+// JSON still equals ARGV[7]. ARGV[8] is the normalized original URL being
+// replaced and KEYS[8] the list index of its owner.
+// Returns 1 if the ID is unknown, 2 if the new normalized URL belongs to
+// another short code, 3 if the URL changed concurrently and 0 on success.
+var updateScript = redis.NewScript(luaUnlist + `
+local current = redis.call('HGET', KEYS[1], ARGV[1])
+if not current then return 1 end
+if current ~= ARGV[7] then return 3 end
+local owner = redis.call('HGET', KEYS[3], ARGV[4])
+if owner and owner ~= ARGV[3] then return 2 end
+redis.call('HDEL', KEYS[3], ARGV[8])
+redis.call('ZREM', KEYS[4], ARGV[1])
+unlist(ARGV[1], KEYS[8])
+` + luaInsert + `
+return 0
+`)
+
+// deleteScript removes a URL and its indexes atomically. ARGV is the ID, short
+// code and normalized URL. Returns 0 if the ID is unknown.
+var deleteScript = redis.NewScript(luaUnlist + `
+if redis.call('HDEL', KEYS[1], ARGV[1]) == 0 then return 0 end
+redis.call('HDEL', KEYS[2], ARGV[2])
+redis.call('ZREM', KEYS[4], ARGV[1])
+unlist(ARGV[1], KEYS[7])
+if redis.call('HGET', KEYS[3], ARGV[3]) == ARGV[2] then
+    redis.call('HDEL', KEYS[3], ARGV[3])
+end
+return 1
+`)
+
+// consumeClickScript counts one click of the URL with ID ARGV[1] in its JSON,
+// unless it is click-limited and has none left.
+// Returns {0} if the ID is unknown, {1} if no clicks are left and {2, json}
+// with the updated URL on success.
+var consumeClickScript = redis.NewScript(`
+local current = redis.call('HGET', KEYS[1], ARGV[1])
+if not current then return {0} end
+local url = cjson.decode(current)
+local clicks = url['clicks'] or 0
+local maxClicks = url['max_clicks'] or 0
+if maxClicks > 0 and clicks >= maxClicks then return {1} end
+url['clicks'] = clicks + 1
+local updated = cjson.encode(url)
+redis.call('HSET', KEYS[1], ARGV[1], updated)
+return {2, updated}
+`)
+
+// RedisStorage implements the Storage interface on top of Redis so several
+// server instances can share the same links
+type RedisStorage struct {
+    client        redis.UniversalClient
+    urlsKey       string // Hash of ID to JSON encoded URL
+    shortCodesKey string // Hash of short code to ID
+    normalizedKey string // Hash of owner and normalized original URL (see originalKey) to short code
+    expiryKey     string // Sorted set of ID scored by expiry time
+    listKey       string // Sorted set of list positions, all scored 0 and ordered by member; per owner under listKey:owner
+    positionsKey  string // Hash of ID to list position
+}
+
+// NewRedisStorage creates a Redis-backed URL storage. All keys share a hash tag
+// derived from prefix so the scripts also work against Redis Cluster.
+func NewRedisStorage(client redis.UniversalClient, prefix string) *RedisStorage {
+    tag := "{" + prefix + "}:"
+
+    return &RedisStorage{
+        client:        client,
+        urlsKey:       tag + "urls",
+        shortCodesKey: tag + "short_codes",
+        normalizedKey: tag + "normalized",
+        expiryKey:     tag + "expiry",
+        listKey:       tag + "list",
+        positionsKey:  tag + "list_positions",
+    }
+}
+
+// keys returns the keys the scripts operate on for a URL of owner, in KEYS order
+func (s *RedisStorage) keys(owner string) []string {
+    return []string{s.urlsKey, s.shortCodesKey, s.normalizedKey, s.expiryKey, s.listKey, s.positionsKey, s.ownerListKey(owner)}
+}
+
+// ownerListKey returns the list index of the URLs of owner. URLs without an
+// owner are only in the list index itself.
+func (s *RedisStorage) ownerListKey(owner string) string {
+    if owner == "" {
+        return s.listKey
+    }
+    return s.listKey + ":" + owner
+}
+
+// Save stores a new shortened URL
+func (s *RedisStorage) Save(ctx context.Context, url model.URL) error {
+    args, err := s.saveArgs(url)
+    if err != nil {
+        return err
+    }
+
+    result, err := saveScript.Run(ctx, s.client, s.keys(url.Owner), args...).Int()
+    if err != nil {
+        return err
+    }
+
+    switch result {
+    case 1:
+        return ErrURLExists
+    case 2:
+        return ErrOriginalURLExists
+    }
+
+    return nil
+}
+
+// GetOrCreate returns the live URL url.Owner stored for url.Original, or stores url
+func (s *RedisStorage) GetOrCreate(ctx context.Context, url model.URL) (model.URL, bool, error) {
+    args, err := s.saveArgs(url)
+    if err != nil {
+        return model.URL{}, false, err
+    }
+    args = append(args, url.CreatedAt.UnixMilli())
+
+    result, err := getOrCreateScript.Run(ctx, s.client, s.keys(url.Owner), args...).Slice()
+    if err != nil {
+        return model.URL{}, false, err
+    }
+
+    status, _ := result[0].(int64)
+    switch status {
+    case 1:
+        data, _ := result[1].(string)
+        var existing model.URL
+        if err := json.Unmarshal([]byte(data), &existing); err != nil {
+            return model.URL{}, false, err
+        }
+        return existing, false, nil
+    case 2:
+        return model.URL{}, false, ErrURLExists
+    }
+
+    return url, true, nil
+}
+
+// saveArgs builds the ARGV shared by the save scripts
+func (s *RedisStorage) saveArgs(url model.URL) ([]interface{}, error) {
+    data, err := json.Marshal(url)
+    if err != nil {
+        return nil, err
+    }
+
+    expiry := ""
+    if url.ExpiresAt != nil {
+        expiry = strconv.FormatInt(url.ExpiresAt.UnixMilli(), 10)
+    }
+
+    return []interface{}{url.ID, data, url.ShortCode, originalKey(url.Owner, url.Original), expiry, listMember(url)}, nil
+}
+
+// listMember returns the list index member of a URL. Zero-padding the creation
+// time makes the lexicographic order of members match the List ordering.
+func listMember(url model.URL) string {
+    return fmt.Sprintf("%020d:%s", url.CreatedAt.UnixNano(), url.ID)
+}
+
+// GetByID retrieves a URL by its ID
+func (s *RedisStorage) GetByID(ctx context.Context, id string) (model.URL, error) {
+    data, err := s.client.HGet(ctx, s.urlsKey, id).Bytes()
+    if errors.Is(err, redis.Nil) {
+        return model.URL{}, ErrURLNotFound
+    }
+    if err != nil {
+        return model.URL{}, err
+    }
+
+    var url model.URL
+    if err := json.Unmarshal(data, &url); err != nil {
+        return model.URL{}, err
+    }
+
+    return url, nil
+}
+
+// GetByShortCode retrieves a URL by its short code
+func (s *RedisStorage) GetByShortCode(ctx context.Context, shortCode string) (model.URL, error) {
+    id, err := s.client.HGet(ctx, s.shortCodesKey, shortCode).Result()
+    if errors.Is(err, redis.Nil) {
+        return model.URL{}, ErrURLNotFound
+    }
+    if err != nil {
+        return model.URL{}, err
+    }
+
+    return s.GetByID(ctx, id)
+}
+
+// GetByOriginalURL retrieves the URL owner stored for an original URL
+func (s *RedisStorage) GetByOriginalURL(ctx context.Context, owner, originalURL string) (model.URL, error) {
+    shortCode, err := s.client.HGet(ctx, s.normalizedKey, originalKey(owner, originalURL)).Result()
+    if errors.Is(err, redis.Nil) {
+        return model.URL{}, ErrURLNotFound
+    }
+    if err != nil {
+        return model.URL{}, err
+    }
+
+    return s.GetByShortCode(ctx, shortCode)
+}
+
+// Update replaces a stored URL, keeping its short code. The script only
+// applies if the URL is unchanged since it was read, otherwise it is retried.
+func (s *RedisStorage) Update(ctx context.Context, url model.URL) error {
+    for attempt := 0; attempt < maxCreateAttempts; attempt++ {
+        current, err := s.client.HGet(ctx, s.urlsKey, url.ID).Result()
+        if errors.Is(err, redis.Nil) {
+            return ErrURLNotFound
+        }
+        if err != nil {
+            return err
+        }
+
+        var existing model.URL
+        if err := json.Unmarshal([]byte(current), &existing); err != nil {
+            return err
+        }
+        url.ShortCode = existing.ShortCode
+        url.Clicks = existing.Clicks
+
+        args, err := s.saveArgs(url)
+        if err != nil {
+            return err
+        }
+        args = append(args, current, originalKey(existing.Owner, existing.Original))
+
+        result, err := updateScript.Run(ctx, s.client, append(s.keys(url.Owner), s.ownerListKey(existing.Owner)), args...).Int()
+        if err != nil {
+            return err
+        }
+        switch result {
+        case 0:
+            return nil
+        case 1:
+            return ErrURLNotFound
+        case 2:
+            return ErrOriginalURLExists
+        }
+        // Changed since it was read, try again
+    }
+
+    return fmt.Errorf("update %s: too much contention", url.ID)
+}
+
+// ConsumeClick counts one click of a URL unless it has no clicks left. The
+// script checks and counts in one step, so concurrent clicks from several
+// instances can't overspend the limit.
+func (s *RedisStorage) ConsumeClick(ctx context.Context, id string) (model.URL, error) {
+    result, err := consumeClickScript.Run(ctx, s.client, []string{s.urlsKey}, id).Slice()
+    if err != nil {
+        return model.URL{}, err
+    }
+
+    status, _ := result[0].(int64)
+    switch status {
+    case 0:
+        return model.URL{}, ErrURLNotFound
+    case 1:
+        return model.URL{}, ErrClicksExhausted
+    }
+
+    data, _ := result[1].(string)
+    var url model.URL
+    if err := json.Unmarshal([]byte(data), &url); err != nil {
+        return model.URL{}, err
+    }
+    return url, nil
+}
+
+// Delete removes a URL from storage
+func (s *RedisStorage) Delete(ctx context.Context, id string) error {
+    url, err := s.GetByID(ctx, id)
+    if err != nil {
+        return err
+    }
+
+    deleted, err := deleteScript.Run(ctx, s.client, s.keys(url.Owner),
+        url.ID, url.ShortCode, originalKey(url.Owner, url.Original),
+    ).Int()
+    if err != nil {
+        return err
+    }
+    if deleted == 0 {
+        return ErrURLNotFound
+    }
+
+    return nil
+}
+
+// listBatch is how many list index entries List reads per round trip
+const listBatch = 100
+
+// List returns a page of URLs matching opts by walking the list index, or the
+// owner's if opts has one, in order and filtering each batch, until the page
+// is full or the index ends
+func (s *RedisStorage) List(ctx context.Context, opts ListOptions) (ListResult, error) {
+    cursor, err := opts.validate()
+    if err != nil {
+        return ListResult{}, err
+    }
+    listKey := s.ownerListKey(opts.Owner)
+
+    // Members start with the zero-padded creation time, so the creation time
+    // range maps to a range of member prefixes
+    min, max := "-", "+"
+    if !opts.CreatedFrom.IsZero() {
+        min = fmt.Sprintf("[%020d:", opts.CreatedFrom.UnixNano())
+    }
+    if !opts.CreatedBefore.IsZero() {
+        max = fmt.Sprintf("(%020d:", opts.CreatedBefore.UnixNano())
+    }
+    if cursor != nil {
+        member := fmt.Sprintf("%020d:%s", cursor.createdAt, cursor.id)
+        if opts.Descending && (max == "+" || member < max[1:]) {
+            max = "(" + member
+        }
+        if !opts.Descending && (min == "-" || member >= min[1:]) {
+            min = "(" + member
+        }
+    }
+
+    matched := make([]model.URL, 0, opts.Limit+1)
+    for len(matched) <= opts.Limit {
+        bounds := &redis.ZRangeBy{Min: min, Max: max, Count: listBatch}
+        var members []string
+        if opts.Descending {
+            members, err = s.client.ZRevRangeByLex(ctx, listKey, bounds).Result()
+        } else {
+            members, err = s.client.ZRangeByLex(ctx, listKey, bounds).Result()
+        }
+        if err != nil {
+            return ListResult{}, err
+        }
+        if len(members) == 0 {
+            break
+        }
+
+        ids := make([]string, len(members))
+        for i, member := range members {
+            _, ids[i], _ = strings.Cut(member, ":")
+        }
+        values, err := s.client.HMGet(ctx, s.urlsKey, ids...).Result()
+        if err != nil {
+            return ListResult{}, err
+        }
+
+        for _, value := range values {
+            data, ok := value.(string)
+            if !ok {
+                // Deleted since the index was read
+                continue
+            }
+            var url model.URL
+            if err := json.Unmarshal([]byte(data), &url); err != nil {
+                return ListResult{}, err
+            }
+            if opts.matches(url) {
+                matched = append(matched, url)
+                if len(matched) > opts.Limit {
+                    break
+                }
+            }
+        }
+
+        // Continue after the last member read
+        last := members[len(members)-1]
+        if opts.Descending {
+            max = "(" + last
+        } else {
+            min = "(" + last
+        }
+        if len(members) < listBatch {
+            break
+        }
+    }
+
+    return pageOf(matched, opts.Limit), nil
+}
+
+// Count returns the number of stored URLs
+func (s *RedisStorage) Count(ctx context.Context) (int, error) {
+    count, err := s.client.HLen(ctx, s.urlsKey).Result()
+    return int(count), err
+}
+
+// DeleteExpired removes every URL that expired at or before now, keeping
+// those with a fallback URL until fallbackRetention has passed. Those are
+// read again on every run while they are kept.
+func (s *RedisStorage) DeleteExpired(ctx context.Context, now time.Time, fallbackRetention time.Duration) (int, error) {
+    ids, err := s.client.ZRangeByScore(ctx, s.expiryKey, &redis.ZRangeBy{
+        Min: "-inf",
+        Max: strconv.FormatInt(now.UnixMilli(), 10),
+    }).Result()
+    if err != nil {
+        return 0, err
+    }
+
+    removed := 0
+    for _, id := range ids {
+        url, err := s.GetByID(ctx, id)
+        if err == ErrURLNotFound {
+            // Another instance reaped it first
+            continue
+        }
+        if err != nil {
+            return removed, err
+        }
+        if !url.IsReplaceable(now, fallbackRetention) {
+            continue
+        }
+
+        err = s.Delete(ctx, id)
+        if err == ErrURLNotFound {
+            continue
+        }
+        if err != nil {
+            return removed, err
+        }
+        removed++
+    }
+
+    return removed, nil
+}
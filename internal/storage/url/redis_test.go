@@ -0,0 +1,112 @@
+package url
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gatij/goUrlShortener/internal/model"
+	"github.com/gatij/goUrlShortener/internal/storage/storagetest"
+)
+
+func TestRedisStorage_SaveAndGet(t *testing.T) {
+	server := miniredis.RunT(t)
+	storage := NewRedisStorage(storagetest.RedisClient(t, server), "test")
+	ctx := context.Background()
+
+	expectedURL := model.URL{
+		ID:        "abc123",
+		ShortCode: "abc123",
+		Original:  "https://github.com/golang/go",
+		CreatedAt: time.Now(),
+	}
+
+	if err := storage.Save(ctx, expectedURL); err != nil {
+		t.Fatalf("Failed to save URL: %v", err)
+	}
+	if err := storage.Save(ctx, expectedURL); err != ErrURLExists {
+		t.Errorf("Expected ErrURLExists but got: %v", err)
+	}
+
+	url, err := storage.GetByShortCode(ctx, "abc123")
+	if err != nil {
+		t.Fatalf("Failed to get URL by short code: %v", err)
+	}
+	if url.ID != expectedURL.ID || url.Original != expectedURL.Original {
+		t.Errorf("Expected URL %+v but got %+v", expectedURL, url)
+	}
+
+	url, err = storage.GetByOriginalURL(ctx, "", "https://github.com/golang/go/")
+	if err != nil {
+		t.Fatalf("Failed to get URL by original URL: %v", err)
+	}
+	if url.ShortCode != "abc123" {
+		t.Errorf("Expected short code abc123 but got %s", url.ShortCode)
+	}
+
+	if _, err := storage.GetByID(ctx, "nonexistent"); err != ErrURLNotFound {
+		t.Errorf("Expected ErrURLNotFound but got: %v", err)
+	}
+}
+
+func TestRedisStorage_SharedBetweenInstances(t *testing.T) {
+	server := miniredis.RunT(t)
+	ctx := context.Background()
+
+	// Two replicas with their own connections to the same server
+	first := NewRedisStorage(storagetest.RedisClient(t, server), "test")
+	second := NewRedisStorage(storagetest.RedisClient(t, server), "test")
+
+	url := model.URL{ID: "abc123", ShortCode: "abc123", Original: "https://github.com", CreatedAt: time.Now()}
+	if err := first.Save(ctx, url); err != nil {
+		t.Fatalf("Failed to save URL: %v", err)
+	}
+
+	if _, err := second.GetByShortCode(ctx, "abc123"); err != nil {
+		t.Errorf("Expected URL saved by one instance to be visible to another but got: %v", err)
+	}
+
+	if err := second.Delete(ctx, "abc123"); err != nil {
+		t.Fatalf("Failed to delete URL: %v", err)
+	}
+	if _, err := first.GetByOriginalURL(ctx, "", "https://github.com"); err != ErrURLNotFound {
+		t.Errorf("Expected normalized index to be cleared but got: %v", err)
+	}
+	if err := first.Delete(ctx, "abc123"); err != ErrURLNotFound {
+		t.Errorf("Expected ErrURLNotFound but got: %v", err)
+	}
+}
+
+func TestRedisStorage_DeleteExpired(t *testing.T) {
+	server := miniredis.RunT(t)
+	storage := NewRedisStorage(storagetest.RedisClient(t, server), "test")
+	ctx := context.Background()
+	now := time.Now()
+
+	past := now.Add(-time.Minute)
+	future := now.Add(time.Hour)
+	urls := []model.URL{
+		{ID: "expired1", ShortCode: "expired1", Original: "https://github.com/a", CreatedAt: now, ExpiresAt: &past},
+		{ID: "active1", ShortCode: "active1", Original: "https://github.com/b", CreatedAt: now, ExpiresAt: &future},
+	}
+	for _, u := range urls {
+		if err := storage.Save(ctx, u); err != nil {
+			t.Fatalf("Failed to save URL: %v", err)
+		}
+	}
+
+	removed, err := storage.DeleteExpired(ctx, now, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to delete expired URLs: %v", err)
+	}
+	if removed != 1 {
+		t.Errorf("Expected 1 expired URL to be removed but got %d", removed)
+	}
+	if _, err := storage.GetByOriginalURL(ctx, "", "https://github.com/a"); err != ErrURLNotFound {
+		t.Errorf("Expected expired URL to be purged but got: %v", err)
+	}
+	if _, err := storage.GetByID(ctx, "active1"); err != nil {
+		t.Errorf("Expected active URL to be kept but got: %v", err)
+	}
+}
@@ -0,0 +1,367 @@
+package url
+
+import (
+    "context"
+    "database/sql"
+    "errors"
+    "fmt"
+    "strings"
+    "time"
+
+    "github.com/gatij/goUrlShortener/internal/model"
+)
+
+// urlSchema creates the urls table. Short codes and normalized original URLs,
+// prefixed by the owner (see originalKey), are unique so the database enforces
+// the same invariants as the in-memory maps. Optional timestamps are NULL when
+// unset; every other column has a value.
+const urlSchema = `
+CREATE TABLE IF NOT EXISTS urls (
+    id              TEXT PRIMARY KEY,
+    short_code      TEXT NOT NULL,
+    original        TEXT NOT NULL,
+    normalized      TEXT NOT NULL,
+    domain          TEXT NOT NULL,
+    created_at      INTEGER NOT NULL,
+    expires_at      INTEGER,
+    owner           TEXT NOT NULL DEFAULT '',
+    redirect_status INTEGER NOT NULL DEFAULT 0,
+    preview         INTEGER NOT NULL DEFAULT 0,
+    password_hash   TEXT NOT NULL DEFAULT '',
+    max_clicks      INTEGER NOT NULL DEFAULT 0,
+    clicks          INTEGER NOT NULL DEFAULT 0,
+    not_before      INTEGER,
+    not_after       INTEGER,
+    fallback_url    TEXT NOT NULL DEFAULT ''
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_urls_short_code ON urls (short_code);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_urls_normalized ON urls (normalized);
+CREATE INDEX IF NOT EXISTS idx_urls_expires_at ON urls (expires_at);
+CREATE INDEX IF NOT EXISTS idx_urls_created_at ON urls (created_at, id);
+CREATE INDEX IF NOT EXISTS idx_urls_domain ON urls (domain, created_at, id);
+CREATE INDEX IF NOT EXISTS idx_urls_owner ON urls (owner, created_at, id);
+`
+
+// urlColumns lists the columns read by every query, in scan order
+const urlColumns = `id, short_code, original, created_at, expires_at, owner, redirect_status, preview, password_hash, max_clicks, clicks, not_before, not_after, fallback_url`
+
+// maxCreateAttempts bounds how often GetOrCreate and Update retry after losing a race
+const maxCreateAttempts = 3
+
+// SQLStorage implements the Storage interface on top of a SQL database
+type SQLStorage struct {
+    db *sql.DB
+}
+
+// NewSQLStorage creates a SQL-backed URL storage, creating the schema if needed
+func NewSQLStorage(ctx context.Context, db *sql.DB) (*SQLStorage, error) {
+    if _, err := db.ExecContext(ctx, urlSchema); err != nil {
+        return nil, fmt.Errorf("create urls schema: %w", err)
+    }
+
+    return &SQLStorage{db: db}, nil
+}
+
+// Save stores a new shortened URL
+func (s *SQLStorage) Save(ctx context.Context, url model.URL) error {
+    // Ignore conflicts here and work out which constraint was hit below
+    result, err := s.db.ExecContext(ctx,
+        `INSERT INTO urls (id, short_code, original, normalized, domain, created_at, expires_at, owner, redirect_status, preview, password_hash, max_clicks, clicks, not_before, not_after, fallback_url)
+         VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+         ON CONFLICT DO NOTHING`,
+        url.ID, url.ShortCode, url.Original, originalKey(url.Owner, url.Original), urlDomain(url.Original),
+        url.CreatedAt.UnixNano(), nullableTime(url.ExpiresAt), url.Owner, url.RedirectStatus, url.Preview, url.PasswordHash,
+        url.MaxClicks, url.Clicks, nullableTime(url.NotBefore), nullableTime(url.NotAfter), url.FallbackURL,
+    )
+    if err != nil {
+        return err
+    }
+
+    inserted, err := result.RowsAffected()
+    if err != nil {
+        return err
+    }
+    if inserted > 0 {
+        return nil
+    }
+
+    // Nothing was inserted, report whether the ID or the original URL clashed
+    if _, err := s.GetByID(ctx, url.ID); err == nil {
+        return ErrURLExists
+    } else if err != ErrURLNotFound {
+        return err
+    }
+
+    return ErrOriginalURLExists
+}
+
+// GetOrCreate returns the live URL url.Owner stored for url.Original, or
+// stores url. The unique index on the normalized URL guarantees a single winner when
+// several requests race; losers look up the winner and return it.
+func (s *SQLStorage) GetOrCreate(ctx context.Context, url model.URL) (model.URL, bool, error) {
+    for attempt := 0; attempt < maxCreateAttempts; attempt++ {
+        existing, err := s.GetByOriginalURL(ctx, url.Owner, url.Original)
+        switch {
+        case err == nil && (!existing.IsSpent(url.CreatedAt) || existing.KeepsFallback(url.CreatedAt)):
+            return existing, false, nil
+        case err == nil:
+            // Free up the original URL, unless someone else replaced it already
+            _, err := s.db.ExecContext(ctx,
+                `DELETE FROM urls WHERE id = ?
+                 AND (expires_at IS NOT NULL AND expires_at <= ? AND fallback_url = ''
+                      OR (expires_at IS NULL OR expires_at > ?) AND max_clicks > 0 AND clicks >= max_clicks)`,
+                existing.ID, url.CreatedAt.UnixNano(), url.CreatedAt.UnixNano(),
+            )
+            if err != nil {
+                return model.URL{}, false, err
+            }
+        case err != ErrURLNotFound:
+            return model.URL{}, false, err
+        }
+
+        err = s.Save(ctx, url)
+        if err == nil {
+            return url, true, nil
+        }
+        if err != ErrOriginalURLExists {
+            return model.URL{}, false, err
+        }
+        // Another request stored the same URL in the meantime, look it up again
+    }
+
+    return model.URL{}, false, fmt.Errorf("get or create %s: too much contention", url.Original)
+}
+
+// GetByID retrieves a URL by its ID
+func (s *SQLStorage) GetByID(ctx context.Context, id string) (model.URL, error) {
+    row := s.db.QueryRowContext(ctx, `SELECT `+urlColumns+` FROM urls WHERE id = ?`, id)
+    return scanURL(row)
+}
+
+// GetByShortCode retrieves a URL by its short code
+func (s *SQLStorage) GetByShortCode(ctx context.Context, shortCode string) (model.URL, error) {
+    row := s.db.QueryRowContext(ctx, `SELECT `+urlColumns+` FROM urls WHERE short_code = ?`, shortCode)
+    return scanURL(row)
+}
+
+// GetByOriginalURL retrieves the URL owner stored for an original URL
+func (s *SQLStorage) GetByOriginalURL(ctx context.Context, owner, originalURL string) (model.URL, error) {
+    row := s.db.QueryRowContext(ctx, `SELECT `+urlColumns+` FROM urls WHERE normalized = ?`, originalKey(owner, originalURL))
+    return scanURL(row)
+}
+
+// Update replaces a stored URL, keeping its short code and click count
+func (s *SQLStorage) Update(ctx context.Context, url model.URL) error {
+    // OR IGNORE skips the row instead of failing when the new original URL
+    // hits the unique index; which case applies is worked out below
+    result, err := s.db.ExecContext(ctx,
+        `UPDATE OR IGNORE urls
+         SET original = ?, normalized = ?, domain = ?, created_at = ?, expires_at = ?, owner = ?, redirect_status = ?, preview = ?, password_hash = ?, max_clicks = ?,
+             not_before = ?, not_after = ?, fallback_url = ?
+         WHERE id = ?`,
+        url.Original, originalKey(url.Owner, url.Original), urlDomain(url.Original),
+        url.CreatedAt.UnixNano(), nullableTime(url.ExpiresAt), url.Owner, url.RedirectStatus, url.Preview, url.PasswordHash, url.MaxClicks,
+        nullableTime(url.NotBefore), nullableTime(url.NotAfter), url.FallbackURL,
+        url.ID,
+    )
+    if err != nil {
+        return err
+    }
+
+    updated, err := result.RowsAffected()
+    if err != nil {
+        return err
+    }
+    if updated > 0 {
+        return nil
+    }
+
+    // Nothing was updated, report whether the ID is unknown or the original URL clashed
+    if _, err := s.GetByID(ctx, url.ID); err != nil {
+        return err
+    }
+    return ErrOriginalURLExists
+}
+
+// ConsumeClick counts one click of a URL unless it has no clicks left. The
+// check and the increment are a single statement, so concurrent clicks can't
+// overspend the limit.
+func (s *SQLStorage) ConsumeClick(ctx context.Context, id string) (model.URL, error) {
+    row := s.db.QueryRowContext(ctx,
+        `UPDATE urls SET clicks = clicks + 1
+         WHERE id = ? AND (max_clicks = 0 OR clicks < max_clicks)
+         RETURNING `+urlColumns,
+        id,
+    )
+    url, err := scanURL(row)
+    if err != ErrURLNotFound {
+        return url, err
+    }
+
+    // Nothing was updated, report whether the ID is unknown or out of clicks
+    if _, err := s.GetByID(ctx, id); err != nil {
+        return model.URL{}, err
+    }
+    return model.URL{}, ErrClicksExhausted
+}
+
+// Delete removes a URL from storage
+func (s *SQLStorage) Delete(ctx context.Context, id string) error {
+    result, err := s.db.ExecContext(ctx, `DELETE FROM urls WHERE id = ?`, id)
+    if err != nil {
+        return err
+    }
+
+    deleted, err := result.RowsAffected()
+    if err != nil {
+        return err
+    }
+    if deleted == 0 {
+        return ErrURLNotFound
+    }
+
+    return nil
+}
+
+// List returns a page of URLs matching opts using keyset pagination on
+// (created_at, id), so later pages cost the same as the first
+func (s *SQLStorage) List(ctx context.Context, opts ListOptions) (ListResult, error) {
+    cursor, err := opts.validate()
+    if err != nil {
+        return ListResult{}, err
+    }
+
+    var (
+        conditions []string
+        args       []any
+    )
+    if opts.Owner != "" {
+        conditions = append(conditions, "owner = ?")
+        args = append(args, opts.Owner)
+    }
+    if opts.Domain != "" {
+        conditions = append(conditions, "domain = ?")
+        args = append(args, strings.ToLower(opts.Domain))
+    }
+    if opts.Contains != "" {
+        // instr rather than LIKE so % and _ in the search text are literal
+        conditions = append(conditions, "instr(lower(original), ?) > 0")
+        args = append(args, strings.ToLower(opts.Contains))
+    }
+    if !opts.CreatedFrom.IsZero() {
+        conditions = append(conditions, "created_at >= ?")
+        args = append(args, opts.CreatedFrom.UnixNano())
+    }
+    if !opts.CreatedBefore.IsZero() {
+        conditions = append(conditions, "created_at < ?")
+        args = append(args, opts.CreatedBefore.UnixNano())
+    }
+
+    direction, compare := "ASC", ">"
+    if opts.Descending {
+        direction, compare = "DESC", "<"
+    }
+    if cursor != nil {
+        conditions = append(conditions, "(created_at "+compare+" ? OR (created_at = ? AND id "+compare+" ?))")
+        args = append(args, cursor.createdAt, cursor.createdAt, cursor.id)
+    }
+
+    query := `SELECT ` + urlColumns + ` FROM urls`
+    if len(conditions) > 0 {
+        query += ` WHERE ` + strings.Join(conditions, " AND ")
+    }
+    query += ` ORDER BY created_at ` + direction + `, id ` + direction + ` LIMIT ?`
+    // Fetch one extra row to find out whether there is another page
+    args = append(args, opts.Limit+1)
+
+    rows, err := s.db.QueryContext(ctx, query, args...)
+    if err != nil {
+        return ListResult{}, err
+    }
+    defer rows.Close()
+
+    urls := make([]model.URL, 0, opts.Limit+1)
+    for rows.Next() {
+        url, err := scanURL(rows)
+        if err != nil {
+            return ListResult{}, err
+        }
+        urls = append(urls, url)
+    }
+    if err := rows.Err(); err != nil {
+        return ListResult{}, err
+    }
+
+    return pageOf(urls, opts.Limit), nil
+}
+
+// Count returns the number of stored URLs
+func (s *SQLStorage) Count(ctx context.Context) (int, error) {
+    var count int
+    err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM urls`).Scan(&count)
+    return count, err
+}
+
+// DeleteExpired removes every URL that expired at or before now, keeping
+// those with a fallback URL until fallbackRetention has passed
+func (s *SQLStorage) DeleteExpired(ctx context.Context, now time.Time, fallbackRetention time.Duration) (int, error) {
+    result, err := s.db.ExecContext(ctx,
+        `DELETE FROM urls
+         WHERE expires_at IS NOT NULL AND expires_at <= ? AND (fallback_url = '' OR expires_at <= ?)`,
+        now.UnixNano(), now.Add(-fallbackRetention).UnixNano(),
+    )
+    if err != nil {
+        return 0, err
+    }
+
+    deleted, err := result.RowsAffected()
+    return int(deleted), err
+}
+
+// nullableTime converts an optional timestamp to a nullable integer column value
+func nullableTime(t *time.Time) sql.NullInt64 {
+    if t == nil {
+        return sql.NullInt64{}
+    }
+    return sql.NullInt64{Int64: t.UnixNano(), Valid: true}
+}
+
+// timeFromNullable converts a nullable integer column value back to an optional timestamp
+func timeFromNullable(n sql.NullInt64) *time.Time {
+    if !n.Valid {
+        return nil
+    }
+    t := time.Unix(0, n.Int64)
+    return &t
+}
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+    Scan(dest ...any) error
+}
+
+// scanURL reads a single URL selected with urlColumns
+func scanURL(row rowScanner) (model.URL, error) {
+    var (
+        url       model.URL
+        createdAt int64
+        expiresAt sql.NullInt64
+        notBefore sql.NullInt64
+        notAfter  sql.NullInt64
+    )
+
+    err := row.Scan(&url.ID, &url.ShortCode, &url.Original, &createdAt, &expiresAt, &url.Owner, &url.RedirectStatus,
+        &url.Preview, &url.PasswordHash, &url.MaxClicks, &url.Clicks, &notBefore, &notAfter, &url.FallbackURL)
+    if errors.Is(err, sql.ErrNoRows) {
+        return model.URL{}, ErrURLNotFound
+    }
+    if err != nil {
+        return model.URL{}, err
+    }
+
+    url.CreatedAt = time.Unix(0, createdAt)
+    url.ExpiresAt = timeFromNullable(expiresAt)
+    url.NotBefore = timeFromNullable(notBefore)
+    url.NotAfter = timeFromNullable(notAfter)
+    return url, nil
+}
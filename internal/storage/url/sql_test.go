@@ -0,0 +1,128 @@
+package url
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gatij/goUrlShortener/internal/model"
+	"github.com/gatij/goUrlShortener/internal/storage/storagetest"
+)
+
+func newTestSQLStorage(t *testing.T) *SQLStorage {
+	t.Helper()
+
+	storage, err := NewSQLStorage(context.Background(), storagetest.SQLite(t))
+	if err != nil {
+		t.Fatalf("Failed to create SQL storage: %v", err)
+	}
+	return storage
+}
+
+func TestSQLStorage_SaveAndGet(t *testing.T) {
+	storage := newTestSQLStorage(t)
+	ctx := context.Background()
+
+	expectedURL := model.URL{
+		ID:        "abc123",
+		ShortCode: "abc123",
+		Original:  "https://github.com/golang/go",
+		CreatedAt: time.Now(),
+	}
+
+	if err := storage.Save(ctx, expectedURL); err != nil {
+		t.Fatalf("Failed to save URL: %v", err)
+	}
+
+	// Saving the same ID again must fail
+	if err := storage.Save(ctx, expectedURL); err != ErrURLExists {
+		t.Errorf("Expected ErrURLExists but got: %v", err)
+	}
+
+	// Saving a different ID for the same normalized URL fails and keeps the existing entry
+	duplicate := model.URL{ID: "xyz789", ShortCode: "xyz789", Original: "https://github.com/golang/go/", CreatedAt: time.Now()}
+	if err := storage.Save(ctx, duplicate); err != ErrOriginalURLExists {
+		t.Errorf("Expected ErrOriginalURLExists but got: %v", err)
+	}
+	if _, err := storage.GetByID(ctx, "xyz789"); err != ErrURLNotFound {
+		t.Errorf("Expected duplicate original URL not to be stored but got: %v", err)
+	}
+
+	url, err := storage.GetByShortCode(ctx, "abc123")
+	if err != nil {
+		t.Fatalf("Failed to get URL by short code: %v", err)
+	}
+	if url.ID != expectedURL.ID || url.Original != expectedURL.Original || !url.CreatedAt.Equal(expectedURL.CreatedAt) {
+		t.Errorf("Expected URL %+v but got %+v", expectedURL, url)
+	}
+
+	url, err = storage.GetByOriginalURL(ctx, "", "https://github.com/golang/go/")
+	if err != nil {
+		t.Fatalf("Failed to get URL by original URL: %v", err)
+	}
+	if url.ShortCode != "abc123" {
+		t.Errorf("Expected short code abc123 but got %s", url.ShortCode)
+	}
+
+	if _, err := storage.GetByShortCode(ctx, "nonexistent"); err != ErrURLNotFound {
+		t.Errorf("Expected ErrURLNotFound but got: %v", err)
+	}
+}
+
+func TestSQLStorage_Delete(t *testing.T) {
+	storage := newTestSQLStorage(t)
+	ctx := context.Background()
+
+	url := model.URL{ID: "abc123", ShortCode: "abc123", Original: "https://github.com", CreatedAt: time.Now()}
+	if err := storage.Save(ctx, url); err != nil {
+		t.Fatalf("Failed to save URL: %v", err)
+	}
+
+	if err := storage.Delete(ctx, "abc123"); err != nil {
+		t.Errorf("Failed to delete URL: %v", err)
+	}
+	if _, err := storage.GetByID(ctx, "abc123"); err != ErrURLNotFound {
+		t.Errorf("URL was not deleted properly")
+	}
+	if err := storage.Delete(ctx, "abc123"); err != ErrURLNotFound {
+		t.Errorf("Expected ErrURLNotFound but got: %v", err)
+	}
+}
+
+func TestSQLStorage_DeleteExpired(t *testing.T) {
+	storage := newTestSQLStorage(t)
+	ctx := context.Background()
+	now := time.Now()
+
+	past := now.Add(-time.Minute)
+	future := now.Add(time.Hour)
+	urls := []model.URL{
+		{ID: "expired1", ShortCode: "expired1", Original: "https://github.com/a", CreatedAt: now, ExpiresAt: &past},
+		{ID: "active1", ShortCode: "active1", Original: "https://github.com/b", CreatedAt: now, ExpiresAt: &future},
+		{ID: "forever1", ShortCode: "forever1", Original: "https://github.com/c", CreatedAt: now},
+	}
+	for _, u := range urls {
+		if err := storage.Save(ctx, u); err != nil {
+			t.Fatalf("Failed to save URL: %v", err)
+		}
+	}
+
+	url, err := storage.GetByID(ctx, "active1")
+	if err != nil {
+		t.Fatalf("Failed to get URL: %v", err)
+	}
+	if url.ExpiresAt == nil || !url.ExpiresAt.Equal(future) {
+		t.Errorf("Expected expiry %v but got %v", future, url.ExpiresAt)
+	}
+
+	removed, err := storage.DeleteExpired(ctx, now, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to delete expired URLs: %v", err)
+	}
+	if removed != 1 {
+		t.Errorf("Expected 1 expired URL to be removed but got %d", removed)
+	}
+	if _, err := storage.GetByOriginalURL(ctx, "", "https://github.com/a"); err != ErrURLNotFound {
+		t.Errorf("Expected expired URL to be purged but got: %v", err)
+	}
+}
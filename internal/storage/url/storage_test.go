@@ -0,0 +1,545 @@
+package url
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gatij/goUrlShortener/internal/model"
+	"github.com/gatij/goUrlShortener/internal/storage/storagetest"
+)
+
+// storageFactories creates a fresh instance of every Storage implementation
+func storageFactories(t *testing.T) map[string]func() Storage {
+	return map[string]func() Storage{
+		"memory": func() Storage { return NewMemoryStorage() },
+		"file": func() Storage {
+			storage, err := NewFileStorage(t.TempDir(), 100)
+			if err != nil {
+				t.Fatalf("Failed to open file storage: %v", err)
+			}
+			t.Cleanup(func() { storage.Close() })
+			return storage
+		},
+		"sql":   func() Storage { return newTestSQLStorage(t) },
+		"redis": func() Storage { return NewRedisStorage(storagetest.Redis(t), "test") },
+	}
+}
+
+func TestStorage_GetOrCreate(t *testing.T) {
+	for name, newStorage := range storageFactories(t) {
+		t.Run(name, func(t *testing.T) {
+			storage := newStorage()
+			ctx := context.Background()
+			now := time.Now()
+
+			first := model.URL{ID: "abc123", ShortCode: "abc123", Original: "https://github.com/golang/go", CreatedAt: now}
+			stored, created, err := storage.GetOrCreate(ctx, first)
+			if err != nil || !created || stored.ShortCode != "abc123" {
+				t.Fatalf("Expected abc123 to be created but got %+v (created=%v, err=%v)", stored, created, err)
+			}
+
+			// Same normalized URL under another code returns the existing record
+			second := model.URL{ID: "def456", ShortCode: "def456", Original: "https://github.com/golang/go/", CreatedAt: now}
+			stored, created, err = storage.GetOrCreate(ctx, second)
+			if err != nil || created || stored.ShortCode != "abc123" {
+				t.Errorf("Expected existing abc123 to be returned but got %+v (created=%v, err=%v)", stored, created, err)
+			}
+			if _, err := storage.GetByID(ctx, "def456"); err != ErrURLNotFound {
+				t.Errorf("Expected def456 not to be stored but got: %v", err)
+			}
+
+			// Same code for a different URL is a clash
+			clash := model.URL{ID: "abc123", ShortCode: "abc123", Original: "https://go.dev", CreatedAt: now}
+			if _, _, err := storage.GetOrCreate(ctx, clash); err != ErrURLExists {
+				t.Errorf("Expected ErrURLExists but got: %v", err)
+			}
+
+			// Plain Save no longer silently drops duplicates
+			if err := storage.Save(ctx, second); err != ErrOriginalURLExists {
+				t.Errorf("Expected ErrOriginalURLExists but got: %v", err)
+			}
+		})
+	}
+}
+
+func TestStorage_GetOrCreatePerOwner(t *testing.T) {
+	for name, newStorage := range storageFactories(t) {
+		t.Run(name, func(t *testing.T) {
+			storage := newStorage()
+			ctx := context.Background()
+			now := time.Now()
+
+			alice := model.URL{ID: "abc123", ShortCode: "abc123", Original: "https://github.com/golang/go", CreatedAt: now, Owner: "alice"}
+			if _, created, err := storage.GetOrCreate(ctx, alice); err != nil || !created {
+				t.Fatalf("Expected abc123 to be created (created=%v, err=%v)", created, err)
+			}
+
+			// Another owner gets a link of their own for the same URL
+			bob := model.URL{ID: "def456", ShortCode: "def456", Original: "https://github.com/golang/go/", CreatedAt: now, Owner: "bob"}
+			stored, created, err := storage.GetOrCreate(ctx, bob)
+			if err != nil || !created || stored.ShortCode != "def456" {
+				t.Fatalf("Expected def456 to be created for bob but got %+v (created=%v, err=%v)", stored, created, err)
+			}
+			if url, err := storage.GetByOriginalURL(ctx, "alice", "https://github.com/golang/go"); err != nil || url.ShortCode != "abc123" {
+				t.Errorf("Expected alice's URL to stay abc123 but got %+v (err=%v)", url, err)
+			}
+			if _, err := storage.GetByOriginalURL(ctx, "", "https://github.com/golang/go"); err != ErrURLNotFound {
+				t.Errorf("Expected no unowned URL but got: %v", err)
+			}
+
+			// Retargeting one owner's link leaves the other's alone
+			alice.Original = "https://go.dev"
+			if err := storage.Update(ctx, alice); err != nil {
+				t.Fatalf("Failed to update URL: %v", err)
+			}
+			if url, err := storage.GetByOriginalURL(ctx, "bob", "https://github.com/golang/go"); err != nil || url.ShortCode != "def456" {
+				t.Errorf("Expected bob's URL to stay def456 but got %+v (err=%v)", url, err)
+			}
+		})
+	}
+}
+
+func TestStorage_GetOrCreateReplacesExpired(t *testing.T) {
+	for name, newStorage := range storageFactories(t) {
+		t.Run(name, func(t *testing.T) {
+			storage := newStorage()
+			ctx := context.Background()
+			now := time.Now()
+
+			past := now.Add(-time.Minute)
+			expired := model.URL{ID: "old123", ShortCode: "old123", Original: "https://github.com", CreatedAt: past.Add(-time.Hour), ExpiresAt: &past}
+			if err := storage.Save(ctx, expired); err != nil {
+				t.Fatalf("Failed to save URL: %v", err)
+			}
+
+			fresh := model.URL{ID: "new456", ShortCode: "new456", Original: "https://github.com", CreatedAt: now}
+			stored, created, err := storage.GetOrCreate(ctx, fresh)
+			if err != nil || !created || stored.ShortCode != "new456" {
+				t.Fatalf("Expected new456 to replace expired URL but got %+v (created=%v, err=%v)", stored, created, err)
+			}
+			if _, err := storage.GetByShortCode(ctx, "old123"); err != ErrURLNotFound {
+				t.Errorf("Expected expired URL to be removed but got: %v", err)
+			}
+			if url, err := storage.GetByOriginalURL(ctx, "", "https://github.com"); err != nil || url.ShortCode != "new456" {
+				t.Errorf("Expected original URL to map to new456 but got %+v (err=%v)", url, err)
+			}
+		})
+	}
+}
+
+func TestStorage_GetOrCreateConcurrent(t *testing.T) {
+	for name, newStorage := range storageFactories(t) {
+		t.Run(name, func(t *testing.T) {
+			storage := newStorage()
+			ctx := context.Background()
+
+			const workers = 10
+			var (
+				wg      sync.WaitGroup
+				mu      sync.Mutex
+				codes   = make(map[string]bool)
+				created int
+			)
+			for i := 0; i < workers; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					code := fmt.Sprintf("code%02d", i)
+					url := model.URL{ID: code, ShortCode: code, Original: "https://github.com/race", CreatedAt: time.Now()}
+					stored, wasCreated, err := storage.GetOrCreate(ctx, url)
+					if err != nil {
+						t.Errorf("GetOrCreate failed: %v", err)
+						return
+					}
+					mu.Lock()
+					defer mu.Unlock()
+					codes[stored.ShortCode] = true
+					if wasCreated {
+						created++
+					}
+				}(i)
+			}
+			wg.Wait()
+
+			if len(codes) != 1 || created != 1 {
+				t.Fatalf("Expected every caller to get one stored code but got codes=%v created=%d", codes, created)
+			}
+			for code := range codes {
+				if _, err := storage.GetByShortCode(ctx, code); err != nil {
+					t.Errorf("Expected returned code %s to be stored but got: %v", code, err)
+				}
+			}
+		})
+	}
+}
+
+func TestStorage_ConsumeClick(t *testing.T) {
+	for name, newStorage := range storageFactories(t) {
+		t.Run(name, func(t *testing.T) {
+			storage := newStorage()
+			ctx := context.Background()
+			now := time.Now()
+
+			limited := model.URL{ID: "once12", ShortCode: "once12", Original: "https://github.com/download", CreatedAt: now, MaxClicks: 2}
+			if err := storage.Save(ctx, limited); err != nil {
+				t.Fatalf("Failed to save URL: %v", err)
+			}
+			for want := 1; want <= 2; want++ {
+				url, err := storage.ConsumeClick(ctx, "once12")
+				if err != nil || url.Clicks != want || url.MaxClicks != 2 {
+					t.Fatalf("Click %d: expected the count to be %d but got %+v (err=%v)", want, want, url, err)
+				}
+			}
+			if _, err := storage.ConsumeClick(ctx, "once12"); err != ErrClicksExhausted {
+				t.Errorf("Expected ErrClicksExhausted but got: %v", err)
+			}
+			if _, err := storage.ConsumeClick(ctx, "none00"); err != ErrURLNotFound {
+				t.Errorf("Expected ErrURLNotFound but got: %v", err)
+			}
+
+			// Retargeting keeps the clicks already spent
+			limited.Original = "https://github.com/download/v2"
+			if err := storage.Update(ctx, limited); err != nil {
+				t.Fatalf("Failed to update URL: %v", err)
+			}
+			if url, err := storage.GetByID(ctx, "once12"); err != nil || url.Clicks != 2 {
+				t.Errorf("Expected the update to keep 2 clicks but got %+v (err=%v)", url, err)
+			}
+
+			// URLs without a limit are counted but never run out
+			open := model.URL{ID: "open12", ShortCode: "open12", Original: "https://github.com", CreatedAt: now}
+			if err := storage.Save(ctx, open); err != nil {
+				t.Fatalf("Failed to save URL: %v", err)
+			}
+			for i := 0; i < 3; i++ {
+				if _, err := storage.ConsumeClick(ctx, "open12"); err != nil {
+					t.Fatalf("Expected unlimited URL to be clicked but got: %v", err)
+				}
+			}
+
+			// A used up URL is replaced like an expired one
+			fresh := model.URL{ID: "new456", ShortCode: "new456", Original: "https://github.com/download/v2", CreatedAt: now}
+			stored, created, err := storage.GetOrCreate(ctx, fresh)
+			if err != nil || !created || stored.ShortCode != "new456" {
+				t.Fatalf("Expected new456 to replace used up URL but got %+v (created=%v, err=%v)", stored, created, err)
+			}
+			if _, err := storage.GetByShortCode(ctx, "once12"); err != ErrURLNotFound {
+				t.Errorf("Expected used up URL to be removed but got: %v", err)
+			}
+		})
+	}
+}
+
+func TestStorage_ConsumeClickConcurrent(t *testing.T) {
+	for name, newStorage := range storageFactories(t) {
+		t.Run(name, func(t *testing.T) {
+			storage := newStorage()
+			ctx := context.Background()
+
+			const maxClicks, workers = 5, 20
+			url := model.URL{ID: "race12", ShortCode: "race12", Original: "https://github.com/race", CreatedAt: time.Now(), MaxClicks: maxClicks}
+			if err := storage.Save(ctx, url); err != nil {
+				t.Fatalf("Failed to save URL: %v", err)
+			}
+
+			var (
+				wg      sync.WaitGroup
+				mu      sync.Mutex
+				granted int
+			)
+			for i := 0; i < workers; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					_, err := storage.ConsumeClick(ctx, "race12")
+					if err != nil && err != ErrClicksExhausted {
+						t.Errorf("ConsumeClick failed: %v", err)
+						return
+					}
+					if err == nil {
+						mu.Lock()
+						granted++
+						mu.Unlock()
+					}
+				}()
+			}
+			wg.Wait()
+
+			if granted != maxClicks {
+				t.Errorf("Expected exactly %d clicks to be granted but got %d", maxClicks, granted)
+			}
+			if stored, err := storage.GetByID(ctx, "race12"); err != nil || stored.Clicks != maxClicks {
+				t.Errorf("Expected %d clicks to be stored but got %+v (err=%v)", maxClicks, stored, err)
+			}
+		})
+	}
+}
+
+func TestStorage_Count(t *testing.T) {
+	for name, newStorage := range storageFactories(t) {
+		t.Run(name, func(t *testing.T) {
+			storage := newStorage()
+			ctx := context.Background()
+
+			for i := 0; i < 3; i++ {
+				code := fmt.Sprintf("code%02d", i)
+				url := model.URL{ID: code, ShortCode: code, Original: "https://github.com/" + code, CreatedAt: time.Now()}
+				if err := storage.Save(ctx, url); err != nil {
+					t.Fatalf("Failed to save URL: %v", err)
+				}
+			}
+			if err := storage.Delete(ctx, "code01"); err != nil {
+				t.Fatalf("Failed to delete URL: %v", err)
+			}
+
+			count, err := storage.Count(ctx)
+			if err != nil {
+				t.Fatalf("Failed to count URLs: %v", err)
+			}
+			if count != 2 {
+				t.Errorf("Expected 2 URLs but got %d", count)
+			}
+		})
+	}
+}
+
+func TestStorage_DeleteExpiredKeepsFallbacks(t *testing.T) {
+	for name, newStorage := range storageFactories(t) {
+		t.Run(name, func(t *testing.T) {
+			storage := newStorage()
+			ctx := context.Background()
+			now := time.Now()
+			expiredAt := now.Add(-time.Minute)
+			longExpiredAt := now.Add(-2 * time.Hour)
+
+			plain := model.URL{ID: "plain1", ShortCode: "plain1", Original: "https://github.com", CreatedAt: now.Add(-time.Hour), ExpiresAt: &expiredAt}
+			withFallback := model.URL{ID: "fallb1", ShortCode: "fallb1", Original: "https://go.dev/dl/go.tar.gz", CreatedAt: now.Add(-time.Hour), ExpiresAt: &expiredAt, FallbackURL: "https://go.dev/dl/"}
+			pastRetention := model.URL{ID: "fallb2", ShortCode: "fallb2", Original: "https://go.dev/blog", CreatedAt: now.Add(-3 * time.Hour), ExpiresAt: &longExpiredAt, FallbackURL: "https://go.dev/"}
+			for _, url := range []model.URL{plain, withFallback, pastRetention} {
+				if err := storage.Save(ctx, url); err != nil {
+					t.Fatalf("Failed to save URL: %v", err)
+				}
+			}
+
+			removed, err := storage.DeleteExpired(ctx, now, time.Hour)
+			if err != nil {
+				t.Fatalf("Failed to delete expired URLs: %v", err)
+			}
+			if removed != 2 {
+				t.Errorf("Expected 2 URLs to be removed but got %d", removed)
+			}
+			if _, err := storage.GetByShortCode(ctx, "plain1"); err != ErrURLNotFound {
+				t.Errorf("Expected the plain URL to be removed but got: %v", err)
+			}
+			if _, err := storage.GetByShortCode(ctx, "fallb2"); err != ErrURLNotFound {
+				t.Errorf("Expected the URL expired for longer than the retention to be removed but got: %v", err)
+			}
+			kept, err := storage.GetByShortCode(ctx, "fallb1")
+			if err != nil || kept.FallbackURL != "https://go.dev/dl/" {
+				t.Errorf("Expected the URL with a fallback to be kept but got %+v, %v", kept, err)
+			}
+
+			// Shortening its original URL again doesn't replace it either
+			url := model.URL{ID: "newone", ShortCode: "newone", Original: withFallback.Original, CreatedAt: now}
+			stored, created, err := storage.GetOrCreate(ctx, url)
+			if err != nil || created || stored.ID != "fallb1" {
+				t.Errorf("Expected the URL with a fallback to be returned but got %+v, %v, %v", stored, created, err)
+			}
+		})
+	}
+}
+
+func TestStorage_Update(t *testing.T) {
+	for name, newStorage := range storageFactories(t) {
+		t.Run(name, func(t *testing.T) {
+			storage := newStorage()
+			ctx := context.Background()
+			now := time.Now()
+
+			first := model.URL{ID: "abc123", ShortCode: "abc123", Original: "https://github.com/golang/go", CreatedAt: now}
+			second := model.URL{ID: "def456", ShortCode: "def456", Original: "https://go.dev", CreatedAt: now}
+			for _, url := range []model.URL{first, second} {
+				if err := storage.Save(ctx, url); err != nil {
+					t.Fatalf("Failed to save URL: %v", err)
+				}
+			}
+
+			// Retarget the first URL and give it an expiry and activation window
+			expiresAt := now.Add(time.Hour)
+			notAfter := now.Add(30 * time.Minute)
+			updated := first
+			updated.Original = "https://pkg.go.dev"
+			updated.ExpiresAt = &expiresAt
+			updated.NotBefore = &now
+			updated.NotAfter = &notAfter
+			if err := storage.Update(ctx, updated); err != nil {
+				t.Fatalf("Failed to update URL: %v", err)
+			}
+
+			got, err := storage.GetByShortCode(ctx, "abc123")
+			if err != nil {
+				t.Fatalf("Failed to get updated URL: %v", err)
+			}
+			if got.Original != "https://pkg.go.dev" || got.ExpiresAt == nil {
+				t.Errorf("Expected updated URL with expiry but got %+v", got)
+			}
+			if got.NotBefore == nil || !got.NotBefore.Equal(now) || got.NotAfter == nil || !got.NotAfter.Equal(notAfter) {
+				t.Errorf("Expected updated URL with activation window but got %+v", got)
+			}
+
+			// The normalized URL index follows the new destination
+			if _, err := storage.GetByOriginalURL(ctx, "", "https://github.com/golang/go"); err != ErrURLNotFound {
+				t.Errorf("Expected old original URL to be unindexed but got: %v", err)
+			}
+			if byOriginal, err := storage.GetByOriginalURL(ctx, "", "https://pkg.go.dev/"); err != nil || byOriginal.ID != "abc123" {
+				t.Errorf("Expected new original URL to find abc123 but got %+v (err=%v)", byOriginal, err)
+			}
+
+			// The old destination is free again
+			reuse := model.URL{ID: "ghi789", ShortCode: "ghi789", Original: "https://github.com/golang/go", CreatedAt: now}
+			if err := storage.Save(ctx, reuse); err != nil {
+				t.Errorf("Expected old original URL to be reusable but got: %v", err)
+			}
+
+			// Another link's destination can't be taken
+			clash := updated
+			clash.Original = "https://go.dev/"
+			if err := storage.Update(ctx, clash); err != ErrOriginalURLExists {
+				t.Errorf("Expected ErrOriginalURLExists but got: %v", err)
+			}
+
+			// Updating to the same destination is allowed
+			if err := storage.Update(ctx, updated); err != nil {
+				t.Errorf("Expected no-op update to succeed but got: %v", err)
+			}
+
+			missing := model.URL{ID: "nothere", ShortCode: "nothere", Original: "https://example.org", CreatedAt: now}
+			if err := storage.Update(ctx, missing); err != ErrURLNotFound {
+				t.Errorf("Expected ErrURLNotFound but got: %v", err)
+			}
+		})
+	}
+}
+
+// listAll follows NextCursor until the last page and returns the IDs in order
+func listAll(t *testing.T, storage Storage, opts ListOptions) []string {
+	t.Helper()
+
+	var ids []string
+	for pages := 0; ; pages++ {
+		if pages > 10 {
+			t.Fatalf("Pagination did not terminate, got %v so far", ids)
+		}
+		result, err := storage.List(context.Background(), opts)
+		if err != nil {
+			t.Fatalf("Failed to list URLs: %v", err)
+		}
+		for _, url := range result.URLs {
+			ids = append(ids, url.ID)
+		}
+		if result.NextCursor == "" {
+			return ids
+		}
+		opts.Cursor = result.NextCursor
+	}
+}
+
+func TestStorage_List(t *testing.T) {
+	base := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	urls := []model.URL{
+		{ID: "code01", ShortCode: "code01", Original: "https://github.com/golang/go", CreatedAt: base, Owner: "key1"},
+		{ID: "code02", ShortCode: "code02", Original: "https://go.dev/doc", CreatedAt: base.Add(time.Minute)},
+		// Same creation time as code02, the ID breaks the tie
+		{ID: "code03", ShortCode: "code03", Original: "https://github.com/golang/tools", CreatedAt: base.Add(time.Minute)},
+		{ID: "code04", ShortCode: "code04", Original: "https://GitHub.com/kubernetes/kubernetes", CreatedAt: base.Add(2 * time.Minute), Owner: "key1", RedirectStatus: 307, Preview: true, PasswordHash: "hash", FallbackURL: "https://example.com/gone"},
+		{ID: "code05", ShortCode: "code05", Original: "https://pkg.go.dev/net/http", CreatedAt: base.Add(3 * time.Minute)},
+	}
+
+	for name, newStorage := range storageFactories(t) {
+		t.Run(name, func(t *testing.T) {
+			storage := newStorage()
+			ctx := context.Background()
+
+			// Save in a shuffled order so insertion order can't leak into the results
+			for _, i := range []int{3, 0, 4, 2, 1} {
+				if err := storage.Save(ctx, urls[i]); err != nil {
+					t.Fatalf("Failed to save URL: %v", err)
+				}
+			}
+
+			if got, err := storage.GetByID(ctx, "code04"); err != nil || got.Owner != "key1" || got.RedirectStatus != 307 || !got.Preview || got.PasswordHash != "hash" || got.FallbackURL != "https://example.com/gone" {
+				t.Errorf("Expected owner key1, redirect status 307, preview, password hash and fallback URL to be stored but got %+v (err: %v)", got, err)
+			}
+
+			tests := []struct {
+				name string
+				opts ListOptions
+				want []string
+			}{
+				{"oldest first", ListOptions{Limit: 2}, []string{"code01", "code02", "code03", "code04", "code05"}},
+				{"newest first", ListOptions{Limit: 2, Descending: true}, []string{"code05", "code04", "code03", "code02", "code01"}},
+				{"by domain", ListOptions{Limit: 1, Domain: "github.com"}, []string{"code01", "code03", "code04"}},
+				{"by owner", ListOptions{Limit: 1, Owner: "key1"}, []string{"code01", "code04"}},
+				{"by substring", ListOptions{Limit: 10, Contains: "GOLANG"}, []string{"code01", "code03"}},
+				{"by creation range", ListOptions{Limit: 1, CreatedFrom: base.Add(time.Minute), CreatedBefore: base.Add(3 * time.Minute)}, []string{"code02", "code03", "code04"}},
+				{"range newest first", ListOptions{Limit: 2, Descending: true, CreatedFrom: base.Add(time.Minute), CreatedBefore: base.Add(3 * time.Minute)}, []string{"code04", "code03", "code02"}},
+				{"no matches", ListOptions{Limit: 5, Domain: "example.org"}, nil},
+			}
+
+			for _, tt := range tests {
+				got := listAll(t, storage, tt.opts)
+				if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+					t.Errorf("%s: expected %v but got %v", tt.name, tt.want, got)
+				}
+			}
+
+			// A full last page has no cursor
+			result, err := storage.List(ctx, ListOptions{Limit: 5})
+			if err != nil {
+				t.Fatalf("Failed to list URLs: %v", err)
+			}
+			if len(result.URLs) != 5 || result.NextCursor != "" {
+				t.Errorf("Expected 5 URLs and no cursor but got %d and %q", len(result.URLs), result.NextCursor)
+			}
+
+			if _, err := storage.List(ctx, ListOptions{Limit: 5, Cursor: "not-a-cursor"}); err != ErrInvalidCursor {
+				t.Errorf("Expected ErrInvalidCursor but got: %v", err)
+			}
+		})
+	}
+}
+
+func TestStorage_ListAfterDeleteAndUpdate(t *testing.T) {
+	for name, newStorage := range storageFactories(t) {
+		t.Run(name, func(t *testing.T) {
+			storage := newStorage()
+			ctx := context.Background()
+			base := time.Now()
+
+			for i := 0; i < 3; i++ {
+				code := fmt.Sprintf("code%02d", i)
+				url := model.URL{ID: code, ShortCode: code, Original: "https://github.com/" + code, CreatedAt: base.Add(time.Duration(i) * time.Second)}
+				if err := storage.Save(ctx, url); err != nil {
+					t.Fatalf("Failed to save URL: %v", err)
+				}
+			}
+			if err := storage.Delete(ctx, "code01"); err != nil {
+				t.Fatalf("Failed to delete URL: %v", err)
+			}
+			retargeted := model.URL{ID: "code02", ShortCode: "code02", Original: "https://go.dev", CreatedAt: base.Add(2 * time.Second)}
+			if err := storage.Update(ctx, retargeted); err != nil {
+				t.Fatalf("Failed to update URL: %v", err)
+			}
+
+			if got := listAll(t, storage, ListOptions{Limit: 10}); fmt.Sprint(got) != "[code00 code02]" {
+				t.Errorf("Expected [code00 code02] but got %v", got)
+			}
+			if got := listAll(t, storage, ListOptions{Limit: 10, Domain: "go.dev"}); fmt.Sprint(got) != "[code02]" {
+				t.Errorf("Expected the updated domain to be filtered on but got %v", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package telemetry
+
+import (
+    "context"
+    "time"
+
+    "github.com/gatij/goUrlShortener/internal/model"
+    "github.com/gatij/goUrlShortener/internal/storage/metrics"
+    "github.com/gatij/goUrlShortener/internal/storage/url"
+)
+
+// urlStorage records the latency of the operations of a url.Storage
+type urlStorage struct {
+    next url.Storage
+    t    *Telemetry
+}
+
+// InstrumentURLStorage wraps store so the latency of its operations is recorded
+func InstrumentURLStorage(store url.Storage, t *Telemetry) url.Storage {
+    return &urlStorage{next: store, t: t}
+}
+
+func (s *urlStorage) Save(ctx context.Context, u model.URL) error {
+    start := time.Now()
+    err := s.next.Save(ctx, u)
+    s.t.observeStorage("url", "save", start, err)
+    return err
+}
+
+func (s *urlStorage) GetOrCreate(ctx context.Context, u model.URL) (model.URL, bool, error) {
+    start := time.Now()
+    stored, created, err := s.next.GetOrCreate(ctx, u)
+    s.t.observeStorage("url", "get_or_create", start, err)
+    return stored, created, err
+}
+
+func (s *urlStorage) GetByID(ctx context.Context, id string) (model.URL, error) {
+    start := time.Now()
+    u, err := s.next.GetByID(ctx, id)
+    s.t.observeStorage("url", "get_by_id", start, err)
+    return u, err
+}
+
+func (s *urlStorage) GetByShortCode(ctx context.Context, shortCode string) (model.URL, error) {
+    start := time.Now()
+    u, err := s.next.GetByShortCode(ctx, shortCode)
+    s.t.observeStorage("url", "get_by_short_code", start, err)
+    return u, err
+}
+
+func (s *urlStorage) GetByOriginalURL(ctx context.Context, owner, originalURL string) (model.URL, error) {
+    start := time.Now()
+    u, err := s.next.GetByOriginalURL(ctx, owner, originalURL)
+    s.t.observeStorage("url", "get_by_original_url", start, err)
+    return u, err
+}
+
+func (s *urlStorage) Update(ctx context.Context, u model.URL) error {
+    start := time.Now()
+    err := s.next.Update(ctx, u)
+    s.t.observeStorage("url", "update", start, err)
+    return err
+}
+
+func (s *urlStorage) ConsumeClick(ctx context.Context, id string) (model.URL, error) {
+    start := time.Now()
+    u, err := s.next.ConsumeClick(ctx, id)
+    s.t.observeStorage("url", "consume_click", start, err)
+    return u, err
+}
+
+func (s *urlStorage) Delete(ctx context.Context, id string) error {
+    start := time.Now()
+    err := s.next.Delete(ctx, id)
+    s.t.observeStorage("url", "delete", start, err)
+    return err
+}
+
+func (s *urlStorage) List(ctx context.Context, opts url.ListOptions) (url.ListResult, error) {
+    start := time.Now()
+    result, err := s.next.List(ctx, opts)
+    s.t.observeStorage("url", "list", start, err)
+    return result, err
+}
+
+func (s *urlStorage) Count(ctx context.Context) (int, error) {
+    start := time.Now()
+    count, err := s.next.Count(ctx)
+    s.t.observeStorage("url", "count", start, err)
+    return count, err
+}
+
+func (s *urlStorage) DeleteExpired(ctx context.Context, now time.Time, fallbackRetention time.Duration) (int, error) {
+    start := time.Now()
+    removed, err := s.next.DeleteExpired(ctx, now, fallbackRetention)
+    s.t.observeStorage("url", "delete_expired", start, err)
+    return removed, err
+}
+
+// metricsStorage records the latency of the operations of a metrics.Storage
+type metricsStorage struct {
+    next metrics.Storage
+    t    *Telemetry
+}
+
+// InstrumentMetricsStorage wraps store so the latency of its operations is recorded
+func InstrumentMetricsStorage(store metrics.Storage, t *Telemetry) metrics.Storage {
+    return &metricsStorage{next: store, t: t}
+}
+
+func (s *metricsStorage) SaveDomainMetrics(ctx context.Context, m model.DomainMetrics) error {
+    start := time.Now()
+    err := s.next.SaveDomainMetrics(ctx, m)
+    s.t.observeStorage("metrics", "save_domain_metrics", start, err)
+    return err
+}
+
+func (s *metricsStorage) GetTopDomains(ctx context.Context, limit int) ([]model.DomainMetrics, error) {
+    start := time.Now()
+    domains, err := s.next.GetTopDomains(ctx, limit)
+    s.t.observeStorage("metrics", "get_top_domains", start, err)
+    return domains, err
+}
+
+func (s *metricsStorage) GetDomainMetrics(ctx context.Context, domain string) (model.DomainMetrics, bool, error) {
+    start := time.Now()
+    m, found, err := s.next.GetDomainMetrics(ctx, domain)
+    s.t.observeStorage("metrics", "get_domain_metrics", start, err)
+    return m, found, err
+}
+
+func (s *metricsStorage) IncrementDomainShortenCount(ctx context.Context, domain string) error {
+    start := time.Now()
+    err := s.next.IncrementDomainShortenCount(ctx, domain)
+    s.t.observeStorage("metrics", "increment_domain_shorten_count", start, err)
+    return err
+}
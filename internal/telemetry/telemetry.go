@@ -0,0 +1,181 @@
+package telemetry
+
+import (
+    "context"
+    "log/slog"
+    "net/http"
+    "strconv"
+    "time"
+
+    "github.com/prometheus/client_golang/prometheus"
+    "github.com/prometheus/client_golang/prometheus/collectors"
+    "github.com/prometheus/client_golang/prometheus/promhttp"
+    "github.com/gatij/goUrlShortener/internal/service"
+    "github.com/gatij/goUrlShortener/internal/storage/url"
+)
+
+// namespace prefixes every metric name
+const namespace = "urlshortener"
+
+// countTimeout bounds the storage call made for the link count on each scrape
+const countTimeout = 5 * time.Second
+
+// errorTypes names the shortener errors worth telling apart. Anything else
+// is counted as "internal".
+var errorTypes = map[error]string{
+    url.ErrURLNotFound:               "not_found",
+    service.ErrInvalidURL:            "invalid_url",
+    service.ErrInvalidExpiry:         "invalid_expiry",
+    service.ErrURLExpired:            "expired",
+    service.ErrInvalidAlias:          "invalid_alias",
+    service.ErrAliasReserved:         "alias_reserved",
+    service.ErrAliasTaken:            "alias_taken",
+    service.ErrURLAlreadyShortened:   "already_shortened",
+    service.ErrShortCodeUnavailable:  "code_unavailable",
+    service.ErrDomainBlocked:         "domain_blocked",
+    service.ErrSelfLink:              "self_link",
+    service.ErrShortenerChain:        "shortener_chain",
+    service.ErrResolveFailed:         "resolve_failed",
+    service.ErrForbidden:             "forbidden",
+    service.ErrInvalidRedirectStatus: "invalid_redirect_status",
+    service.ErrInvalidPassword:       "invalid_password",
+    service.ErrInvalidMaxClicks:      "invalid_max_clicks",
+    service.ErrClickLimitReached:     "click_limit_reached",
+    service.ErrInvalidSchedule:       "invalid_schedule",
+    service.ErrLinkNotYetActive:      "not_yet_active",
+    service.ErrLinkClosed:            "link_closed",
+    service.ErrInvalidFallbackURL:    "invalid_fallback_url",
+}
+
+// expectedStorageErrors are storage errors that are part of normal operation
+var expectedStorageErrors = map[error]bool{
+    url.ErrURLNotFound:       true,
+    url.ErrURLExists:         true,
+    url.ErrOriginalURLExists: true,
+    url.ErrInvalidCursor:     true,
+    url.ErrClicksExhausted:   true,
+}
+
+// Telemetry collects operational metrics and serves them to Prometheus. It
+// uses its own registry, so several instances can coexist in tests.
+type Telemetry struct {
+    registry *prometheus.Registry
+
+    requests        *prometheus.CounterVec
+    requestDuration *prometheus.HistogramVec
+    redirects       prometheus.Counter
+    linksCreated    prometheus.Counter
+    errors          *prometheus.CounterVec
+    storageDuration *prometheus.HistogramVec
+}
+
+// New creates the metrics, along with the usual Go runtime and process metrics
+func New() *Telemetry {
+    t := &Telemetry{
+        registry: prometheus.NewRegistry(),
+        requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+            Namespace: namespace,
+            Name:      "http_requests_total",
+            Help:      "HTTP requests by route, method and status code.",
+        }, []string{"route", "method", "status"}),
+        requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+            Namespace: namespace,
+            Name:      "http_request_duration_seconds",
+            Help:      "Time taken to answer HTTP requests, by route and method.",
+            Buckets:   prometheus.DefBuckets,
+        }, []string{"route", "method"}),
+        redirects: prometheus.NewCounter(prometheus.CounterOpts{
+            Namespace: namespace,
+            Name:      "redirects_total",
+            Help:      "Short URLs resolved to their destination for a redirect.",
+        }),
+        linksCreated: prometheus.NewCounter(prometheus.CounterOpts{
+            Namespace: namespace,
+            Name:      "links_created_total",
+            Help:      "Short URLs created, not counting URLs that were already shortened.",
+        }),
+        errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+            Namespace: namespace,
+            Name:      "shortener_errors_total",
+            Help:      "Failed shortener operations by operation and error type.",
+        }, []string{"operation", "type"}),
+        storageDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+            Namespace: namespace,
+            Name:      "storage_operation_duration_seconds",
+            Help:      "Time taken by storage operations, by store, operation and outcome.",
+            Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+        }, []string{"store", "operation", "result"}),
+    }
+
+    t.registry.MustRegister(
+        collectors.NewGoCollector(),
+        collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+        t.requests,
+        t.requestDuration,
+        t.redirects,
+        t.linksCreated,
+        t.errors,
+        t.storageDuration,
+    )
+    return t
+}
+
+// Handler serves the metrics in the Prometheus exposition format
+func (t *Telemetry) Handler() http.Handler {
+    return promhttp.HandlerFor(t.registry, promhttp.HandlerOpts{Registry: t.registry})
+}
+
+// RegisterLinkCount exposes the number of stored links, counted in store
+// whenever the metrics are scraped
+func (t *Telemetry) RegisterLinkCount(store url.Storage) {
+    t.registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+        Namespace: namespace,
+        Name:      "links",
+        Help:      "Short URLs currently stored, including expired ones not yet purged.",
+    }, func() float64 {
+        ctx, cancel := context.WithTimeout(context.Background(), countTimeout)
+        defer cancel()
+
+        count, err := store.Count(ctx)
+        if err != nil {
+            slog.Error("Failed to count links for metrics", "error", err)
+            return 0
+        }
+        return float64(count)
+    }))
+}
+
+// ObserveRequest records an HTTP request answered with status after duration
+func (t *Telemetry) ObserveRequest(route, method string, status int, duration time.Duration) {
+    t.requests.WithLabelValues(route, method, strconv.Itoa(status)).Inc()
+    t.requestDuration.WithLabelValues(route, method).Observe(duration.Seconds())
+}
+
+// LinkCreated counts a new short URL
+func (t *Telemetry) LinkCreated() {
+    t.linksCreated.Inc()
+}
+
+// RedirectServed counts a redirect to a short URL's destination
+func (t *Telemetry) RedirectServed() {
+    t.redirects.Inc()
+}
+
+// ShortenerError counts a shortener operation that failed with err
+func (t *Telemetry) ShortenerError(operation string, err error) {
+    errorType, known := errorTypes[err]
+    if !known {
+        errorType = "internal"
+    }
+    t.errors.WithLabelValues(operation, errorType).Inc()
+}
+
+// observeStorage records a storage operation that started at start. Errors
+// the callers expect, such as lookups of missing records, count as successful.
+func (t *Telemetry) observeStorage(store, operation string, start time.Time, err error) {
+    result := "ok"
+    if err != nil && !expectedStorageErrors[err] {
+        result = "error"
+    }
+    t.storageDuration.WithLabelValues(store, operation, result).Observe(time.Since(start).Seconds())
+}
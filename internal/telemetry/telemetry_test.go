@@ -0,0 +1,125 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gatij/goUrlShortener/internal/model"
+	"github.com/gatij/goUrlShortener/internal/service"
+	"github.com/gatij/goUrlShortener/internal/storage/metrics"
+	"github.com/gatij/goUrlShortener/internal/storage/url"
+)
+
+func TestInstrumentURLStorage(t *testing.T) {
+	tel := New()
+	store := InstrumentURLStorage(url.NewMemoryStorage(), tel)
+	ctx := context.Background()
+
+	if err := store.Save(ctx, model.URL{ID: "abc123", ShortCode: "abc123", Original: "https://go.dev"}); err != nil {
+		t.Fatalf("Failed to save URL: %v", err)
+	}
+	if _, err := store.GetByShortCode(ctx, "abc123"); err != nil {
+		t.Fatalf("Failed to get URL: %v", err)
+	}
+	if _, err := store.GetByShortCode(ctx, "nothere"); err != url.ErrURLNotFound {
+		t.Fatalf("Expected ErrURLNotFound to pass through but got: %v", err)
+	}
+
+	tests := []struct {
+		operation string
+		result    string
+		expected  int
+	}{
+		{"save", "ok", 1},
+		{"get_by_short_code", "ok", 2}, // Missing records aren't failures
+		{"get_by_short_code", "error", 0},
+	}
+	for _, tt := range tests {
+		if got := sampleCount(t, tel, "url", tt.operation, tt.result); got != tt.expected {
+			t.Errorf("%s/%s: expected %d observations but got %d", tt.operation, tt.result, tt.expected, got)
+		}
+	}
+}
+
+func TestInstrumentMetricsStorage(t *testing.T) {
+	tel := New()
+	store := InstrumentMetricsStorage(metrics.NewMemoryStorage(), tel)
+	ctx := context.Background()
+
+	store.IncrementDomainShortenCount(ctx, "go.dev")
+	if top, err := store.GetTopDomains(ctx, 3); err != nil || len(top) != 1 {
+		t.Fatalf("Expected one domain but got %v (err=%v)", top, err)
+	}
+
+	if got := sampleCount(t, tel, "metrics", "increment_domain_shorten_count", "ok"); got != 1 {
+		t.Errorf("Expected 1 observation but got %d", got)
+	}
+	if got := sampleCount(t, tel, "metrics", "get_top_domains", "ok"); got != 1 {
+		t.Errorf("Expected 1 observation but got %d", got)
+	}
+}
+
+func TestTelemetry_Handler(t *testing.T) {
+	tel := New()
+	store := url.NewMemoryStorage()
+	store.Save(context.Background(), model.URL{ID: "abc123", ShortCode: "abc123", Original: "https://go.dev"})
+	tel.RegisterLinkCount(store)
+
+	tel.LinkCreated()
+	tel.RedirectServed()
+	tel.RedirectServed()
+	tel.ShortenerError("create", service.ErrAliasTaken)
+	tel.ShortenerError("redirect", errors.New("connection refused"))
+	tel.ObserveRequest("/:shortCode", "GET", 301, 2*time.Millisecond)
+
+	resp := httptest.NewRecorder()
+	tel.Handler().ServeHTTP(resp, httptest.NewRequest("GET", "/metrics", nil))
+	if resp.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d but got %d", http.StatusOK, resp.Code)
+	}
+
+	body := resp.Body.String()
+	for _, expected := range []string{
+		"urlshortener_links 1",
+		"urlshortener_links_created_total 1",
+		"urlshortener_redirects_total 2",
+		`urlshortener_shortener_errors_total{operation="create",type="alias_taken"} 1`,
+		`urlshortener_shortener_errors_total{operation="redirect",type="internal"} 1`,
+		`urlshortener_http_requests_total{method="GET",route="/:shortCode",status="301"} 1`,
+		`urlshortener_http_request_duration_seconds_count{method="GET",route="/:shortCode"} 1`,
+		"go_goroutines",
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("Expected metrics to contain %q", expected)
+		}
+	}
+}
+
+// sampleCount returns how many storage operations were observed with the labels
+func sampleCount(t *testing.T, tel *Telemetry, store, operation, result string) int {
+	t.Helper()
+	families, err := tel.registry.Gather()
+	if err != nil {
+		t.Fatalf("Failed to gather metrics: %v", err)
+	}
+	for _, family := range families {
+		if family.GetName() != "urlshortener_storage_operation_duration_seconds" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			labels := make(map[string]string)
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			if labels["store"] == store && labels["operation"] == operation && labels["result"] == result {
+				return int(metric.GetHistogram().GetSampleCount())
+			}
+		}
+	}
+	return 0
+}
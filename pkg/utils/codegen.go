@@ -0,0 +1,277 @@
+package utils
+
+import (
+    "context"
+    "crypto/sha256"
+    "errors"
+    "fmt"
+    "math/big"
+    "strconv"
+
+    gonanoid "github.com/matoous/go-nanoid/v2"
+)
+
+// Short code generation strategies that can be selected with CODE_STRATEGY
+const (
+    StrategyRandom  = "random"  // Random nanoid codes
+    StrategyCounter = "counter" // Base62 encoded monotonic counter
+    StrategyHashids = "hashids" // Counter obfuscated with a salted alphabet
+    StrategyHash    = "hash"    // Derived from the original URL
+)
+
+const (
+    // Base62Alphabet is the default alphabet for counter-based codes
+    Base62Alphabet = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+    // minAlphabetLength keeps generated codes from getting needlessly long
+    minAlphabetLength = 16
+)
+
+var (
+    // ErrUnknownStrategy is returned for a strategy name that doesn't exist
+    ErrUnknownStrategy = errors.New("unknown code generation strategy")
+
+    // ErrInvalidAlphabet is returned for an alphabet that can't be used for short codes
+    ErrInvalidAlphabet = errors.New("alphabet must have at least 16 unique letters, digits, hyphens or underscores")
+
+    // ErrSequenceRequired is returned when a counter-based strategy has no sequence
+    ErrSequenceRequired = errors.New("counter-based strategies need a sequence")
+)
+
+// CodeRequest describes the short code to generate
+type CodeRequest struct {
+    OriginalURL string // Normalized URL the code will point to
+    Length      int    // Desired code length (a minimum for counter-based strategies)
+    Attempt     int    // Zero-based attempt, increased after each collision
+}
+
+// CodeGenerator generates short codes
+type CodeGenerator interface {
+    // Generate returns a short code for req
+    Generate(ctx context.Context, req CodeRequest) (string, error)
+
+    // Alphabet returns the characters codes are made of
+    Alphabet() string
+}
+
+// Sequence hands out increasing numbers to counter-based generators. It must
+// never return the same number twice, including across restarts.
+type Sequence interface {
+    Next(ctx context.Context) (uint64, error)
+}
+
+// GeneratorOptions configures NewCodeGenerator
+type GeneratorOptions struct {
+    Strategy string   // One of the Strategy constants (default random)
+    Alphabet string   // Characters to build codes from (default depends on the strategy)
+    Salt     string   // Secret mixed into hashids and hash codes
+    Sequence Sequence // Number source for the counter and hashids strategies
+}
+
+// NewCodeGenerator creates the generator selected by opts.Strategy
+func NewCodeGenerator(opts GeneratorOptions) (CodeGenerator, error) {
+    alphabet := opts.Alphabet
+    if alphabet == "" {
+        alphabet = DefaultAlphabet
+        if opts.Strategy == StrategyCounter {
+            alphabet = Base62Alphabet
+        }
+    }
+    if !isValidAlphabet(alphabet) {
+        return nil, ErrInvalidAlphabet
+    }
+
+    switch opts.Strategy {
+    case "", StrategyRandom:
+        return &RandomGenerator{alphabet: alphabet}, nil
+    case StrategyCounter:
+        if opts.Sequence == nil {
+            return nil, ErrSequenceRequired
+        }
+        return &CounterGenerator{alphabet: alphabet, sequence: opts.Sequence}, nil
+    case StrategyHashids:
+        if opts.Sequence == nil {
+            return nil, ErrSequenceRequired
+        }
+        return &HashidsGenerator{
+            alphabet: consistentShuffle(alphabet, opts.Salt),
+            salt:     opts.Salt,
+            sequence: opts.Sequence,
+        }, nil
+    case StrategyHash:
+        return &HashGenerator{alphabet: alphabet, salt: opts.Salt}, nil
+    default:
+        return nil, fmt.Errorf("%w: %q", ErrUnknownStrategy, opts.Strategy)
+    }
+}
+
+// isValidAlphabet checks that alphabet has enough unique characters that are all
+// allowed in short codes
+func isValidAlphabet(alphabet string) bool {
+    seen := make(map[rune]bool, len(alphabet))
+    for _, r := range alphabet {
+        isAllowed := (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '-' || r == '_'
+        if !isAllowed || seen[r] {
+            return false
+        }
+        seen[r] = true
+    }
+    return len(seen) >= minAlphabetLength
+}
+
+// RandomGenerator generates random nanoid codes
+type RandomGenerator struct {
+    alphabet string
+}
+
+// Generate returns a random code of req.Length characters
+func (g *RandomGenerator) Generate(ctx context.Context, req CodeRequest) (string, error) {
+    length := req.Length
+    if length <= 0 {
+        length = DefaultShortCodeLength
+    }
+    return gonanoid.Generate(g.alphabet, length)
+}
+
+// Alphabet returns the characters codes are made of
+func (g *RandomGenerator) Alphabet() string {
+    return g.alphabet
+}
+
+// CounterGenerator encodes the next number of a sequence, so codes are short,
+// never repeat and reveal how many links were created
+type CounterGenerator struct {
+    alphabet string
+    sequence Sequence
+}
+
+// Generate encodes the next sequence number, left-padded to req.Length
+func (g *CounterGenerator) Generate(ctx context.Context, req CodeRequest) (string, error) {
+    n, err := g.sequence.Next(ctx)
+    if err != nil {
+        return "", err
+    }
+
+    code := encodeNumber(n, g.alphabet)
+    for len(code) < req.Length {
+        code = g.alphabet[:1] + code
+    }
+    return code, nil
+}
+
+// Alphabet returns the characters codes are made of
+func (g *CounterGenerator) Alphabet() string {
+    return g.alphabet
+}
+
+// HashidsGenerator encodes the next number of a sequence in the style of
+// Hashids: the alphabet is shuffled with a salt and again per number, so
+// consecutive codes look unrelated. Unpadded codes never repeat; padding can
+// rarely make two codes equal, which callers handle like any other collision.
+type HashidsGenerator struct {
+    alphabet string // Already shuffled with salt
+    salt     string
+    sequence Sequence
+}
+
+// Generate encodes the next sequence number, padded to req.Length
+func (g *HashidsGenerator) Generate(ctx context.Context, req CodeRequest) (string, error) {
+    n, err := g.sequence.Next(ctx)
+    if err != nil {
+        return "", err
+    }
+
+    // The lottery character picks the per-number shuffle and leads the code so
+    // it can be decoded again
+    lottery := g.alphabet[n%uint64(len(g.alphabet))]
+    alphabet := consistentShuffle(g.alphabet, string(lottery)+g.salt)
+    code := string(lottery) + encodeNumber(n, alphabet)
+
+    // Pad both sides with characters from ever-reshuffled alphabets
+    for len(code) < req.Length {
+        alphabet = consistentShuffle(alphabet, alphabet)
+        half := len(alphabet) / 2
+        code = alphabet[half:] + code + alphabet[:half]
+        if excess := len(code) - req.Length; excess > 0 {
+            start := excess / 2
+            code = code[start : start+req.Length]
+        }
+    }
+    return code, nil
+}
+
+// Alphabet returns the characters codes are made of
+func (g *HashidsGenerator) Alphabet() string {
+    return g.alphabet
+}
+
+// HashGenerator derives codes from a hash of the original URL, so every
+// instance generates the same code for the same URL
+type HashGenerator struct {
+    alphabet string
+    salt     string
+}
+
+// Generate returns the first req.Length characters of the encoded URL hash.
+// Later attempts hash the attempt number too, giving a different but still
+// deterministic code after a collision.
+func (g *HashGenerator) Generate(ctx context.Context, req CodeRequest) (string, error) {
+    length := req.Length
+    if length <= 0 {
+        length = DefaultShortCodeLength
+    }
+
+    input := g.salt + req.OriginalURL
+    if req.Attempt > 0 {
+        input += "#" + strconv.Itoa(req.Attempt)
+    }
+    sum := sha256.Sum256([]byte(input))
+
+    // A SHA-256 sum has enough entropy for far more characters than a code needs
+    n := new(big.Int).SetBytes(sum[:])
+    base := big.NewInt(int64(len(g.alphabet)))
+    mod := new(big.Int)
+    code := make([]byte, length)
+    for i := range code {
+        n.DivMod(n, base, mod)
+        code[i] = g.alphabet[mod.Int64()]
+    }
+    return string(code), nil
+}
+
+// Alphabet returns the characters codes are made of
+func (g *HashGenerator) Alphabet() string {
+    return g.alphabet
+}
+
+// encodeNumber writes n in the positional system whose digits are alphabet
+func encodeNumber(n uint64, alphabet string) string {
+    base := uint64(len(alphabet))
+    if n == 0 {
+        return alphabet[:1]
+    }
+
+    var code []byte
+    for n > 0 {
+        code = append([]byte{alphabet[n%base]}, code...)
+        n /= base
+    }
+    return string(code)
+}
+
+// consistentShuffle permutes alphabet deterministically based on salt, as Hashids does
+func consistentShuffle(alphabet, salt string) string {
+    if salt == "" {
+        return alphabet
+    }
+
+    result := []byte(alphabet)
+    for i, v, p := len(result)-1, 0, 0; i > 0; i-- {
+        v %= len(salt)
+        p += int(salt[v])
+        j := (int(salt[v]) + v + p) % i
+        result[i], result[j] = result[j], result[i]
+        v++
+    }
+    return string(result)
+}
@@ -0,0 +1,135 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// countingSequence is an in-memory Sequence for tests
+type countingSequence struct {
+	last uint64
+}
+
+func (s *countingSequence) Next(ctx context.Context) (uint64, error) {
+	s.last++
+	return s.last, nil
+}
+
+func TestNewCodeGenerator(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    GeneratorOptions
+		wantErr error
+	}{
+		{"default is random", GeneratorOptions{}, nil},
+		{"counter", GeneratorOptions{Strategy: StrategyCounter, Sequence: &countingSequence{}}, nil},
+		{"hashids", GeneratorOptions{Strategy: StrategyHashids, Salt: "pepper", Sequence: &countingSequence{}}, nil},
+		{"hash", GeneratorOptions{Strategy: StrategyHash}, nil},
+		{"unknown strategy", GeneratorOptions{Strategy: "sequential"}, ErrUnknownStrategy},
+		{"counter without sequence", GeneratorOptions{Strategy: StrategyCounter}, ErrSequenceRequired},
+		{"alphabet too short", GeneratorOptions{Alphabet: "abcdef"}, ErrInvalidAlphabet},
+		{"alphabet with duplicates", GeneratorOptions{Alphabet: "aabcdefghijklmnop"}, ErrInvalidAlphabet},
+		{"alphabet with unsafe characters", GeneratorOptions{Alphabet: "abcdefghijklmnop/"}, ErrInvalidAlphabet},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewCodeGenerator(tt.opts)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("NewCodeGenerator() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCounterGenerator(t *testing.T) {
+	generator, err := NewCodeGenerator(GeneratorOptions{Strategy: StrategyCounter, Sequence: &countingSequence{last: 61}})
+	if err != nil {
+		t.Fatalf("Failed to create generator: %v", err)
+	}
+	ctx := context.Background()
+
+	// 62 and 63 are the first two-digit numbers in base62
+	for _, want := range []string{"000010", "000011"} {
+		code, err := generator.Generate(ctx, CodeRequest{Length: 6})
+		if err != nil {
+			t.Fatalf("Failed to generate code: %v", err)
+		}
+		if code != want {
+			t.Errorf("Expected %s but got %s", want, code)
+		}
+	}
+}
+
+func TestHashidsGenerator(t *testing.T) {
+	ctx := context.Background()
+	newGenerator := func(salt string) CodeGenerator {
+		generator, err := NewCodeGenerator(GeneratorOptions{Strategy: StrategyHashids, Salt: salt, Sequence: &countingSequence{}})
+		if err != nil {
+			t.Fatalf("Failed to create generator: %v", err)
+		}
+		return generator
+	}
+
+	generator := newGenerator("pepper")
+	seen := make(map[string]bool)
+	var first string
+	for i := 0; i < 1000; i++ {
+		code, err := generator.Generate(ctx, CodeRequest{Length: 6})
+		if err != nil {
+			t.Fatalf("Failed to generate code: %v", err)
+		}
+		if len(code) != 6 || !IsValidShortCode(code) {
+			t.Fatalf("Generated invalid code %q", code)
+		}
+		if seen[code] {
+			t.Fatalf("Code %s was generated twice", code)
+		}
+		seen[code] = true
+		if i == 0 {
+			first = code
+		}
+	}
+
+	// A different salt yields different codes for the same numbers
+	other, _ := newGenerator("salt").Generate(ctx, CodeRequest{Length: 6})
+	if other == first {
+		t.Errorf("Expected salts to change the code but both produced %s", first)
+	}
+}
+
+func TestHashGenerator(t *testing.T) {
+	ctx := context.Background()
+	newGenerator := func() CodeGenerator {
+		generator, err := NewCodeGenerator(GeneratorOptions{Strategy: StrategyHash, Salt: "pepper"})
+		if err != nil {
+			t.Fatalf("Failed to create generator: %v", err)
+		}
+		return generator
+	}
+
+	// Separate generators stand in for separate instances
+	req := CodeRequest{OriginalURL: "https://github.com/golang/go", Length: 7}
+	code1, _ := newGenerator().Generate(ctx, req)
+	code2, _ := newGenerator().Generate(ctx, req)
+	if code1 != code2 {
+		t.Errorf("Expected the same code for the same URL but got %s and %s", code1, code2)
+	}
+	if len(code1) != 7 || strings.Trim(code1, DefaultAlphabet) != "" {
+		t.Errorf("Generated invalid code %q", code1)
+	}
+
+	// A retry after a collision produces a different code
+	req.Attempt = 1
+	retry, _ := newGenerator().Generate(ctx, req)
+	if retry == code1 {
+		t.Errorf("Expected a different code on retry but got %s again", retry)
+	}
+
+	other, _ := newGenerator().Generate(ctx, CodeRequest{OriginalURL: "https://go.dev", Length: 7})
+	if other == code1 {
+		t.Errorf("Expected different URLs to get different codes but both got %s", code1)
+	}
+}
@@ -0,0 +1,235 @@
+package utils
+
+import (
+    "bufio"
+    "context"
+    "fmt"
+    "log/slog"
+    "net/url"
+    "os"
+    "strings"
+    "sync"
+    "time"
+)
+
+// DefaultPolicyReloadInterval is how often policy files are checked for changes
+const DefaultPolicyReloadInterval = 30 * time.Second
+
+// domainSet matches hostnames against a list of domains. A plain entry such
+// as "example.com" matches the domain and all of its subdomains; a wildcard
+// entry such as "*.example.com" matches only the subdomains.
+type domainSet struct {
+    domains   map[string]bool // Plain entries
+    wildcards map[string]bool // Wildcard entries without the "*."
+}
+
+// newDomainSet builds a set from entries, skipping blank ones
+func newDomainSet(entries []string) domainSet {
+    set := domainSet{domains: make(map[string]bool), wildcards: make(map[string]bool)}
+    for _, entry := range entries {
+        entry = normalizeHost(entry)
+        if wildcard, found := strings.CutPrefix(entry, "*."); found {
+            set.wildcards[wildcard] = true
+        } else if entry != "" {
+            set.domains[entry] = true
+        }
+    }
+    return set
+}
+
+// matches reports whether host is one of the domains or a subdomain of them.
+// Only whole labels are compared, so "notexample.com" doesn't match "example.com".
+func (s domainSet) matches(host string) bool {
+    if s.domains[host] {
+        return true
+    }
+    for i := strings.IndexByte(host, '.'); i >= 0; i = strings.IndexByte(host, '.') {
+        host = host[i+1:]
+        if s.domains[host] || s.wildcards[host] {
+            return true
+        }
+    }
+    return false
+}
+
+// normalizeHost lowercases a hostname and drops the trailing dot of a fully
+// qualified name, so equivalent spellings compare equal
+func normalizeHost(host string) string {
+    return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(host)), ".")
+}
+
+// fileStamp identifies a version of a file without reading it
+type fileStamp struct {
+    modTime time.Time
+    size    int64
+}
+
+// DomainPolicy decides which destination domains may be shortened. Domains on
+// the blocklist are always refused. If an allowlist is configured the policy
+// is allowlist-only: every domain that isn't on it is refused too.
+type DomainPolicy struct {
+    blocklistPath string // File listing blocked domains (empty for none)
+    allowlistPath string // File listing allowed domains (empty to allow all)
+
+    mu      sync.RWMutex
+    blocked domainSet
+    allowed *domainSet           // nil unless allowlist-only
+    stamps  map[string]fileStamp // Versions of the files last loaded
+}
+
+// NewDomainPolicy creates a policy from fixed lists. A nil allowed list allows
+// every domain that isn't blocked.
+func NewDomainPolicy(blocked, allowed []string) *DomainPolicy {
+    p := &DomainPolicy{blocked: newDomainSet(blocked)}
+    if allowed != nil {
+        set := newDomainSet(allowed)
+        p.allowed = &set
+    }
+    return p
+}
+
+// LoadDomainPolicy creates a policy from a blocklist and an allowlist file,
+// either of which may be empty to leave it out. Files hold one domain or
+// wildcard per line; blank lines and lines starting with # are ignored.
+func LoadDomainPolicy(blocklistPath, allowlistPath string) (*DomainPolicy, error) {
+    p := &DomainPolicy{
+        blocklistPath: blocklistPath,
+        allowlistPath: allowlistPath,
+    }
+    if err := p.Reload(); err != nil {
+        return nil, err
+    }
+    return p, nil
+}
+
+// Check returns ErrBlockedDomain if host, which may include a port, may not
+// be shortened. A nil policy allows everything.
+func (p *DomainPolicy) Check(host string) error {
+    if p == nil {
+        return nil
+    }
+    host = normalizeHost((&url.URL{Host: host}).Hostname())
+
+    p.mu.RLock()
+    defer p.mu.RUnlock()
+
+    if p.blocked.matches(host) {
+        return ErrBlockedDomain
+    }
+    if p.allowed != nil && !p.allowed.matches(host) {
+        return ErrBlockedDomain
+    }
+    return nil
+}
+
+// Reload reads the policy files again. On error the previous rules stay in place.
+func (p *DomainPolicy) Reload() error {
+    stamps := make(map[string]fileStamp)
+
+    blocked, err := readDomainFile(p.blocklistPath, stamps)
+    if err != nil {
+        return fmt.Errorf("load domain blocklist: %w", err)
+    }
+    allowed, err := readDomainFile(p.allowlistPath, stamps)
+    if err != nil {
+        return fmt.Errorf("load domain allowlist: %w", err)
+    }
+
+    p.mu.Lock()
+    defer p.mu.Unlock()
+
+    p.blocked = newDomainSet(blocked)
+    p.allowed = nil
+    if p.allowlistPath != "" {
+        set := newDomainSet(allowed)
+        p.allowed = &set
+    }
+    p.stamps = stamps
+    return nil
+}
+
+// changed reports whether a policy file differs from the version last loaded
+func (p *DomainPolicy) changed() bool {
+    p.mu.RLock()
+    defer p.mu.RUnlock()
+
+    for _, path := range []string{p.blocklistPath, p.allowlistPath} {
+        if path == "" {
+            continue
+        }
+        info, err := os.Stat(path)
+        if err != nil {
+            // Report it, the reload logs the error
+            return true
+        }
+        if p.stamps[path] != (fileStamp{modTime: info.ModTime(), size: info.Size()}) {
+            return true
+        }
+    }
+    return false
+}
+
+// Watch reloads the policy files whenever they change, checking every
+// interval until ctx is cancelled. A file that fails to load is logged and
+// the previous rules are kept.
+func (p *DomainPolicy) Watch(ctx context.Context, interval time.Duration) {
+    if interval <= 0 {
+        interval = DefaultPolicyReloadInterval
+    }
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+
+    for {
+        select {
+        case <-ctx.Done():
+            return
+        case <-ticker.C:
+            if !p.changed() {
+                continue
+            }
+            if err := p.Reload(); err != nil {
+                slog.Error("Keeping previous domain policy", "error", err)
+                continue
+            }
+            slog.Info("Reloaded domain policy")
+        }
+    }
+}
+
+// readDomainFile returns the entries in a policy file and records its version
+// in stamps. An empty path has no entries.
+func readDomainFile(path string, stamps map[string]fileStamp) ([]string, error) {
+    if path == "" {
+        return nil, nil
+    }
+
+    f, err := os.Open(path)
+    if err != nil {
+        return nil, err
+    }
+    defer f.Close()
+
+    info, err := f.Stat()
+    if err != nil {
+        return nil, err
+    }
+
+    var entries []string
+    scanner := bufio.NewScanner(f)
+    for lineNumber := 1; scanner.Scan(); lineNumber++ {
+        line := strings.TrimSpace(scanner.Text())
+        if line == "" || strings.HasPrefix(line, "#") {
+            continue
+        }
+        if strings.ContainsAny(line, " \t/:") || strings.Contains(strings.TrimPrefix(line, "*."), "*") {
+            return nil, fmt.Errorf("%s:%d: invalid domain %q", path, lineNumber, line)
+        }
+        entries = append(entries, line)
+    }
+    if err := scanner.Err(); err != nil {
+        return nil, err
+    }
+
+    stamps[path] = fileStamp{modTime: info.ModTime(), size: info.Size()}
+    return entries, nil
+}
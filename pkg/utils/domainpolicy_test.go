@@ -0,0 +1,118 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDomainPolicy_Blocklist(t *testing.T) {
+	policy := NewDomainPolicy([]string{"example.com", "*.evil.org", "Malicious.COM."}, nil)
+
+	tests := []struct {
+		host    string
+		blocked bool
+	}{
+		{"example.com", true},
+		{"www.example.com", true},
+		{"EXAMPLE.com:8443", true},
+		{"example.com.", true},
+		{"notexample.com", false},
+		{"example.com.au", false},
+		{"evil.org", false}, // Wildcards only cover subdomains
+		{"cdn.evil.org", true},
+		{"a.b.evil.org", true},
+		{"malicious.com", true},
+		{"github.com", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			err := policy.Check(tt.host)
+			if tt.blocked && err != ErrBlockedDomain {
+				t.Errorf("Expected %s to be blocked but got: %v", tt.host, err)
+			}
+			if !tt.blocked && err != nil {
+				t.Errorf("Expected %s to be allowed but got: %v", tt.host, err)
+			}
+		})
+	}
+}
+
+func TestDomainPolicy_Allowlist(t *testing.T) {
+	policy := NewDomainPolicy([]string{"secret.corp.example"}, []string{"corp.example", "*.partner.test"})
+
+	tests := []struct {
+		host    string
+		blocked bool
+	}{
+		{"corp.example", false},
+		{"wiki.corp.example", false},
+		{"secret.corp.example", true}, // The blocklist wins
+		{"app.partner.test", false},
+		{"partner.test", true},
+		{"github.com", true},
+	}
+
+	for _, tt := range tests {
+		if err := policy.Check(tt.host); (err != nil) != tt.blocked {
+			t.Errorf("%s: expected blocked %v but got: %v", tt.host, tt.blocked, err)
+		}
+	}
+
+	var none *DomainPolicy
+	if err := none.Check("anything.test"); err != nil {
+		t.Errorf("Expected a nil policy to allow everything but got: %v", err)
+	}
+}
+
+func TestDomainPolicy_LoadAndReload(t *testing.T) {
+	dir := t.TempDir()
+	blocklist := filepath.Join(dir, "blocklist.txt")
+	if err := os.WriteFile(blocklist, []byte("# Known bad\nexample.com\n\n  *.evil.org  \n"), 0o644); err != nil {
+		t.Fatalf("Failed to write blocklist: %v", err)
+	}
+
+	policy, err := LoadDomainPolicy(blocklist, "")
+	if err != nil {
+		t.Fatalf("Failed to load policy: %v", err)
+	}
+	if policy.Check("www.example.com") != ErrBlockedDomain || policy.Check("cdn.evil.org") != ErrBlockedDomain {
+		t.Error("Expected the listed domains to be blocked")
+	}
+	if policy.changed() {
+		t.Error("Expected no change right after loading")
+	}
+
+	// Rewrite the file with a different size and a later modification time
+	if err := os.WriteFile(blocklist, []byte("github.com\n"), 0o644); err != nil {
+		t.Fatalf("Failed to write blocklist: %v", err)
+	}
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(blocklist, later, later)
+	if !policy.changed() {
+		t.Fatal("Expected the edit to be noticed")
+	}
+	if err := policy.Reload(); err != nil {
+		t.Fatalf("Failed to reload policy: %v", err)
+	}
+	if policy.Check("example.com") != nil || policy.Check("github.com") != ErrBlockedDomain {
+		t.Error("Expected the reloaded rules to apply")
+	}
+
+	// A broken file keeps the previous rules
+	if err := os.WriteFile(blocklist, []byte("https://example.com/path\n"), 0o644); err != nil {
+		t.Fatalf("Failed to write blocklist: %v", err)
+	}
+	if err := policy.Reload(); err == nil {
+		t.Error("Expected an error for an invalid entry")
+	}
+	if policy.Check("github.com") != ErrBlockedDomain {
+		t.Error("Expected the previous rules to stay in place")
+	}
+
+	if _, err := LoadDomainPolicy(filepath.Join(dir, "missing.txt"), ""); err == nil {
+		t.Error("Expected an error for a missing file")
+	}
+}
@@ -10,6 +10,9 @@ const (
     // DefaultShortCodeLength is the default length for generated short codes
     DefaultShortCodeLength = 6
     
+    // MaxShortCodeLength is the longest short code that is accepted or generated
+    MaxShortCodeLength = 10
+    
     // Default alphabet for URL-safe short codes
     // Excludes similar looking characters like 1, l, I, 0, O
     DefaultAlphabet = "23456789abcdefghijkmnpqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ"
@@ -0,0 +1,174 @@
+package utils
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "net/http"
+    "net/url"
+    "time"
+)
+
+// ErrShortenerChain is returned when a URL is a short link of another URL shortener
+var ErrShortenerChain = errors.New("URL is a short link of another shortener")
+
+const (
+    // DefaultResolveTimeout bounds how long resolving a short link may take
+    DefaultResolveTimeout = 5 * time.Second
+
+    // DefaultMaxRedirectHops is how many redirects are followed when resolving
+    DefaultMaxRedirectHops = 10
+)
+
+// DefaultShortenerDomains are well-known URL shorteners. Shortening their
+// links would chain two redirects, hiding the real destination.
+var DefaultShortenerDomains = []string{
+    "bit.ly",
+    "bitly.com",
+    "buff.ly",
+    "cutt.ly",
+    "goo.gl",
+    "is.gd",
+    "lnkd.in",
+    "ow.ly",
+    "rb.gy",
+    "rebrand.ly",
+    "s.id",
+    "shorturl.at",
+    "t.co",
+    "t.ly",
+    "tiny.cc",
+    "tinyurl.com",
+    "v.gd",
+}
+
+// LoopGuard refuses URLs that point back at this service, which would
+// redirect to themselves, and recognizes short links of other shorteners
+type LoopGuard struct {
+    selfHosts  map[string]bool // Exact hostnames this service answers on
+    shorteners domainSet       // Other shorteners, including their subdomains
+}
+
+// NewLoopGuard creates a guard for a service reachable at the host of baseURL
+// and at aliases, which are extra hostnames such as a www. variant.
+// shortenerDomains lists other URL shorteners.
+func NewLoopGuard(baseURL string, aliases, shortenerDomains []string) *LoopGuard {
+    g := &LoopGuard{
+        selfHosts:  make(map[string]bool),
+        shorteners: newDomainSet(shortenerDomains),
+    }
+    if parsed, err := url.Parse(baseURL); err == nil && parsed.Host != "" {
+        g.selfHosts[normalizeHost(parsed.Hostname())] = true
+    }
+    for _, alias := range aliases {
+        if host := normalizeHost((&url.URL{Host: alias}).Hostname()); host != "" {
+            g.selfHosts[host] = true
+        }
+    }
+    return g
+}
+
+// IsSelf reports whether host, which may include a port, is this service
+func (g *LoopGuard) IsSelf(host string) bool {
+    if g == nil {
+        return false
+    }
+    return g.selfHosts[normalizeHost((&url.URL{Host: host}).Hostname())]
+}
+
+// Check returns ErrInfiniteLoopDetected if host is this service and
+// ErrShortenerChain if it is another URL shortener. A nil guard allows everything.
+func (g *LoopGuard) Check(host string) error {
+    if g == nil {
+        return nil
+    }
+    if g.IsSelf(host) {
+        return ErrInfiniteLoopDetected
+    }
+    if g.IsShortener(host) {
+        return ErrShortenerChain
+    }
+    return nil
+}
+
+// IsShortener reports whether host, which may include a port, is another URL shortener
+func (g *LoopGuard) IsShortener(host string) bool {
+    if g == nil {
+        return false
+    }
+    return g.shorteners.matches(normalizeHost((&url.URL{Host: host}).Hostname()))
+}
+
+// RedirectResolver follows the redirects of short links to find where they
+// end. Only shortener hosts are requested: the first redirect to any other
+// host is taken as the destination without visiting it.
+type RedirectResolver struct {
+    client *http.Client
+}
+
+// NewRedirectResolver creates a resolver that follows redirects between the
+// shorteners known to guard, giving up after timeout or maxHops redirects.
+// Redirects back to this service stop with ErrInfiniteLoopDetected.
+func NewRedirectResolver(guard *LoopGuard, timeout time.Duration, maxHops int) *RedirectResolver {
+    if timeout <= 0 {
+        timeout = DefaultResolveTimeout
+    }
+    if maxHops <= 0 {
+        maxHops = DefaultMaxRedirectHops
+    }
+    return &RedirectResolver{
+        client: &http.Client{
+            Timeout: timeout,
+            CheckRedirect: func(req *http.Request, via []*http.Request) error {
+                if guard.IsSelf(req.URL.Host) {
+                    return ErrInfiniteLoopDetected
+                }
+                if !guard.IsShortener(req.URL.Host) {
+                    return http.ErrUseLastResponse
+                }
+                if len(via) >= maxHops {
+                    return fmt.Errorf("stopped after %d redirects", maxHops)
+                }
+                return nil
+            },
+        },
+    }
+}
+
+// Resolve returns the URL that rawURL ends up at after following its redirects
+func (r *RedirectResolver) Resolve(ctx context.Context, rawURL string) (string, error) {
+    // Most shorteners answer HEAD, fall back to GET for the ones that don't
+    final, err := r.follow(ctx, http.MethodHead, rawURL)
+    if err == errMethodNotAllowed {
+        final, err = r.follow(ctx, http.MethodGet, rawURL)
+    }
+    return final, err
+}
+
+// errMethodNotAllowed is returned by follow when the server refuses the method
+var errMethodNotAllowed = errors.New("method not allowed")
+
+// follow requests rawURL with method and returns the URL the last response
+// redirects to, or the URL of the last response if it doesn't redirect
+func (r *RedirectResolver) follow(ctx context.Context, method, rawURL string) (string, error) {
+    req, err := http.NewRequestWithContext(ctx, method, rawURL, nil)
+    if err != nil {
+        return "", err
+    }
+
+    resp, err := r.client.Do(req)
+    if err != nil {
+        return "", err
+    }
+    resp.Body.Close()
+
+    if resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented {
+        return "", errMethodNotAllowed
+    }
+    if resp.StatusCode >= 300 && resp.StatusCode < 400 {
+        if location, err := resp.Location(); err == nil {
+            return location.String(), nil
+        }
+    }
+    return resp.Request.URL.String(), nil
+}
@@ -0,0 +1,100 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLoopGuard_Check(t *testing.T) {
+	guard := NewLoopGuard("https://Sho.rt:8443", []string{"www.sho.rt", "go.example:3000"}, DefaultShortenerDomains)
+
+	tests := []struct {
+		host     string
+		expected error
+	}{
+		{"sho.rt", ErrInfiniteLoopDetected},
+		{"SHO.RT:443", ErrInfiniteLoopDetected},
+		{"www.sho.rt", ErrInfiniteLoopDetected},
+		{"go.example", ErrInfiniteLoopDetected},
+		{"docs.sho.rt", nil}, // Only the exact hostnames are this service
+		{"localhost:3000", nil},
+		{"bit.ly", ErrShortenerChain},
+		{"j.mp.bit.ly", ErrShortenerChain},
+		{"notbit.ly", nil},
+		{"github.com", nil},
+	}
+
+	for _, tt := range tests {
+		if err := guard.Check(tt.host); err != tt.expected {
+			t.Errorf("%s: expected %v but got %v", tt.host, tt.expected, err)
+		}
+	}
+
+	var none *LoopGuard
+	if err := none.Check("bit.ly"); err != nil {
+		t.Errorf("Expected a nil guard to allow everything but got: %v", err)
+	}
+}
+
+func TestRedirectResolver_Resolve(t *testing.T) {
+	const destination = "https://github.com/golang/go"
+
+	var methods []string
+	shortener := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		methods = append(methods, r.Method)
+		switch r.URL.Path {
+		case "/get-only":
+			if r.Method == http.MethodHead {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			http.Redirect(w, r, destination, http.StatusFound)
+		case "/hop":
+			http.Redirect(w, r, "/final", http.StatusMovedPermanently)
+		case "/final":
+			http.Redirect(w, r, destination, http.StatusFound)
+		case "/loop":
+			http.Redirect(w, r, "https://sho.rt/abc123", http.StatusFound)
+		case "/forever":
+			http.Redirect(w, r, "/forever", http.StatusFound)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer shortener.Close()
+
+	// The test server counts as a shortener, so hops between its paths are
+	// followed while the destination is never requested
+	resolver := NewRedirectResolver(NewLoopGuard("https://sho.rt", nil, []string{"127.0.0.1"}), 0, 3)
+	ctx := context.Background()
+
+	final, err := resolver.Resolve(ctx, shortener.URL+"/hop")
+	if err != nil || final != destination {
+		t.Errorf("Expected %s but got %s (err=%v)", destination, final, err)
+	}
+
+	methods = nil
+	final, err = resolver.Resolve(ctx, shortener.URL+"/get-only")
+	if err != nil || final != destination {
+		t.Errorf("Expected %s after falling back to GET but got %s (err=%v)", destination, final, err)
+	}
+	if len(methods) != 2 || methods[0] != http.MethodHead || methods[1] != http.MethodGet {
+		t.Errorf("Expected HEAD then GET but got %v", methods)
+	}
+
+	// A link that doesn't redirect resolves to itself
+	final, err = resolver.Resolve(ctx, shortener.URL+"/unknown")
+	if err != nil || final != shortener.URL+"/unknown" {
+		t.Errorf("Expected the link itself but got %s (err=%v)", final, err)
+	}
+
+	if _, err := resolver.Resolve(ctx, shortener.URL+"/loop"); !errors.Is(err, ErrInfiniteLoopDetected) {
+		t.Errorf("Expected ErrInfiniteLoopDetected but got: %v", err)
+	}
+	if _, err := resolver.Resolve(ctx, shortener.URL+"/forever"); err == nil {
+		t.Error("Expected an error after too many redirects")
+	}
+}
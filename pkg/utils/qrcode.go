@@ -0,0 +1,198 @@
+package utils
+
+import (
+    "bytes"
+    "errors"
+    "fmt"
+    "image"
+    "image/color"
+    "image/png"
+    "strconv"
+    "strings"
+
+    qrcode "github.com/skip2/go-qrcode"
+)
+
+// QR code formats that can be rendered
+const (
+    QRFormatPNG = "png"
+    QRFormatSVG = "svg"
+)
+
+const (
+    // DefaultQRSize is the default width and height of a QR code image in pixels
+    DefaultQRSize = 256
+
+    // MinQRSize and MaxQRSize bound the size of a QR code image in pixels
+    MinQRSize = 64
+    MaxQRSize = 2048
+
+    // DefaultQRMargin is the default quiet zone around a QR code in modules,
+    // the minimum the QR specification asks for
+    DefaultQRMargin = 4
+
+    // MaxQRMargin is the widest quiet zone allowed in modules
+    MaxQRMargin = 16
+)
+
+// ErrInvalidColor is returned when a color isn't a hex RGB value
+var ErrInvalidColor = errors.New("color must be a hex RGB value such as 000000 or #fff")
+
+// qrLevels maps error correction levels to the share of the code that can be
+// damaged and still read: L 7%, M 15%, Q 25% and H 30%
+var qrLevels = map[string]qrcode.RecoveryLevel{
+    "L": qrcode.Low,
+    "M": qrcode.Medium,
+    "Q": qrcode.High,
+    "H": qrcode.Highest,
+}
+
+// QROptions controls how a QR code is rendered
+type QROptions struct {
+    Format     string     // png or svg
+    Size       int        // Width and height of the image in pixels
+    Level      string     // Error correction level: L, M, Q or H
+    Margin     int        // Quiet zone around the code in modules
+    Foreground color.RGBA // Color of the dark modules
+    Background color.RGBA // Color of the light modules and the margin
+}
+
+// DefaultQROptions returns options for a black on white PNG of DefaultQRSize
+// with medium error correction
+func DefaultQROptions() QROptions {
+    return QROptions{
+        Format:     QRFormatPNG,
+        Size:       DefaultQRSize,
+        Level:      "M",
+        Margin:     DefaultQRMargin,
+        Foreground: color.RGBA{A: 0xff},
+        Background: color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff},
+    }
+}
+
+// Validate reports the first option that is out of range
+func (o QROptions) Validate() error {
+    if o.Format != QRFormatPNG && o.Format != QRFormatSVG {
+        return fmt.Errorf("format must be %s or %s", QRFormatPNG, QRFormatSVG)
+    }
+    if o.Size < MinQRSize || o.Size > MaxQRSize {
+        return fmt.Errorf("size must be between %d and %d pixels", MinQRSize, MaxQRSize)
+    }
+    if _, ok := qrLevels[o.Level]; !ok {
+        return errors.New("level must be L, M, Q or H")
+    }
+    if o.Margin < 0 || o.Margin > MaxQRMargin {
+        return fmt.Errorf("margin must be between 0 and %d modules", MaxQRMargin)
+    }
+    return nil
+}
+
+// ParseHexColor parses an opaque color written as RRGGBB or RGB, with or
+// without a leading #
+func ParseHexColor(s string) (color.RGBA, error) {
+    s = strings.TrimPrefix(s, "#")
+    if len(s) == 3 {
+        s = string([]byte{s[0], s[0], s[1], s[1], s[2], s[2]})
+    }
+    if len(s) != 6 {
+        return color.RGBA{}, ErrInvalidColor
+    }
+    value, err := strconv.ParseUint(s, 16, 32)
+    if err != nil {
+        return color.RGBA{}, ErrInvalidColor
+    }
+    return color.RGBA{R: uint8(value >> 16), G: uint8(value >> 8), B: uint8(value), A: 0xff}, nil
+}
+
+// RenderQRCode encodes content as a QR code and renders it as opts asks. It
+// returns the image and its content type.
+func RenderQRCode(content string, opts QROptions) ([]byte, string, error) {
+    if err := opts.Validate(); err != nil {
+        return nil, "", err
+    }
+
+    code, err := qrcode.New(content, qrLevels[opts.Level])
+    if err != nil {
+        return nil, "", err
+    }
+    // The margin is drawn below, so it can be sized
+    code.DisableBorder = true
+    grid := newQRGrid(code.Bitmap(), opts)
+
+    if opts.Format == QRFormatSVG {
+        return grid.svg(), "image/svg+xml", nil
+    }
+    data, err := grid.png()
+    return data, "image/png", err
+}
+
+// qrGrid lays out the modules of a QR code on an image of the requested
+// size. Modules are a whole number of pixels so they stay sharp; any pixels
+// left over are added to the margin.
+type qrGrid struct {
+    modules [][]bool
+    opts    QROptions
+    scale   int // Pixels per module
+    offset  int // Pixels before the first module, on both axes
+}
+
+// newQRGrid fits modules and a margin of opts.Margin modules into opts.Size pixels
+func newQRGrid(modules [][]bool, opts QROptions) qrGrid {
+    span := len(modules) + 2*opts.Margin
+    scale := max(opts.Size/span, 1)
+    // A code too big for the size grows the image rather than losing modules
+    opts.Size = max(opts.Size, span*scale)
+    return qrGrid{
+        modules: modules,
+        opts:    opts,
+        scale:   scale,
+        offset:  (opts.Size - len(modules)*scale) / 2,
+    }
+}
+
+// png renders the grid as a PNG image
+func (g qrGrid) png() ([]byte, error) {
+    palette := color.Palette{g.opts.Background, g.opts.Foreground}
+    img := image.NewPaletted(image.Rect(0, 0, g.opts.Size, g.opts.Size), palette)
+    for y, row := range g.modules {
+        for x, dark := range row {
+            if !dark {
+                continue
+            }
+            for dy := 0; dy < g.scale; dy++ {
+                for dx := 0; dx < g.scale; dx++ {
+                    img.SetColorIndex(g.offset+x*g.scale+dx, g.offset+y*g.scale+dy, 1)
+                }
+            }
+        }
+    }
+
+    var buf bytes.Buffer
+    if err := png.Encode(&buf, img); err != nil {
+        return nil, err
+    }
+    return buf.Bytes(), nil
+}
+
+// svg renders the grid as an SVG image, drawing the dark modules as one path
+func (g qrGrid) svg() []byte {
+    var buf bytes.Buffer
+    fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`,
+        g.opts.Size, g.opts.Size, g.opts.Size, g.opts.Size)
+    fmt.Fprintf(&buf, `<rect width="100%%" height="100%%" fill="%s"/>`, hexColor(g.opts.Background))
+    fmt.Fprintf(&buf, `<path fill="%s" d="`, hexColor(g.opts.Foreground))
+    for y, row := range g.modules {
+        for x, dark := range row {
+            if dark {
+                fmt.Fprintf(&buf, "M%d %dh%dv%dh-%dz", g.offset+x*g.scale, g.offset+y*g.scale, g.scale, g.scale, g.scale)
+            }
+        }
+    }
+    buf.WriteString(`"/></svg>`)
+    return buf.Bytes()
+}
+
+// hexColor formats c as #rrggbb
+func hexColor(c color.RGBA) string {
+    return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
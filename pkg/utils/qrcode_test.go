@@ -0,0 +1,129 @@
+package utils
+
+import (
+	"bytes"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func TestParseHexColor(t *testing.T) {
+	tests := []struct {
+		input string
+		want  color.RGBA
+		ok    bool
+	}{
+		{"000000", color.RGBA{A: 0xff}, true},
+		{"#1a2B3c", color.RGBA{R: 0x1a, G: 0x2b, B: 0x3c, A: 0xff}, true},
+		{"fff", color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}, true},
+		{"#f0c", color.RGBA{R: 0xff, G: 0x00, B: 0xcc, A: 0xff}, true},
+		{"", color.RGBA{}, false},
+		{"12345", color.RGBA{}, false},
+		{"gg0000", color.RGBA{}, false},
+		{"red", color.RGBA{}, false},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseHexColor(tt.input)
+		if (err == nil) != tt.ok {
+			t.Errorf("ParseHexColor(%q) error = %v, want ok = %v", tt.input, err, tt.ok)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseHexColor(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestQROptions_Validate(t *testing.T) {
+	if err := DefaultQROptions().Validate(); err != nil {
+		t.Fatalf("Expected the default options to be valid but got: %v", err)
+	}
+
+	tests := map[string]func(*QROptions){
+		"format":      func(o *QROptions) { o.Format = "gif" },
+		"small size":  func(o *QROptions) { o.Size = MinQRSize - 1 },
+		"large size":  func(o *QROptions) { o.Size = MaxQRSize + 1 },
+		"level":       func(o *QROptions) { o.Level = "X" },
+		"wide margin": func(o *QROptions) { o.Margin = MaxQRMargin + 1 },
+		"no margin":   func(o *QROptions) { o.Margin = -1 },
+	}
+	for name, change := range tests {
+		opts := DefaultQROptions()
+		change(&opts)
+		if err := opts.Validate(); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestRenderQRCode_PNG(t *testing.T) {
+	opts := DefaultQROptions()
+	opts.Size = 300
+	opts.Margin = 0
+	opts.Foreground = color.RGBA{R: 0x11, G: 0x22, B: 0x33, A: 0xff}
+
+	data, contentType, err := RenderQRCode("http://localhost:3000/abc123", opts)
+	if err != nil {
+		t.Fatalf("Failed to render QR code: %v", err)
+	}
+	if contentType != "image/png" {
+		t.Errorf("Expected image/png but got %s", contentType)
+	}
+
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Failed to decode PNG: %v", err)
+	}
+	if bounds := img.Bounds(); bounds.Dx() != 300 || bounds.Dy() != 300 {
+		t.Errorf("Expected a 300x300 image but got %v", bounds)
+	}
+
+	// Without a margin the finder pattern starts a few leftover pixels from
+	// the corner, which itself is background
+	if got := color.RGBAModel.Convert(img.At(0, 0)); got != opts.Background {
+		t.Errorf("Expected the background color at the corner but got %v", got)
+	}
+	if got := color.RGBAModel.Convert(img.At(150, 150)); got != opts.Foreground && got != opts.Background {
+		t.Errorf("Expected only the two chosen colors but got %v", got)
+	}
+	foundForeground := false
+	for i := 0; i < 30 && !foundForeground; i++ {
+		foundForeground = color.RGBAModel.Convert(img.At(i, i)) == opts.Foreground
+	}
+	if !foundForeground {
+		t.Error("Expected the finder pattern near the corner")
+	}
+}
+
+func TestRenderQRCode_SVG(t *testing.T) {
+	opts := DefaultQROptions()
+	opts.Format = QRFormatSVG
+	opts.Background, _ = ParseHexColor("fafafa")
+
+	data, contentType, err := RenderQRCode("http://localhost:3000/abc123", opts)
+	if err != nil {
+		t.Fatalf("Failed to render QR code: %v", err)
+	}
+	if contentType != "image/svg+xml" {
+		t.Errorf("Expected image/svg+xml but got %s", contentType)
+	}
+
+	svg := string(data)
+	for _, expected := range []string{`width="256"`, `fill="#fafafa"`, `fill="#000000"`, `<path`} {
+		if !strings.Contains(svg, expected) {
+			t.Errorf("Expected SVG to contain %q", expected)
+		}
+	}
+
+	// A higher error correction level needs more modules
+	opts.Level = "H"
+	dense, _, err := RenderQRCode("http://localhost:3000/abc123", opts)
+	if err != nil {
+		t.Fatalf("Failed to render QR code: %v", err)
+	}
+	if len(dense) <= len(data) {
+		t.Error("Expected level H to draw more modules than level M")
+	}
+}
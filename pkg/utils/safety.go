@@ -0,0 +1,48 @@
+package utils
+
+import (
+    "fmt"
+    "net"
+    "net/url"
+    "strings"
+)
+
+// maxPlainURLLength is the length past which a URL is hard to read in full
+const maxPlainURLLength = 200
+
+// LinkWarnings returns reasons to look closely at a destination URL before
+// following it, for showing on a preview page. A URL without anything unusual
+// has none. The checks are hints, not a verdict on the destination.
+func LinkWarnings(rawURL string) []string {
+    parsed, err := url.Parse(rawURL)
+    if err != nil {
+        return []string{"The destination address could not be read."}
+    }
+
+    var warnings []string
+    if parsed.Scheme != "https" {
+        warnings = append(warnings, "The destination doesn't use HTTPS, so the connection isn't encrypted.")
+    }
+    if parsed.User != nil {
+        warnings = append(warnings, "The address includes a user name before the domain, which can disguise the real domain.")
+    }
+
+    host := parsed.Hostname()
+    if net.ParseIP(host) != nil {
+        warnings = append(warnings, "The destination is an IP address rather than a domain name.")
+    }
+    for _, label := range strings.Split(strings.ToLower(host), ".") {
+        if strings.HasPrefix(label, "xn--") {
+            warnings = append(warnings, "The domain contains international characters, which can imitate the look of another domain.")
+            break
+        }
+    }
+    if port := parsed.Port(); port != "" && port != "443" && port != "80" {
+        warnings = append(warnings, fmt.Sprintf("The destination uses the unusual port %s.", port))
+    }
+    if len(rawURL) > maxPlainURLLength {
+        warnings = append(warnings, "The address is very long, so check all of it.")
+    }
+
+    return warnings
+}
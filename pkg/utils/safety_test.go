@@ -0,0 +1,36 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLinkWarnings(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string // Substring of the expected warning, empty for none
+	}{
+		{"https://github.com/golang/go", ""},
+		{"https://github.com:443/golang/go", ""},
+		{"http://github.com/golang/go", "HTTPS"},
+		{"https://paypal.com@evil.example/login", "user name"},
+		{"https://192.0.2.10/download", "IP address"},
+		{"https://[2001:db8::1]/download", "IP address"},
+		{"https://xn--pypal-4ve.com/", "international characters"},
+		{"https://github.com:8443/golang/go", "port 8443"},
+		{"https://github.com/" + strings.Repeat("a", 200), "very long"},
+	}
+
+	for _, tt := range tests {
+		warnings := LinkWarnings(tt.url)
+		if tt.want == "" {
+			if len(warnings) != 0 {
+				t.Errorf("LinkWarnings(%q) = %v, want none", tt.url, warnings)
+			}
+			continue
+		}
+		if len(warnings) != 1 || !strings.Contains(warnings[0], tt.want) {
+			t.Errorf("LinkWarnings(%q) = %v, want one mentioning %q", tt.url, warnings, tt.want)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package utils
+
+import "strings"
+
+// userAgentFamilies maps User-Agent substrings to browser families. Order
+// matters: most browsers also claim to be Safari or Chrome, so the more
+// specific tokens are checked first.
+var userAgentFamilies = []struct {
+    token  string
+    family string
+}{
+    {"bot", "Bot"},
+    {"crawler", "Bot"},
+    {"spider", "Bot"},
+    {"curl/", "curl"},
+    {"wget/", "Wget"},
+    {"edg", "Edge"},
+    {"opr/", "Opera"},
+    {"opera", "Opera"},
+    {"samsungbrowser/", "Samsung Internet"},
+    {"firefox/", "Firefox"},
+    {"fxios/", "Firefox"},
+    {"crios/", "Chrome"},
+    {"chrome/", "Chrome"},
+    {"safari/", "Safari"},
+}
+
+// UserAgentFamily returns the browser family of a User-Agent header,
+// "Other" for unrecognized agents and "Unknown" if the header is empty
+func UserAgentFamily(userAgent string) string {
+    if userAgent == "" {
+        return "Unknown"
+    }
+
+    ua := strings.ToLower(userAgent)
+    for _, f := range userAgentFamilies {
+        if strings.Contains(ua, f.token) {
+            return f.family
+        }
+    }
+    return "Other"
+}
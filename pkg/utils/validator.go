@@ -3,6 +3,7 @@ package utils
 import (
     "errors"
     "net/url"
+    "regexp"
     "strings"
 
     "github.com/asaskevich/govalidator"
@@ -12,23 +13,30 @@ var (
     // ErrInvalidURL is returned when the URL is invalid
     ErrInvalidURL = errors.New("invalid URL format")
     
-    // ErrBlockedDomain is returned when the URL domain is blocked
+    // ErrBlockedDomain is returned when a DomainPolicy refuses the URL domain
     ErrBlockedDomain = errors.New("domain is blocked")
     
-    // ErrInfiniteLoopDetected is returned when attempting to shorten our own URL
+    // ErrInfiniteLoopDetected is returned when a LoopGuard finds a URL pointing at this service
     ErrInfiniteLoopDetected = errors.New("cannot shorten URLs from this service (infinite loop)")
     
     // ErrHTTPSRequired is returned when the URL doesn't use HTTPS
     ErrHTTPSRequired = errors.New("URL must use HTTPS protocol")
 )
 
-// List of domains that should not be shortened (to prevent redirect loops)
-var selfDomains = []string{
-    "localhost",
-    "127.0.0.1",
-    // Add your actual domain name(s) here
+// Short codes that would shadow the service's own routes and so can't be used as aliases
+var reservedShortCodes = map[string]bool{
+    "api":     true,
+    "health":  true,
+    "metrics": true,
+    "admin":   true,
+    "static":  true,
+    "assets":  true,
 }
 
+// shortCodePattern allows letters, digits, hyphens and underscores, but must
+// start and end with a letter or digit
+var shortCodePattern = regexp.MustCompile(`^[A-Za-z0-9](?:[A-Za-z0-9_-]*[A-Za-z0-9])?$`)
+
 // URLInfo contains processed URL information
 type URLInfo struct {
     OriginalURL   string // The original input URL
@@ -36,7 +44,9 @@ type URLInfo struct {
     Domain        string // Extracted domain
 }
 
-// ValidateURL checks if a URL is valid and meets requirements
+// ValidateURL checks if a URL is valid and meets requirements. Which domains
+// may be shortened is up to a DomainPolicy, and links back to this service
+// are caught by a LoopGuard.
 func ValidateURL(rawURL string) (*url.URL, error) {
     // Basic URL validation
     if !govalidator.IsURL(rawURL) {
@@ -49,21 +59,6 @@ func ValidateURL(rawURL string) (*url.URL, error) {
         return nil, ErrInvalidURL
     }
     
-    // Check for infinite loop
-    for _, domain := range selfDomains {
-        if strings.Contains(parsedURL.Host, domain) {
-            return nil, ErrInfiniteLoopDetected
-        }
-    }
-    
-    // Check for blocked domains
-    blockedDomains := []string{"example.com", "malicious.com"}
-    for _, blocked := range blockedDomains {
-        if strings.Contains(parsedURL.Host, blocked) {
-            return nil, ErrBlockedDomain
-        }
-    }
-    
     // Validate scheme
     if parsedURL.Scheme != "https" && parsedURL.Scheme != "http" {
         return nil, ErrInvalidURL
@@ -77,6 +72,16 @@ func ExtractDomain(parsedURL *url.URL) string {
     return parsedURL.Host
 }
 
+// ReferrerHost returns the host of a Referer header, or "direct" when there is
+// no usable referrer
+func ReferrerHost(referrer string) string {
+    parsedURL, err := url.Parse(referrer)
+    if err != nil || parsedURL.Host == "" {
+        return "direct"
+    }
+    return strings.ToLower(parsedURL.Hostname())
+}
+
 // EnforceHTTPS ensures the URL uses HTTPS, converting if necessary
 func EnforceHTTPS(parsedURL *url.URL, enforceHTTPS bool) (string, error) {
     if parsedURL.Scheme != "https" {
@@ -120,5 +125,10 @@ func ProcessURL(rawURL string, enforceHTTPS bool) (URLInfo, error) {
 
 // IsValidShortCode checks if a short code meets our requirements
 func IsValidShortCode(code string) bool {
-    return govalidator.IsAlphanumeric(code) && len(code) >= 4 && len(code) <= 10
+    return shortCodePattern.MatchString(code) && len(code) >= 4 && len(code) <= MaxShortCodeLength
+}
+
+// IsReservedShortCode checks if a short code is reserved for the service's own routes
+func IsReservedShortCode(code string) bool {
+    return reservedShortCodes[strings.ToLower(code)]
 }
\ No newline at end of file
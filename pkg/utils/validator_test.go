@@ -24,22 +24,19 @@ func TestValidateURL(t *testing.T) {
 			errorType:   ErrInvalidURL,
 		},
 		{
-			name:        "HTTP URL (HTTPS required)",
+			name:        "HTTP URL (upgraded to HTTPS later)",
 			inputURL:    "http://example.com",
-			expectError: true,
-			errorType:   ErrBlockedDomain, // Updated to match implementation - example.com is a blocked domain
+			expectError: false,
 		},
 		{
-			name:        "Infinite loop URL (self domain)",
+			name:        "Loops are left to the loop guard",
 			inputURL:    "https://localhost:3000/abc123",
-			expectError: true,
-			errorType:   ErrInfiniteLoopDetected,
+			expectError: false,
 		},
 		{
-			name:        "Blocked domain",
+			name:        "Domains are left to the domain policy",
 			inputURL:    "https://example.com/path",
-			expectError: true,
-			errorType:   ErrBlockedDomain,
+			expectError: false,
 		},
 	}
 
@@ -127,8 +124,28 @@ func TestIsValidShortCode(t *testing.T) {
 			expectValid: true,
 		},
 		{
-			name:        "Short code with special characters",
+			name:        "Short code with hyphen",
 			shortCode:   "abc-123",
+			expectValid: true,
+		},
+		{
+			name:        "Short code with underscore",
+			shortCode:   "abc_123",
+			expectValid: true,
+		},
+		{
+			name:        "Short code with special characters",
+			shortCode:   "abc!123",
+			expectValid: false,
+		},
+		{
+			name:        "Short code starting with hyphen",
+			shortCode:   "-abc123",
+			expectValid: false,
+		},
+		{
+			name:        "Short code ending with underscore",
+			shortCode:   "abc123_",
 			expectValid: false,
 		},
 		{
@@ -157,3 +174,42 @@ func TestIsValidShortCode(t *testing.T) {
 		})
 	}
 }
+
+func TestIsReservedShortCode(t *testing.T) {
+	tests := []struct {
+		shortCode      string
+		expectReserved bool
+	}{
+		{shortCode: "health", expectReserved: true},
+		{shortCode: "API", expectReserved: true},
+		{shortCode: "launch2026", expectReserved: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.shortCode, func(t *testing.T) {
+			if reserved := IsReservedShortCode(tt.shortCode); reserved != tt.expectReserved {
+				t.Errorf("Expected reserved %v but got %v for code %q", tt.expectReserved, reserved, tt.shortCode)
+			}
+		})
+	}
+}
+
+func TestReferrerHost(t *testing.T) {
+	tests := []struct {
+		referrer string
+		want     string
+	}{
+		{"https://news.ycombinator.com/item?id=1", "news.ycombinator.com"},
+		{"https://Twitter.com:443/someone", "twitter.com"},
+		{"", "direct"},
+		{"not a url", "direct"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.referrer, func(t *testing.T) {
+			if got := ReferrerHost(tt.referrer); got != tt.want {
+				t.Errorf("ReferrerHost(%q) = %q, want %q", tt.referrer, got, tt.want)
+			}
+		})
+	}
+}